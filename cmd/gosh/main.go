@@ -2,14 +2,66 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/krzko/gosh/internal/shell"
+	"golang.org/x/term"
 )
 
 func main() {
-	sh, err := shell.New()
+	var opts shell.Options
+	command := flag.String("c", "", "run `command` and exit; further arguments set $0, $1 ...")
+	flag.BoolVar(&opts.Login, "l", false, "act as a login shell, running the login profile")
+	flag.BoolVar(&opts.Login, "login", false, "same as -l")
+	flag.BoolVar(&opts.NoProfile, "noprofile", false, "do not run the login profile")
+	flag.BoolVar(&opts.NoRC, "norc", false, "do not run the rc file")
+	flag.StringVar(&opts.RCFile, "rcfile", "", "run `file` instead of ~/.goshrc")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: gosh [options] [-c command [name [arg ...]]] [script [arg ...]]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	// A login shell is started with a leading '-' in its name.
+	if strings.HasPrefix(os.Args[0], "-") {
+		opts.Login = true
+	}
+	args := flag.Args()
+
+	commandSet := false
+	flag.Visit(func(f *flag.Flag) {
+		commandSet = commandSet || f.Name == "c"
+	})
+
+	switch {
+	case commandSet:
+		name := "gosh"
+		if len(args) > 0 {
+			name, args = args[0], args[1:]
+		}
+		runScript(name, args, "-c", *command)
+
+	case len(args) > 0:
+		src, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gosh: %v\n", err)
+			os.Exit(127)
+		}
+		runScript(args[0], args[1:], args[0], string(src))
+
+	case !term.IsTerminal(int(os.Stdin.Fd())):
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gosh: %v\n", err)
+			os.Exit(1)
+		}
+		runScript("gosh", nil, "stdin", string(src))
+	}
+
+	sh, err := shell.New(opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error initializing shell: %v\n", err)
 		os.Exit(1)
@@ -19,4 +71,15 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+
+	os.Exit(sh.ExitStatus())
+}
+
+// runScript runs src without a prompt and exits with its status. name and
+// args become $0 and the positional parameters; source names src in
+// syntax errors.
+func runScript(name string, args []string, source, src string) {
+	sh := shell.NewScript(name, args)
+	sh.RunScript(source, src)
+	os.Exit(sh.ExitStatus())
 }
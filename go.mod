@@ -6,6 +6,7 @@ require (
 	github.com/chzyer/readline v1.5.1
 	github.com/fatih/color v1.18.0
 	github.com/olekukonko/tablewriter v0.0.5
+	golang.org/x/sys v0.27.0
 	golang.org/x/term v0.26.0
 )
 
@@ -13,5 +14,4 @@ require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mattn/go-runewidth v0.0.9 // indirect
-	golang.org/x/sys v0.27.0 // indirect
 )
@@ -0,0 +1,427 @@
+// internal/shell/ast/ast.go
+package ast
+
+import "fmt"
+
+// Pos is a position in the source text. Line and Col are 1-based; Col
+// counts runes, not bytes.
+type Pos struct {
+	Offset int
+	Line   int
+	Col    int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// IsValid reports whether the position has been set.
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+// Node is implemented by every element of the syntax tree.
+type Node interface {
+	Pos() Pos
+}
+
+// File is a complete parsed input: a line typed at the prompt or a script.
+type File struct {
+	Stmts []*Stmt
+}
+
+func (f *File) Pos() Pos {
+	if len(f.Stmts) == 0 {
+		return Pos{}
+	}
+	return f.Stmts[0].Pos()
+}
+
+// Stmt wraps a command together with everything that applies to it as a
+// whole, such as redirections. Background is set for a statement
+// terminated by '&'.
+type Stmt struct {
+	Position   Pos
+	Cmd        Command
+	Redirs     []*Redirect
+	Background bool
+}
+
+func (s *Stmt) Pos() Pos { return s.Position }
+
+// Command is implemented by every kind of command a Stmt can hold.
+type Command interface {
+	Node
+	commandNode()
+}
+
+// CallExpr is a simple command: optional variable assignments followed by
+// the command name and its arguments. Args may be empty when the command
+// consists only of assignments.
+type CallExpr struct {
+	Assigns []*Assign
+	Args    []*Word
+}
+
+func (c *CallExpr) Pos() Pos {
+	if len(c.Assigns) > 0 {
+		return c.Assigns[0].Pos()
+	}
+	if len(c.Args) > 0 {
+		return c.Args[0].Pos()
+	}
+	return Pos{}
+}
+
+// Pipeline is one or more statements connected with '|'. A leading '!'
+// sets Negated, inverting the pipeline's exit status.
+type Pipeline struct {
+	Position Pos
+	Negated  bool
+	Stmts    []*Stmt
+}
+
+func (p *Pipeline) Pos() Pos { return p.Position }
+
+// BinCmdOp is the operator joining the two sides of a BinaryCmd.
+type BinCmdOp int
+
+const (
+	AndStmt BinCmdOp = iota // &&
+	OrStmt                  // ||
+)
+
+func (op BinCmdOp) String() string {
+	if op == AndStmt {
+		return "&&"
+	}
+	return "||"
+}
+
+// BinaryCmd is two statements joined by && or ||. Chains are
+// left-associative, so "a && b || c" is ((a && b) || c).
+type BinaryCmd struct {
+	OpPos Pos
+	Op    BinCmdOp
+	X, Y  *Stmt
+}
+
+func (b *BinaryCmd) Pos() Pos { return b.X.Pos() }
+
+// Block is a list of statements grouped with { and }, run in the current
+// shell.
+type Block struct {
+	Lbrace, Rbrace Pos
+	Stmts          []*Stmt
+}
+
+func (b *Block) Pos() Pos { return b.Lbrace }
+
+// FuncDecl defines a function, written name() body or function name body.
+// Redirections on Body apply each time the function is called.
+type FuncDecl struct {
+	Position Pos
+	Name     *Lit
+	Body     *Stmt
+}
+
+func (f *FuncDecl) Pos() Pos { return f.Position }
+
+// IfClause is if cond; then list; [elif ...;] [else list;] fi. An elif is
+// held as a nested IfClause in Else, and a plain else as an IfClause
+// with no Cond.
+type IfClause struct {
+	Position Pos
+	Cond     []*Stmt
+	Then     []*Stmt
+	Else     *IfClause
+}
+
+func (c *IfClause) Pos() Pos { return c.Position }
+
+// WhileClause is while cond; do list; done, or with Until set, until
+// cond; do list; done.
+type WhileClause struct {
+	Position Pos
+	Until    bool
+	Cond     []*Stmt
+	Do       []*Stmt
+}
+
+func (c *WhileClause) Pos() Pos { return c.Position }
+
+// ForClause is for name in words; do list; done. Without "in", InSet is
+// false and the loop runs over the positional parameters.
+type ForClause struct {
+	Position Pos
+	Name     *Lit
+	InSet    bool
+	Items    []*Word
+	Do       []*Stmt
+}
+
+func (c *ForClause) Pos() Pos { return c.Position }
+
+// CaseClause is case word in pattern) list;; ... esac.
+type CaseClause struct {
+	Position Pos
+	Word     *Word
+	Items    []*CaseItem
+}
+
+func (c *CaseClause) Pos() Pos { return c.Position }
+
+// CaseItem is one branch of a case: patterns separated by | and the list
+// run if one of them matches.
+type CaseItem struct {
+	Patterns []*Word
+	Stmts    []*Stmt
+}
+
+func (*CallExpr) commandNode()    {}
+func (*Pipeline) commandNode()    {}
+func (*BinaryCmd) commandNode()   {}
+func (*Block) commandNode()       {}
+func (*FuncDecl) commandNode()    {}
+func (*IfClause) commandNode()    {}
+func (*WhileClause) commandNode() {}
+func (*ForClause) commandNode()   {}
+func (*CaseClause) commandNode()  {}
+
+// Assign is a NAME=value word, either on its own or as a prefix to a
+// command.
+type Assign struct {
+	NamePos Pos
+	Name    string
+	Value   *Word
+}
+
+func (a *Assign) Pos() Pos { return a.NamePos }
+
+// RedirOp is the operator of a redirection.
+type RedirOp int
+
+const (
+	RedirOut    RedirOp = iota // >
+	AppendOut                  // >>
+	RedirIn                    // <
+	RedirInOut                 // <>
+	DupIn                      // <&
+	DupOut                     // >&
+	ClobberOut                 // >|
+	RedirAll                   // &>
+	AppendAll                  // &>>
+	Heredoc                    // <<
+	DashHeredoc                // <<-
+	HereString                 // <<<
+)
+
+var redirOpNames = [...]string{
+	RedirOut:    ">",
+	AppendOut:   ">>",
+	RedirIn:     "<",
+	RedirInOut:  "<>",
+	DupIn:       "<&",
+	DupOut:      ">&",
+	ClobberOut:  ">|",
+	RedirAll:    "&>",
+	AppendAll:   "&>>",
+	Heredoc:     "<<",
+	DashHeredoc: "<<-",
+	HereString:  "<<<",
+}
+
+func (op RedirOp) String() string {
+	if int(op) < len(redirOpNames) {
+		return redirOpNames[op]
+	}
+	return fmt.Sprintf("RedirOp(%d)", int(op))
+}
+
+// Redirect is a single redirection such as 2>&1 or >>out.txt. N is the
+// explicit file descriptor number, or nil when the operator's default
+// applies. For a here-document, Word is the delimiter and Hdoc the body,
+// held as a double-quoted word whose literal text is single-quoted so
+// that only its expansions are expanded.
+type Redirect struct {
+	OpPos Pos
+	Op    RedirOp
+	N     *Lit
+	Word  *Word
+	Hdoc  *Word
+}
+
+func (r *Redirect) Pos() Pos {
+	if r.N != nil {
+		return r.N.Pos()
+	}
+	return r.OpPos
+}
+
+// Word is a single shell word made up of literal and quoted parts. The
+// parts are kept separate so that expansion can tell which characters
+// were quoted.
+type Word struct {
+	Parts []WordPart
+}
+
+func (w *Word) Pos() Pos {
+	if len(w.Parts) == 0 {
+		return Pos{}
+	}
+	return w.Parts[0].Pos()
+}
+
+// Lit returns the word's text if it consists of a single unquoted literal
+// with no escapes, and the empty string otherwise. It is how reserved words
+// and assignment names are recognised.
+func (w *Word) Lit() string {
+	if len(w.Parts) != 1 {
+		return ""
+	}
+	lit, ok := w.Parts[0].(*Lit)
+	if !ok {
+		return ""
+	}
+	for i := 0; i < len(lit.Value); i++ {
+		if lit.Value[i] == '\\' {
+			return ""
+		}
+	}
+	return lit.Value
+}
+
+// WordPart is implemented by every piece a Word can be built from.
+type WordPart interface {
+	Node
+	wordPartNode()
+}
+
+// Lit is unquoted text. Value holds the source text as written, including
+// any backslash escapes; they are removed during expansion.
+type Lit struct {
+	ValuePos Pos
+	Value    string
+}
+
+func (l *Lit) Pos() Pos { return l.ValuePos }
+
+// SglQuoted is text inside single quotes, or inside $'...' if Dollar is
+// set, in which backslash escapes such as \n are decoded. Value excludes
+// the quotes and is kept as written.
+type SglQuoted struct {
+	Left   Pos
+	Dollar bool
+	Value  string
+}
+
+func (q *SglQuoted) Pos() Pos { return q.Left }
+
+// DblQuoted is text inside double quotes.
+type DblQuoted struct {
+	Left  Pos
+	Parts []WordPart
+}
+
+func (q *DblQuoted) Pos() Pos { return q.Left }
+
+// ParamOp is the operator inside a braced parameter expansion.
+type ParamOp int
+
+const (
+	ParamNone            ParamOp = iota
+	DefaultUnset                 // ${x-word}
+	DefaultUnsetOrNull           // ${x:-word}
+	AssignUnset                  // ${x=word}
+	AssignUnsetOrNull            // ${x:=word}
+	ErrorUnset                   // ${x?word}
+	ErrorUnsetOrNull             // ${x:?word}
+	AlternateUnset               // ${x+word}
+	AlternateUnsetOrNull         // ${x:+word}
+	RemSmallPrefix               // ${x#pattern}
+	RemLargePrefix               // ${x##pattern}
+	RemSmallSuffix               // ${x%pattern}
+	RemLargeSuffix               // ${x%%pattern}
+	ReplaceFirst                 // ${x/pattern/word}
+	ReplaceAll                   // ${x//pattern/word}
+	Substring                    // ${x:offset} or ${x:offset:length}
+)
+
+var paramOpNames = [...]string{
+	ParamNone:            "",
+	DefaultUnset:         "-",
+	DefaultUnsetOrNull:   ":-",
+	AssignUnset:          "=",
+	AssignUnsetOrNull:    ":=",
+	ErrorUnset:           "?",
+	ErrorUnsetOrNull:     ":?",
+	AlternateUnset:       "+",
+	AlternateUnsetOrNull: ":+",
+	RemSmallPrefix:       "#",
+	RemLargePrefix:       "##",
+	RemSmallSuffix:       "%",
+	RemLargeSuffix:       "%%",
+	ReplaceFirst:         "/",
+	ReplaceAll:           "//",
+	Substring:            ":",
+}
+
+func (op ParamOp) String() string {
+	if int(op) < len(paramOpNames) {
+		return paramOpNames[op]
+	}
+	return fmt.Sprintf("ParamOp(%d)", int(op))
+}
+
+// ParamExp is a parameter expansion such as $HOME, $? or ${HOME:-/root}.
+// Short is set for the unbraced $name form. Word is the operand of Op,
+// and Repl holds the replacement for ReplaceFirst and ReplaceAll, or the
+// length for Substring.
+type ParamExp struct {
+	Dollar Pos
+	Short  bool
+	Length bool // ${#x}
+	Param  string
+	Op     ParamOp
+	Word   *Word
+	Repl   *Word
+}
+
+func (p *ParamExp) Pos() Pos { return p.Dollar }
+
+// CmdSubst is a command substitution, $(list) or `list`, which expands
+// to what the list writes to standard output.
+type CmdSubst struct {
+	Left, Right Pos
+	Stmts       []*Stmt
+}
+
+func (c *CmdSubst) Pos() Pos { return c.Left }
+
+// ProcSubst is a process substitution, <(list) or, with Out set, >(list).
+// It expands to the path of a pipe from which the list's output can be
+// read or, for >(list), to which its input can be written.
+type ProcSubst struct {
+	OpPos, Rparen Pos
+	Out           bool
+	Stmts         []*Stmt
+}
+
+func (p *ProcSubst) Pos() Pos { return p.OpPos }
+
+// ArithExp is an arithmetic expansion, $((expr)). The expression is
+// expanded as if in double quotes before it is evaluated.
+type ArithExp struct {
+	Left, Right Pos
+	X           *Word
+}
+
+func (a *ArithExp) Pos() Pos { return a.Left }
+
+func (*Lit) wordPartNode()       {}
+func (*SglQuoted) wordPartNode() {}
+func (*DblQuoted) wordPartNode() {}
+func (*ParamExp) wordPartNode()  {}
+func (*CmdSubst) wordPartNode()  {}
+func (*ProcSubst) wordPartNode() {}
+func (*ArithExp) wordPartNode()  {}
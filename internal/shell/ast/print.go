@@ -0,0 +1,266 @@
+// internal/shell/ast/print.go
+package ast
+
+import "strings"
+
+// String formats a node back into shell source. The result is equivalent
+// to the input it was parsed from, though spacing and the bracing of
+// parameter expansions may differ, command substitutions are always
+// written as $(list) and the bodies of here-documents are left out. It is used wherever the shell shows a
+// command to the user, such as in job listings.
+func String(node Node) string {
+	var p printer
+	p.node(node)
+	return p.String()
+}
+
+type printer struct {
+	strings.Builder
+}
+
+func (p *printer) node(node Node) {
+	switch n := node.(type) {
+	case *File:
+		for i, stmt := range n.Stmts {
+			if i > 0 {
+				p.WriteString("\n")
+			}
+			p.stmt(stmt)
+		}
+	case *Stmt:
+		p.stmt(n)
+	case Command:
+		p.command(n)
+	case *Word:
+		p.word(n)
+	case WordPart:
+		p.wordPart(n)
+	case *Redirect:
+		p.redirect(n)
+	case *Assign:
+		p.assign(n)
+	}
+}
+
+func (p *printer) stmt(s *Stmt) {
+	p.command(s.Cmd)
+	for _, r := range s.Redirs {
+		p.WriteString(" ")
+		p.redirect(r)
+	}
+	if s.Background {
+		p.WriteString(" &")
+	}
+}
+
+func (p *printer) command(cmd Command) {
+	switch c := cmd.(type) {
+	case *CallExpr:
+		sep := ""
+		for _, as := range c.Assigns {
+			p.WriteString(sep)
+			p.assign(as)
+			sep = " "
+		}
+		for _, w := range c.Args {
+			p.WriteString(sep)
+			p.word(w)
+			sep = " "
+		}
+	case *Pipeline:
+		if c.Negated {
+			p.WriteString("! ")
+		}
+		for i, stmt := range c.Stmts {
+			if i > 0 {
+				p.WriteString(" | ")
+			}
+			p.stmt(stmt)
+		}
+	case *BinaryCmd:
+		p.stmt(c.X)
+		p.WriteString(" " + c.Op.String() + " ")
+		p.stmt(c.Y)
+	case *Block:
+		p.WriteString("{ ")
+		p.stmtList(c.Stmts)
+		p.WriteString("}")
+	case *FuncDecl:
+		p.WriteString(c.Name.Value + "() ")
+		p.stmt(c.Body)
+	case *IfClause:
+		p.WriteString("if ")
+		p.ifClause(c)
+		p.WriteString("fi")
+	case *WhileClause:
+		if c.Until {
+			p.WriteString("until ")
+		} else {
+			p.WriteString("while ")
+		}
+		p.stmtList(c.Cond)
+		p.WriteString("do ")
+		p.stmtList(c.Do)
+		p.WriteString("done")
+	case *ForClause:
+		p.WriteString("for " + c.Name.Value)
+		if c.InSet {
+			p.WriteString(" in")
+			for _, w := range c.Items {
+				p.WriteString(" ")
+				p.word(w)
+			}
+		}
+		p.WriteString("; do ")
+		p.stmtList(c.Do)
+		p.WriteString("done")
+	case *CaseClause:
+		p.WriteString("case ")
+		p.word(c.Word)
+		p.WriteString(" in ")
+		for _, item := range c.Items {
+			for i, pattern := range item.Patterns {
+				if i > 0 {
+					p.WriteString("|")
+				}
+				p.word(pattern)
+			}
+			p.WriteString(")")
+			for i, stmt := range item.Stmts {
+				if i > 0 && !item.Stmts[i-1].Background {
+					p.WriteString(";")
+				}
+				p.WriteString(" ")
+				p.stmt(stmt)
+			}
+			p.WriteString(";; ")
+		}
+		p.WriteString("esac")
+	}
+}
+
+// ifClause prints an if or elif from its condition onwards.
+func (p *printer) ifClause(c *IfClause) {
+	p.stmtList(c.Cond)
+	p.WriteString("then ")
+	p.stmtList(c.Then)
+	switch {
+	case c.Else == nil:
+	case len(c.Else.Cond) > 0:
+		p.WriteString("elif ")
+		p.ifClause(c.Else)
+	default:
+		p.WriteString("else ")
+		p.stmtList(c.Else.Then)
+	}
+}
+
+// stmtList prints statements on one line, each terminated by ; or &.
+func (p *printer) stmtList(stmts []*Stmt) {
+	for _, stmt := range stmts {
+		p.stmt(stmt)
+		if stmt.Background {
+			p.WriteString(" ")
+		} else {
+			p.WriteString("; ")
+		}
+	}
+}
+
+// inlineStmts prints statements on one line with no trailing ;.
+func (p *printer) inlineStmts(stmts []*Stmt) {
+	for i, stmt := range stmts {
+		switch {
+		case i == 0:
+		case stmts[i-1].Background:
+			p.WriteString(" ")
+		default:
+			p.WriteString("; ")
+		}
+		p.stmt(stmt)
+	}
+}
+
+func (p *printer) assign(as *Assign) {
+	p.WriteString(as.Name + "=")
+	p.word(as.Value)
+}
+
+func (p *printer) redirect(r *Redirect) {
+	if r.N != nil {
+		p.WriteString(r.N.Value)
+	}
+	p.WriteString(r.Op.String())
+	p.word(r.Word)
+}
+
+func (p *printer) word(w *Word) {
+	if w == nil {
+		return
+	}
+	for _, part := range w.Parts {
+		p.wordPart(part)
+	}
+}
+
+func (p *printer) wordPart(part WordPart) {
+	switch x := part.(type) {
+	case *Lit:
+		p.WriteString(x.Value)
+	case *SglQuoted:
+		if x.Dollar {
+			p.WriteString("$")
+		}
+		p.WriteString("'" + x.Value + "'")
+	case *DblQuoted:
+		p.WriteString(`"`)
+		for _, inner := range x.Parts {
+			p.wordPart(inner)
+		}
+		p.WriteString(`"`)
+	case *ParamExp:
+		p.paramExp(x)
+	case *CmdSubst:
+		p.WriteString("$(")
+		p.inlineStmts(x.Stmts)
+		p.WriteString(")")
+	case *ProcSubst:
+		if x.Out {
+			p.WriteString(">(")
+		} else {
+			p.WriteString("<(")
+		}
+		p.inlineStmts(x.Stmts)
+		p.WriteString(")")
+	case *ArithExp:
+		p.WriteString("$((")
+		p.word(x.X)
+		p.WriteString("))")
+	}
+}
+
+func (p *printer) paramExp(x *ParamExp) {
+	if x.Short {
+		p.WriteString("$" + x.Param)
+		return
+	}
+	p.WriteString("${")
+	if x.Length {
+		p.WriteString("#")
+	}
+	p.WriteString(x.Param + x.Op.String())
+	p.word(x.Word)
+	switch x.Op {
+	case ReplaceFirst, ReplaceAll:
+		if x.Repl != nil {
+			p.WriteString("/")
+			p.word(x.Repl)
+		}
+	case Substring:
+		if x.Repl != nil {
+			p.WriteString(":")
+			p.word(x.Repl)
+		}
+	}
+	p.WriteString("}")
+}
@@ -1,17 +1,105 @@
 // internal/shell/builtins/alias.go
 package builtins
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/krzko/gosh/internal/shell/command"
+)
+
+// AliasCommand defines and lists aliases. Aliases are expanded by the
+// parser, so a new alias takes effect from the next line read.
 type AliasCommand struct {
-	Cmd  BuiltinCommand
-	Args []string
+	Aliases map[string]string
+}
+
+func (a *AliasCommand) Execute(ctx *command.Context, args []string) error {
+	if len(args) > 0 && args[0] == "-p" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		names := make([]string, 0, len(a.Aliases))
+		for name := range a.Aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(ctx.Stdout, "alias %s=%s\n", name, quoteValue(a.Aliases[name]))
+		}
+		return nil
+	}
+
+	var err error
+	for _, arg := range args {
+		name, value, define := strings.Cut(arg, "=")
+		if define {
+			if !isAliasName(name) {
+				return fmt.Errorf("`%s': invalid alias name", name)
+			}
+			a.Aliases[name] = value
+			continue
+		}
+		value, ok := a.Aliases[name]
+		if !ok {
+			fmt.Fprintf(ctx.Stderr, "alias: %s: not found\n", name)
+			err = command.ExitStatus(1)
+			continue
+		}
+		fmt.Fprintf(ctx.Stdout, "alias %s=%s\n", name, quoteValue(value))
+	}
+	return err
 }
 
-func (a *AliasCommand) Execute(args []string) error {
-	// Combine the alias arguments with any additional arguments
-	allArgs := append(a.Args, args...)
-	return a.Cmd.Execute(allArgs)
+// isAliasName reports whether name can be used as an alias: it must be a
+// word that needs no quoting.
+func isAliasName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\n|&;<>()$`\\\"'/=")
 }
 
 func (a *AliasCommand) Help() string {
-	return a.Cmd.Help()
+	return `alias: Define or display aliases
+Usage: alias [-p] [name[=value] ...]
+
+alias name=value makes name, used as a command, stand for value, which
+may be any text: a command and arguments, a pipeline or a list. With no
+arguments, lists all aliases. With a name alone, shows that alias.
+
+If value ends in a space, the word after the alias is checked for an
+alias too.`
+}
+
+// UnaliasCommand removes aliases.
+type UnaliasCommand struct {
+	Aliases map[string]string
+}
+
+func (u *UnaliasCommand) Execute(ctx *command.Context, args []string) error {
+	if len(args) > 0 && args[0] == "-a" {
+		clear(u.Aliases)
+		return nil
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: unalias [-a] name [name ...]")
+	}
+
+	var err error
+	for _, name := range args {
+		if _, ok := u.Aliases[name]; !ok {
+			fmt.Fprintf(ctx.Stderr, "unalias: %s: not found\n", name)
+			err = command.ExitStatus(1)
+			continue
+		}
+		delete(u.Aliases, name)
+	}
+	return err
+}
+
+func (u *UnaliasCommand) Help() string {
+	return `unalias: Remove aliases
+Usage: unalias [-a] name [name ...]
+
+Options:
+  -a    remove all aliases`
 }
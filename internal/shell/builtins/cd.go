@@ -2,46 +2,70 @@
 package builtins
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/krzko/gosh/internal/shell/command"
 )
 
 type CdCommand struct{}
 
-func (c *CdCommand) Execute(args []string) error {
+func (c *CdCommand) Execute(ctx *command.Context, args []string) error {
 	var dir string
 	if len(args) == 0 {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return err
+		home, ok := ctx.Vars.Get("HOME")
+		if !ok || home == "" {
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				return err
+			}
+			home = homeDir
 		}
-		dir = homeDir
+		dir = home
 	} else {
 		dir = args[0]
 
-		// Handle ".." special case
-		if dir == ".." {
-			currentDir, err := os.Getwd()
-			if err != nil {
-				return err
+		switch dir {
+		case "..":
+			// Handle ".." special case
+			dir = filepath.Dir(ctx.Dir)
+		case "-":
+			oldpwd, ok := ctx.Vars.Get("OLDPWD")
+			if !ok {
+				return fmt.Errorf("OLDPWD not set")
 			}
-			dir = filepath.Dir(currentDir)
+			dir = oldpwd
+			fmt.Fprintln(ctx.Stdout, dir)
 		}
 	}
 
 	// Clean the path to resolve any .. or . in the middle of paths
 	dir = filepath.Clean(dir)
 
-	// Expand ~ to home directory if it's the first character
-	if len(dir) > 0 && dir[0] == '~' {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return err
-		}
-		dir = filepath.Join(homeDir, dir[1:])
+	// The directory is relative to the shell's working directory rather
+	// than the process's, which subshells do not change.
+	path := builtinPath(ctx, dir)
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("%s: %v", dir, errors.Unwrap(err))
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s: not a directory", dir)
+	}
+	if ctx.Chdir != nil {
+		err = ctx.Chdir(path)
+	} else {
+		err = os.Chdir(path)
+	}
+	if err != nil {
+		return err
 	}
 
-	return os.Chdir(dir)
+	ctx.Vars.Set("OLDPWD", ctx.Dir)
+	ctx.Vars.Set("PWD", path)
+	return nil
 }
 
 func (c *CdCommand) Help() string {
@@ -51,5 +75,6 @@ Usage: cd [directory]
 Special paths:
   ..    Move to parent directory
   ~     Move to home directory
+  -     Move to the previous directory ($OLDPWD)
   .     Stay in current directory`
 }
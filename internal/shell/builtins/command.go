@@ -1,7 +1,6 @@
 // internal/shell/builtins/command.go
 package builtins
 
-type BuiltinCommand interface {
-	Execute(args []string) error
-	Help() string
-}
+import "github.com/krzko/gosh/internal/shell/command"
+
+type BuiltinCommand = command.BuiltinCommand
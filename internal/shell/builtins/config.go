@@ -0,0 +1,34 @@
+// internal/shell/builtins/config.go
+package builtins
+
+import (
+	"fmt"
+
+	"github.com/krzko/gosh/internal/shell/command"
+	"github.com/krzko/gosh/internal/shell/config"
+)
+
+// ConfigCommand prints the effective configuration.
+type ConfigCommand struct {
+	Config *config.Config
+}
+
+func (c *ConfigCommand) Execute(ctx *command.Context, args []string) error {
+	if len(args) == 0 {
+		path := c.Config.Path
+		if path == "" {
+			path = "none, using defaults"
+		}
+		fmt.Fprintf(ctx.Stdout, "# config file: %s\n", path)
+	}
+	return c.Config.Print(ctx.Stdout, args...)
+}
+
+func (c *ConfigCommand) Help() string {
+	return `config: Print the effective configuration
+Usage: config [key...]
+
+Prints each setting, or only the given keys, with its value and where it
+came from: a line of the config file, or the default. The config file is
+$XDG_CONFIG_HOME/gosh/config.toml, or ~/.config/gosh/config.toml.`
+}
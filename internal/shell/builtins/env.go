@@ -0,0 +1,93 @@
+// internal/shell/builtins/env.go
+package builtins
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/krzko/gosh/internal/shell/command"
+)
+
+// EnvCommand prints the environment, or runs a command in a modified one.
+type EnvCommand struct{}
+
+func (c *EnvCommand) Execute(ctx *command.Context, args []string) error {
+	env := append([]string(nil), ctx.Env...)
+
+	for len(args) > 0 {
+		arg := args[0]
+		switch {
+		case arg == "-i":
+			env = nil
+		case arg == "-u":
+			if len(args) < 2 {
+				return fmt.Errorf("option requires an argument -- 'u'")
+			}
+			args = args[1:]
+			env = removeEnv(env, args[0])
+		case strings.Contains(arg, "=") && !strings.HasPrefix(arg, "="):
+			name, _, _ := strings.Cut(arg, "=")
+			env = append(removeEnv(env, name), arg)
+		default:
+			return runWithEnv(ctx, env, args)
+		}
+		args = args[1:]
+	}
+
+	for _, kv := range env {
+		fmt.Fprintln(ctx.Stdout, kv)
+	}
+	return nil
+}
+
+func removeEnv(env []string, name string) []string {
+	result := env[:0:0]
+	for _, kv := range env {
+		if key, _, _ := strings.Cut(kv, "="); key != name {
+			result = append(result, kv)
+		}
+	}
+	return result
+}
+
+// runWithEnv runs an external command with the given environment and the
+// builtin's standard streams.
+func runWithEnv(ctx *command.Context, env []string, args []string) error {
+	cmd := exec.CommandContext(ctx.Context, args[0], args[1:]...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.Env = env
+	cmd.Dir = ctx.Dir
+	cmd.Stdin = ctx.Stdin
+	cmd.Stdout = ctx.Stdout
+	cmd.Stderr = ctx.Stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return command.ExitStatus(128 + int(ws.Signal()))
+		}
+		return command.ExitStatus(exitErr.ExitCode())
+	}
+	var execErr *exec.Error
+	if errors.As(err, &execErr) {
+		fmt.Fprintf(ctx.Stderr, "env: %s: command not found\n", args[0])
+		return command.ExitStatus(127)
+	}
+	return err
+}
+
+func (c *EnvCommand) Help() string {
+	return `env: Print the environment or run a command in a modified environment
+Usage: env [-i] [-u name] [name=value ...] [command [args ...]]
+
+Options:
+  -i    start with an empty environment
+  -u    remove name from the environment`
+}
@@ -2,16 +2,29 @@
 package builtins
 
 import (
-	"os"
+	"fmt"
+	"strconv"
+
+	"github.com/krzko/gosh/internal/shell/command"
 )
 
 type ExitCommand struct{}
 
-func (e *ExitCommand) Execute(args []string) error {
-	os.Exit(0)
-	return nil
+func (e *ExitCommand) Execute(ctx *command.Context, args []string) error {
+	if len(args) == 0 {
+		return &command.ExitRequest{Default: true}
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+
+	status, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("%s: numeric argument required", args[0])
+	}
+	return &command.ExitRequest{Status: status & 0xff}
 }
 
 func (e *ExitCommand) Help() string {
-	return "exit: Exit the shell\nUsage: exit"
+	return "exit: Exit the shell\nUsage: exit [n]\n\nExits with status n, or with the status of the last command if n is omitted."
 }
@@ -0,0 +1,88 @@
+// internal/shell/builtins/export.go
+package builtins
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/krzko/gosh/internal/shell/command"
+	"github.com/krzko/gosh/internal/shell/parser"
+)
+
+// ExportCommand marks variables to be passed to child processes.
+type ExportCommand struct{}
+
+// Execute exports each NAME or NAME=value argument. With no arguments, or
+// with -p, it lists the exported variables.
+func (c *ExportCommand) Execute(ctx *command.Context, args []string) error {
+	unexport := false
+	if len(args) > 0 && (args[0] == "-n" || args[0] == "-p") {
+		unexport = args[0] == "-n"
+		args = args[1:]
+	}
+
+	if len(args) == 0 && !unexport {
+		for _, v := range ctx.Vars.All() {
+			if v.Exported {
+				fmt.Fprintf(ctx.Stdout, "export %s=%s\n", v.Name, quoteValue(v.Value))
+			}
+		}
+		return nil
+	}
+
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !parser.IsName(name) {
+			return fmt.Errorf("`%s': not a valid identifier", arg)
+		}
+		if hasValue {
+			ctx.Vars.Set(name, value)
+		}
+		if unexport {
+			ctx.Vars.Unexport(name)
+		} else {
+			ctx.Vars.Export(name)
+		}
+	}
+	return nil
+}
+
+func (c *ExportCommand) Help() string {
+	return `export: Set the export attribute for shell variables
+Usage: export [-n] [name[=value] ...]
+       export -p
+
+Exported variables are passed in the environment of every command run
+from the shell. With no arguments, lists the exported variables.
+
+Options:
+  -n    remove the export attribute instead of setting it
+  -p    list all exported variables`
+}
+
+// UnsetCommand removes shell variables.
+type UnsetCommand struct{}
+
+func (c *UnsetCommand) Execute(ctx *command.Context, args []string) error {
+	if len(args) > 0 && args[0] == "-v" {
+		args = args[1:]
+	}
+	for _, name := range args {
+		if !parser.IsName(name) {
+			return fmt.Errorf("`%s': not a valid identifier", name)
+		}
+		ctx.Vars.Unset(name)
+	}
+	return nil
+}
+
+func (c *UnsetCommand) Help() string {
+	return `unset: Unset shell variables
+Usage: unset [-v] name ...`
+}
+
+// quoteValue single-quotes a value so that the output of export can be
+// read back by the shell.
+func quoteValue(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
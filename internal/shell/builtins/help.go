@@ -3,12 +3,16 @@ package builtins
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/krzko/gosh/internal/shell/command"
 )
 
 type HelpCommand struct {
 	commands map[string]command.BuiltinCommand
+	// Describe, if set, says what a name resolves to when it is not
+	// just a builtin, such as an alias, function or external command.
+	Describe func(name string) (string, bool)
 }
 
 func NewHelpCommand(cmds map[string]command.BuiltinCommand) *HelpCommand {
@@ -17,20 +21,40 @@ func NewHelpCommand(cmds map[string]command.BuiltinCommand) *HelpCommand {
 	}
 }
 
-func (h *HelpCommand) Execute(args []string) error {
+func (h *HelpCommand) Execute(ctx *command.Context, args []string) error {
 	if len(args) == 0 {
-		fmt.Println("Available commands:")
+		names := make([]string, 0, len(h.commands))
 		for name := range h.commands {
-			fmt.Printf("  %s\n", name)
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Fprintln(ctx.Stdout, "Available commands:")
+		for _, name := range names {
+			fmt.Fprintf(ctx.Stdout, "  %s\n", name)
 		}
 		return nil
 	}
 
+	described := false
+	if h.Describe != nil {
+		if description, ok := h.Describe(args[0]); ok {
+			fmt.Fprintln(ctx.Stdout, description)
+			described = true
+		}
+	}
+
 	cmd, ok := h.commands[args[0]]
 	if !ok {
+		if described {
+			return nil
+		}
 		return fmt.Errorf("unknown command: %s", args[0])
 	}
-	fmt.Println(cmd.Help())
+	if described {
+		fmt.Fprintf(ctx.Stdout, "\nIt hides the builtin %s:\n", args[0])
+	}
+	fmt.Fprintln(ctx.Stdout, cmd.Help())
 	return nil
 }
 
@@ -3,30 +3,110 @@ package builtins
 
 import (
 	"fmt"
+
+	"github.com/krzko/gosh/internal/shell/command"
+	"github.com/krzko/gosh/internal/shell/httpclient"
 )
 
 // HttpCommand represents the 'http' builtin command.
-type HttpCommand struct{}
-
-// Execute performs an HTTP GET request to the specified URL.
-// Usage: http [URL]
-func (c *HttpCommand) Execute(args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("URL required")
-	}
-
-	rawURL := args[0]
-	if err := executeRequest(rawURL, "http"); err != nil {
-		return err
-	}
+type HttpCommand struct {
+	// Config holds the client settings from the config file, or nil
+	// for the defaults.
+	Config *httpclient.Config
+}
 
-	return nil
+// Execute performs the HTTP request described by args.
+func (c *HttpCommand) Execute(ctx *command.Context, args []string) error {
+	return executeRequest(ctx, args, "http", c.Config)
 }
 
 // Help returns the help message for the 'http' command.
 func (c *HttpCommand) Help() string {
-	return `http: Transfer data from a URL using HTTP
-Usage: http [URL]
-
-If the scheme (http:// or https://) is omitted, 'http://' is assumed.`
+	return fmt.Sprintf(httpHelp, "http", "HTTP")
 }
+
+// httpHelp is the help of http and https, given the command name and
+// protocol.
+const httpHelp = `%[1]s: Send an %[2]s request
+Usage: %[1]s [options] [METHOD] URL [ITEM...]
+       %[1]s session list | show NAME | delete NAME...
+
+If the scheme is omitted, '%[1]s://' is assumed, and a URL starting with
+a colon is on localhost, as in :8080/path. The method defaults to GET, or
+POST when there is a request body, and is given in capitals.
+
+Request items:
+  Header:value   request header (Header: with no value removes it)
+  key==value     query string parameter
+  key=value      data field, sent as a JSON string
+  key:=json      data field with a raw JSON value, such as 3 or [1,2]
+  key=@file      data field with the contents of a file
+  key:=@file     data field with the JSON contents of a file
+  key@file       file upload, with --form or --multipart
+
+Data fields are sent as a JSON object unless --form is given.
+
+Options:
+  -d, --data DATA   send DATA as the body, or a file's contents for @file
+                    or standard input for @-
+  -f, --form        send data fields as a form, or as multipart form data
+                    if there are file uploads
+  --multipart       send data fields and files as multipart form data
+  -j, --json        send data fields as JSON (the default)
+  -i, --include     print the response status line and headers
+  -v, --verbose     print the request as well as the response
+  -a, --auth USER:PASSWORD
+                    send credentials, or those read from a file for @file
+  -A, --auth-type basic|bearer
+                    send the credentials given with -a as a bearer token
+                    rather than with basic auth
+  --base URL        send a URL starting with / relative to URL
+  --session NAME    use the named session
+
+Client options:
+  --timeout SECS    give up on a request taking longer than SECS seconds,
+                    or a duration such as 1m30s; 0 means no limit
+  --connect-timeout SECS
+                    give up on connecting after SECS seconds (default 10)
+  --response-timeout SECS
+                    give up on a server that has not answered SECS seconds
+                    after the request was sent (default 30)
+  --retry N         retry up to N times after a connection error or a 5xx
+                    response, waiting 1s and then twice as long each time
+  --proxy URL       send requests through the proxy at URL, rather than
+                    the one in HTTP_PROXY or HTTPS_PROXY; hosts listed in
+                    NO_PROXY are not proxied
+  --insecure        do not check the server's certificate
+  --cacert FILE     check the server's certificate against the PEM file
+  --cert FILE       send a client certificate, with its key in FILE too
+                    unless it is given with --key
+  --key FILE        the client certificate's key
+  --max-redirects N follow up to N redirects (default 10); with 0, none
+  --http1.1         use only HTTP/1.1
+  --http2           use only HTTP/2, which needs https
+  --timing[=json]   print how long looking up the host, connecting, the
+                    TLS handshake, waiting for the first byte and the
+                    transfer took, as a table or JSON, instead of the body
+
+The defaults for these, and the wait before the first retry, are set in
+the [http] section of the config file.
+
+Sessions:
+  A session remembers the base URL, headers and auth given with it, and
+  the cookies servers set, for the requests that name it later. Sessions
+  are kept in the sessions directory of the gosh config directory.
+
+Downloads:
+  -o, --output FILE  save the response body to FILE
+  --download         save the response body to a file named by the
+                     Content-Disposition header or else the URL
+  -c, --continue     resume an interrupted download to the -o FILE
+  --sha256 SUM       save the download only if its SHA-256 checksum is SUM
+
+A download is written to FILE.part and renamed when complete, with a
+progress bar on a terminal. An interrupted download leaves FILE.part to
+be resumed with -c.
+
+JSON responses are indented and colored on a terminal, and binary ones
+are not shown there. A response with a status other than 2xx is not
+saved, and sets exit status 3, 4 or 5 for 3xx, 4xx and 5xx.`
@@ -0,0 +1,291 @@
+// internal/shell/builtins/http_download.go
+package builtins
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/krzko/gosh/internal/shell/command"
+	"github.com/krzko/gosh/internal/utils/formatter"
+)
+
+// progressInterval is how often the progress bar is redrawn.
+const progressInterval = 100 * time.Millisecond
+
+// saving reports whether the response body is saved to a file rather than
+// written to standard output.
+func (a *httpArgs) saving() bool {
+	return a.output != "" || a.download
+}
+
+// partName is the file a download is written to until it is complete and
+// verified, and from which --continue resumes it.
+func partName(name string) string {
+	return name + ".part"
+}
+
+// resumeFrom asks for the rest of an interrupted download when resuming,
+// returning the size of what was already downloaded.
+func (a *httpArgs) resumeFrom(ctx *command.Context, req *http.Request) (int64, error) {
+	if !a.resume {
+		return 0, nil
+	}
+	info, err := os.Stat(partName(builtinPath(ctx, a.output)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if info.Size() > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", info.Size()))
+	}
+	return info.Size(), nil
+}
+
+// contentRange parses a Content-Range header, returning the first byte
+// position and the complete length, or -1 for either if it is not given.
+func contentRange(header string) (start, total int64, ok bool) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, 0, false
+	}
+	r, length, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, false
+	}
+	start, total = -1, -1
+	var err error
+	if length != "*" {
+		if total, err = strconv.ParseInt(length, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	if r != "*" {
+		first, _, _ := strings.Cut(r, "-")
+		if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, total, true
+}
+
+// alreadyComplete reports whether the server refused a resumed download
+// because there is nothing left to download.
+func alreadyComplete(resp *http.Response, offset int64) bool {
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable || offset == 0 {
+		return false
+	}
+	_, total, ok := contentRange(resp.Header.Get("Content-Range"))
+	return ok && total == offset
+}
+
+// downloadName picks a file name for --download from the response's
+// Content-Disposition header or else from its URL.
+func downloadName(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := safeName(params["filename"]); name != "" {
+			return name
+		}
+	}
+	if name := safeName(resp.Request.URL.Path); name != "" {
+		return name
+	}
+	return "index"
+}
+
+// safeName returns the last element of a path given by the server, or ""
+// if there is none, so that a download cannot be saved outside the
+// current directory.
+func safeName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == ".." || name == "/" || strings.HasPrefix(name, ".") {
+		return ""
+	}
+	return name
+}
+
+// uniqueName adds a number to name, as in name.1, if the file exists.
+func uniqueName(name string) string {
+	unique := name
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(unique); errors.Is(err, fs.ErrNotExist) {
+			return unique
+		}
+		unique = fmt.Sprintf("%s.%d", name, n)
+	}
+}
+
+// saveResponse saves a download to its file. It is written to a .part
+// file that is renamed once the download is complete and its checksum,
+// if given, verified. An interrupted download leaves the .part file for
+// --continue; offset is its size, when the request asked for the rest.
+func (a *httpArgs) saveResponse(ctx *command.Context, resp *http.Response, offset int64) error {
+	name := a.output
+	if name == "" {
+		name = uniqueName(builtinPath(ctx, downloadName(resp)))
+	}
+	name = builtinPath(ctx, name)
+	part := partName(name)
+
+	complete := alreadyComplete(resp, offset)
+	total := int64(-1)
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	switch {
+	case complete:
+		total = offset
+		flag = os.O_WRONLY | os.O_APPEND
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		start, length, ok := contentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			return fmt.Errorf("cannot resume: server sent range %q, want bytes from %d", resp.Header.Get("Content-Range"), offset)
+		}
+		total = length
+		flag = os.O_WRONLY | os.O_APPEND
+	default:
+		// The server sent the whole file, so start again.
+		offset = 0
+		if resp.ContentLength >= 0 {
+			total = resp.ContentLength
+		}
+	}
+
+	f, err := os.OpenFile(part, flag, 0644)
+	if err != nil {
+		return err
+	}
+	var body io.Reader = resp.Body
+	if complete {
+		body = strings.NewReader("")
+	}
+	var bar *progressBar
+	if isTerminal(ctx.Stderr) {
+		bar = &progressBar{w: ctx.Stderr, label: path.Base(name), done: offset, total: total, offset: offset, start: time.Now()}
+		body = io.TeeReader(body, bar)
+	}
+	n, err := io.Copy(f, body)
+	if bar != nil {
+		bar.finish()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && total >= 0 && offset+n < total {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return fmt.Errorf("download of %s incomplete, resume it with -c: %w", name, err)
+	}
+
+	if a.sha256 != "" {
+		sum, err := fileSHA256(part)
+		if err != nil {
+			return err
+		}
+		if sum != a.sha256 {
+			os.Remove(part)
+			return fmt.Errorf("%s: SHA-256 checksum mismatch: got %s, want %s", name, sum, a.sha256)
+		}
+	}
+	return os.Rename(part, name)
+}
+
+func fileSHA256(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// progressBar draws the progress of a download on a terminal as the
+// downloaded data is written to it.
+type progressBar struct {
+	w     io.Writer
+	label string
+
+	// done and total are the bytes downloaded so far and in all, or -1
+	// if the size is unknown. offset is what a resumed download had
+	// already, which does not count toward the rate.
+	done, total, offset int64
+
+	start, drawn time.Time
+}
+
+func (p *progressBar) Write(b []byte) (int, error) {
+	p.done += int64(len(b))
+	if time.Since(p.drawn) >= progressInterval {
+		p.draw()
+	}
+	return len(b), nil
+}
+
+func (p *progressBar) draw() {
+	p.drawn = time.Now()
+	rate := int64(0)
+	if elapsed := p.drawn.Sub(p.start).Seconds(); elapsed > 0 {
+		rate = int64(float64(p.done-p.offset) / elapsed)
+	}
+	fmt.Fprintf(p.w, "\r%s", progressLine(p.label, p.done, p.total, rate, terminalWidth(p.w)))
+}
+
+func (p *progressBar) finish() {
+	p.draw()
+	fmt.Fprintln(p.w)
+}
+
+// progressLine formats a progress bar line of exactly width-1 columns, so
+// that the terminal does not wrap it:
+//
+//	name  45% [========>          ] 1.2M/2.7M 3.4M/s
+//
+// The label and then the bar are left out when there is no room.
+func progressLine(label string, done, total, rate int64, width int) string {
+	stats := fmt.Sprintf(" %s %s/s", formatter.FormatSize(done), formatter.FormatSize(rate))
+	percent := ""
+	if total > 0 {
+		stats = fmt.Sprintf(" %s/%s %s/s", formatter.FormatSize(done), formatter.FormatSize(total), formatter.FormatSize(rate))
+		percent = fmt.Sprintf(" %3d%%", min(done*100/total, 100))
+	}
+
+	const minBar = 10
+	line := label + percent
+	barWidth := width - 1 - len([]rune(line)) - len(stats) - 3
+	if barWidth < minBar {
+		line = strings.TrimPrefix(percent, " ")
+		barWidth = width - 1 - len(line) - len(stats) - 3
+	}
+	if total > 0 && barWidth >= minBar {
+		filled := int(int64(barWidth) * min(done, total) / total)
+		bar := strings.Repeat("=", filled)
+		if filled < barWidth {
+			bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+		}
+		line += " [" + bar + "]"
+	} else if total <= 0 {
+		line = label
+	}
+	line += stats
+
+	runes := []rune(line)
+	if len(runes) > width-1 {
+		runes = runes[:max(width-1, 0)]
+	}
+	return string(runes) + strings.Repeat(" ", max(width-1-len(runes), 0))
+}
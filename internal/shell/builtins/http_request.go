@@ -0,0 +1,543 @@
+// internal/shell/builtins/http_request.go
+package builtins
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/krzko/gosh/internal/shell/command"
+	"github.com/krzko/gosh/internal/shell/httpclient"
+)
+
+// httpArgs is an invocation of http or https, parsed from its arguments:
+//
+//	http [options] [METHOD] URL [ITEM...]
+type httpArgs struct {
+	method string
+	url    string
+	items  []httpItem
+
+	// data is the request body given with -d, or @file to read it from
+	// a file, or @- from standard input.
+	data    string
+	hasData bool
+
+	form      bool
+	multipart bool
+	include   bool
+	verbose   bool
+
+	// output is the file to save the response body to, given with -o.
+	// With --download it is taken from the response instead.
+	output   string
+	download bool
+	resume   bool
+	sha256   string
+
+	// session is the name of the session given with --session, and base
+	// the URL that a URL starting with / is relative to.
+	session string
+	base    string
+	auth    *httpAuth
+
+	// client is the client's settings: the defaults from the config file
+	// with any given in the arguments.
+	client httpclient.Config
+
+	// timing is "table" or "json" to show how long each phase of the
+	// request took, as with --timing.
+	timing string
+}
+
+// httpItem is a request item, such as Header:value or key=value.
+type httpItem struct {
+	key, sep, value string
+}
+
+// itemSeps are the separators of request items. Where several start at
+// the same place, the first listed is taken.
+var itemSeps = []string{":=@", "=@", "==", ":=", "=", "@", ":"}
+
+// parseItem splits a request item at its first separator. A backslash
+// escapes a separator character in the key.
+func parseItem(arg string) (httpItem, bool) {
+	var key strings.Builder
+	for i := 0; i < len(arg); i++ {
+		if arg[i] == '\\' && i+1 < len(arg) {
+			i++
+			key.WriteByte(arg[i])
+			continue
+		}
+		for _, sep := range itemSeps {
+			if strings.HasPrefix(arg[i:], sep) {
+				if key.Len() == 0 {
+					return httpItem{}, false
+				}
+				return httpItem{key: key.String(), sep: sep, value: arg[i+len(sep):]}, true
+			}
+		}
+		key.WriteByte(arg[i])
+	}
+	return httpItem{}, false
+}
+
+func parseHTTPArgs(args []string, cfg httpclient.Config) (*httpArgs, error) {
+	a := &httpArgs{client: cfg}
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch name {
+		case "-d", "--data", "-o", "--output", "--sha256", "-a", "--auth", "-A", "--auth-type", "--session", "--base",
+			"--timeout", "--connect-timeout", "--response-timeout", "--retry", "--proxy", "--cacert", "--cert", "--key", "--max-redirects":
+			if !hasValue {
+				if i+1 == len(args) {
+					return nil, fmt.Errorf("%s: argument required", name)
+				}
+				i++
+				value = args[i]
+			}
+		}
+		switch name {
+		case "-d", "--data":
+			a.data, a.hasData = value, true
+		case "-o", "--output":
+			a.output = value
+		case "--download":
+			a.download = true
+		case "-c", "--continue":
+			a.resume = true
+		case "--sha256":
+			sum, err := hex.DecodeString(value)
+			if err != nil || len(sum) != sha256.Size {
+				return nil, fmt.Errorf("--sha256: %q is not a SHA-256 checksum", value)
+			}
+			a.sha256 = strings.ToLower(value)
+		case "-a", "--auth":
+			if a.auth == nil {
+				a.auth = &httpAuth{Type: "basic"}
+			}
+			a.auth.Credentials = value
+			if file, ok := strings.CutPrefix(value, "@"); ok {
+				a.auth.Credentials, a.auth.File = "", file
+			}
+		case "-A", "--auth-type":
+			if value != "basic" && value != "bearer" {
+				return nil, fmt.Errorf("%s: unknown auth type %q (want basic or bearer)", name, value)
+			}
+			if a.auth == nil {
+				a.auth = &httpAuth{}
+			}
+			a.auth.Type = value
+		case "--session":
+			a.session = value
+		case "--base":
+			a.base = value
+		case "--timeout", "--connect-timeout", "--response-timeout":
+			d, err := parseTimeout(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			switch name {
+			case "--timeout":
+				a.client.Timeout = d
+			case "--connect-timeout":
+				a.client.ConnectTimeout = d
+			default:
+				a.client.ResponseTimeout = d
+			}
+		case "--retry", "--max-redirects":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%s: invalid count %q", name, value)
+			}
+			if name == "--retry" {
+				a.client.Retries = n
+			} else {
+				a.client.MaxRedirects = n
+			}
+		case "--proxy":
+			a.client.Proxy = value
+		case "--insecure":
+			a.client.Insecure = true
+		case "--cacert":
+			a.client.CACert = value
+		case "--cert":
+			a.client.Cert = value
+		case "--key":
+			a.client.Key = value
+		case "--http1.1":
+			a.client.HTTPVersion = "1.1"
+		case "--http2":
+			a.client.HTTPVersion = "2"
+		case "--timing":
+			if !hasValue {
+				value = "table"
+			}
+			if value != "table" && value != "json" {
+				return nil, fmt.Errorf("--timing: unknown format %q (want table or json)", value)
+			}
+			a.timing = value
+		case "-f", "--form":
+			a.form = true
+		case "--multipart":
+			a.multipart = true
+		case "-j", "--json":
+			a.form, a.multipart = false, false
+		case "-i", "--include":
+			a.include = true
+		case "-v", "--verbose":
+			a.verbose = true
+		default:
+			return nil, fmt.Errorf("unknown option: %s", arg)
+		}
+	}
+
+	// The method may be left out; it is recognized by being in capitals.
+	if len(positional) >= 2 && isMethod(positional[0]) {
+		a.method, positional = positional[0], positional[1:]
+	}
+	if len(positional) == 0 {
+		return nil, fmt.Errorf("URL required")
+	}
+	if a.auth != nil && a.auth.Credentials == "" && a.auth.File == "" {
+		return nil, fmt.Errorf("--auth-type needs the credentials given with -a")
+	}
+	if a.resume && a.output == "" {
+		return nil, fmt.Errorf("--continue needs the file to resume given with -o")
+	}
+	if a.sha256 != "" && !a.saving() {
+		return nil, fmt.Errorf("--sha256 needs -o or --download")
+	}
+	a.url = positional[0]
+	for _, arg := range positional[1:] {
+		item, ok := parseItem(arg)
+		if !ok {
+			return nil, fmt.Errorf("%s: not a request item (want Header:value, key==value, key=value, key:=json or key@file)", arg)
+		}
+		a.items = append(a.items, item)
+	}
+	return a, nil
+}
+
+// parseTimeout parses a timeout given as a duration such as 1m30s or as
+// a number of seconds.
+func parseTimeout(s string) (time.Duration, error) {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil && secs >= 0 {
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid timeout %q", s)
+	}
+	return d, nil
+}
+
+func isMethod(s string) bool {
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// requestURL parses the URL, adding the scheme if it is left out. A URL
+// starting with a colon is on localhost, so that :8080/path is short for
+// localhost:8080/path and :/path for localhost/path.
+func requestURL(rawURL, defaultScheme string) (*url.URL, error) {
+	if !strings.Contains(rawURL, "://") {
+		if rest, ok := strings.CutPrefix(rawURL, ":"); ok {
+			if rest == "" || rest[0] == '/' {
+				rawURL = "localhost" + rest
+			} else {
+				rawURL = "localhost:" + rest
+			}
+		}
+		rawURL = defaultScheme + "://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q: no host", rawURL)
+	}
+	return u, nil
+}
+
+// request builds the HTTP request, returning its body as well so that it
+// can be shown with -v. The session, if not nil, gives the base URL,
+// headers and auth not given in the arguments.
+func (a *httpArgs) request(ctx *command.Context, defaultScheme string, sess *session) (*http.Request, []byte, error) {
+	rawURL, base, auth := a.url, a.base, a.auth
+	header := make(http.Header)
+	if sess != nil {
+		for key, values := range sess.Headers {
+			header[key] = slices.Clone(values)
+		}
+		if base == "" {
+			base = sess.Base
+		}
+		if auth == nil {
+			auth = sess.Auth
+		}
+	}
+	if base != "" && strings.HasPrefix(rawURL, "/") {
+		rawURL = strings.TrimSuffix(base, "/") + rawURL
+	}
+	u, err := requestURL(rawURL, defaultScheme)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// given holds the headers set or removed by the arguments, which
+	// replace those of the session and must not be replaced by the
+	// defaults. A header given more than once is sent with each value.
+	given := make(map[string]bool)
+	query := u.Query()
+	var fields, files []httpItem
+	for _, item := range a.items {
+		switch item.sep {
+		case ":":
+			key := http.CanonicalHeaderKey(item.key)
+			switch {
+			case item.value == "":
+				header.Del(key)
+			case given[key]:
+				header.Add(key, item.value)
+			default:
+				header.Set(key, item.value)
+			}
+			given[key] = true
+		case "==":
+			query.Add(item.key, item.value)
+		case "@":
+			files = append(files, item)
+		default:
+			fields = append(fields, item)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	var body []byte
+	var contentType string
+	switch {
+	case a.hasData:
+		if len(fields) > 0 || len(files) > 0 {
+			return nil, nil, fmt.Errorf("request items cannot be combined with -d")
+		}
+		body, err = readData(ctx, a.data)
+		if a.form {
+			contentType = "application/x-www-form-urlencoded"
+		} else if json.Valid(body) {
+			contentType = "application/json"
+		}
+	case a.multipart || a.form && len(files) > 0:
+		body, contentType, err = multipartBody(ctx, fields, files)
+	case len(files) > 0:
+		return nil, nil, fmt.Errorf("file fields such as %s@%s need --form or --multipart", files[0].key, files[0].value)
+	case a.form && len(fields) > 0:
+		body, err = formBody(ctx, fields)
+		contentType = "application/x-www-form-urlencoded; charset=utf-8"
+	case len(fields) > 0:
+		body, err = jsonBody(ctx, fields)
+		contentType = "application/json"
+		setDefault(header, given, "Accept", "application/json, */*;q=0.5")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if contentType != "" {
+		setDefault(header, given, "Content-Type", contentType)
+	}
+	setDefault(header, given, "User-Agent", "gosh/"+Version)
+	if auth != nil && header.Get("Authorization") == "" {
+		value, err := auth.header()
+		if err != nil {
+			return nil, nil, err
+		}
+		header.Set("Authorization", value)
+	}
+
+	method := a.method
+	if method == "" {
+		method = http.MethodGet
+		if body != nil {
+			method = http.MethodPost
+		}
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx.Context, method, u.String(), reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid request: %w", err)
+	}
+	req.Header = header
+	if host := header.Get("Host"); host != "" {
+		req.Host = host
+	}
+	return req, body, nil
+}
+
+// setDefault sets a header the request has unless the session or the
+// arguments, as recorded in given, have set or removed it.
+func setDefault(header http.Header, given map[string]bool, key, value string) {
+	if _, ok := header[key]; !ok && !given[key] {
+		header.Set(key, value)
+	}
+}
+
+// header returns the Authorization header for the credentials.
+func (h *httpAuth) header() (string, error) {
+	credentials := h.Credentials
+	if h.File != "" {
+		data, err := os.ReadFile(h.File)
+		if err != nil {
+			return "", fmt.Errorf("failed to read credentials: %w", err)
+		}
+		credentials = strings.TrimSpace(string(data))
+	}
+	if h.Type == "bearer" {
+		return "Bearer " + credentials, nil
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials)), nil
+}
+
+// readData returns the body given with -d: the text itself, or the
+// contents of a file for @file, or of standard input for @-.
+func readData(ctx *command.Context, data string) ([]byte, error) {
+	name, ok := strings.CutPrefix(data, "@")
+	if !ok {
+		return []byte(data), nil
+	}
+	if name == "-" {
+		return io.ReadAll(ctx.Stdin)
+	}
+	return os.ReadFile(builtinPath(ctx, name))
+}
+
+// builtinPath resolves name against the builtin's working directory.
+func builtinPath(ctx *command.Context, name string) string {
+	if filepath.IsAbs(name) || ctx.Dir == "" {
+		return name
+	}
+	return filepath.Join(ctx.Dir, name)
+}
+
+// fieldValue returns the value of a data field: the text after = or :=,
+// or the contents of the file named after =@ or :=@.
+func fieldValue(ctx *command.Context, item httpItem) (string, error) {
+	if item.sep != "=@" && item.sep != ":=@" {
+		return item.value, nil
+	}
+	data, err := os.ReadFile(builtinPath(ctx, item.value))
+	return string(data), err
+}
+
+// jsonBody encodes the data fields as a JSON object, keeping their order.
+func jsonBody(ctx *command.Context, fields []httpItem) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, item := range fields {
+		value, err := fieldValue(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		var raw []byte
+		if item.sep == ":=" || item.sep == ":=@" {
+			raw = []byte(strings.TrimSpace(value))
+			if !json.Valid(raw) {
+				return nil, fmt.Errorf("%s%s%s: invalid JSON", item.key, item.sep, item.value)
+			}
+		} else {
+			raw, _ = json.Marshal(value)
+		}
+		key, _ := json.Marshal(item.key)
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.Write(key)
+		buf.WriteString(": ")
+		buf.Write(raw)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// formBody encodes the data fields as an URL-encoded form.
+func formBody(ctx *command.Context, fields []httpItem) ([]byte, error) {
+	form := make(url.Values)
+	for _, item := range fields {
+		if item.sep == ":=" || item.sep == ":=@" {
+			return nil, fmt.Errorf("%s%s%s: JSON fields cannot be sent in a form", item.key, item.sep, item.value)
+		}
+		value, err := fieldValue(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		form.Add(item.key, value)
+	}
+	return []byte(form.Encode()), nil
+}
+
+// multipartBody encodes the data fields and files as multipart form data,
+// returning the body and its content type.
+func multipartBody(ctx *command.Context, fields, files []httpItem) ([]byte, string, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, item := range fields {
+		if item.sep == ":=" || item.sep == ":=@" {
+			return nil, "", fmt.Errorf("%s%s%s: JSON fields cannot be sent in a form", item.key, item.sep, item.value)
+		}
+		value, err := fieldValue(ctx, item)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := mw.WriteField(item.key, value); err != nil {
+			return nil, "", err
+		}
+	}
+	for _, item := range files {
+		data, err := os.ReadFile(builtinPath(ctx, item.value))
+		if err != nil {
+			return nil, "", err
+		}
+		part, err := mw.CreateFormFile(item.key, filepath.Base(item.value))
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(data); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), mw.FormDataContentType(), nil
+}
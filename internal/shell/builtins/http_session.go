@@ -0,0 +1,301 @@
+// internal/shell/builtins/http_session.go
+package builtins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krzko/gosh/internal/shell/command"
+	"github.com/krzko/gosh/internal/shell/config"
+	"github.com/krzko/gosh/internal/utils/color"
+	"github.com/krzko/gosh/internal/utils/formatter"
+)
+
+// session is what a named session given with --session remembers between
+// requests: a base URL, default headers, auth and cookies. It is stored
+// as JSON in the sessions directory under the config directory.
+type session struct {
+	Base         string          `json:"base,omitempty"`
+	Headers      http.Header     `json:"headers,omitempty"`
+	Auth         *httpAuth       `json:"auth,omitempty"`
+	SavedCookies []sessionCookie `json:"cookies,omitempty"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// httpAuth is the credentials given with -a: user:password for basic
+// auth or a bearer token, or the file to read them from for @file.
+type httpAuth struct {
+	Type        string `json:"type"`
+	Credentials string `json:"credentials,omitempty"`
+	File        string `json:"file,omitempty"`
+}
+
+// sessionCookie is a cookie set by a server, with the domain and path
+// that it is sent to.
+type sessionCookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Domain   string     `json:"domain"`
+	HostOnly bool       `json:"host_only,omitempty"`
+	Path     string     `json:"path"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+	HTTPOnly bool       `json:"http_only,omitempty"`
+}
+
+// unsavedHeaders are request headers that describe a single request, and
+// so are not remembered by a session.
+var unsavedHeaders = []string{"Content-Type", "Content-Length", "Host"}
+
+func sessionDir() (string, error) {
+	dir := config.Dir()
+	if dir == "" {
+		return "", fmt.Errorf("no config directory to keep sessions in")
+	}
+	return filepath.Join(dir, "sessions"), nil
+}
+
+func sessionPath(name string) (string, error) {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid session name: %q", name)
+	}
+	dir, err := sessionDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// loadSession reads the named session, or returns a new one if it does
+// not exist yet.
+func loadSession(name string) (*session, error) {
+	file, err := sessionPath(name)
+	if err != nil {
+		return nil, err
+	}
+	s := &session{path: file}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("session %s: %w", name, err)
+	}
+	return s, nil
+}
+
+// save writes the session, readable only by the user as it may hold
+// credentials.
+func (s *session) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	cookies := s.SavedCookies[:0]
+	for _, c := range s.SavedCookies {
+		if c.Expires == nil || c.Expires.After(now) {
+			cookies = append(cookies, c)
+		}
+	}
+	s.SavedCookies = cookies
+
+	data, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, append(data, '\n'), 0600)
+}
+
+// update remembers the headers and auth given for a request.
+func (s *session) update(a *httpArgs) {
+	for _, item := range a.items {
+		if item.sep != ":" || isUnsavedHeader(item.key) {
+			continue
+		}
+		if s.Headers == nil {
+			s.Headers = make(http.Header)
+		}
+		if item.value == "" {
+			s.Headers.Del(item.key)
+		} else {
+			s.Headers.Set(item.key, item.value)
+		}
+	}
+	if a.auth != nil {
+		s.Auth = a.auth
+	}
+	if a.base != "" {
+		s.Base = a.base
+	}
+}
+
+func isUnsavedHeader(key string) bool {
+	key = http.CanonicalHeaderKey(key)
+	for _, h := range unsavedHeaders {
+		if key == h {
+			return true
+		}
+	}
+	return strings.HasPrefix(key, "If-")
+}
+
+// SetCookies stores the cookies set by a response from u, so that the
+// session is an http.CookieJar.
+func (s *session) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+	for _, c := range cookies {
+		sc := sessionCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   strings.ToLower(strings.TrimPrefix(c.Domain, ".")),
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HTTPOnly: c.HttpOnly,
+		}
+		if sc.Domain == "" {
+			sc.Domain, sc.HostOnly = host, true
+		} else if !domainMatch(host, sc.Domain) {
+			continue
+		}
+		if !strings.HasPrefix(sc.Path, "/") {
+			sc.Path = defaultCookiePath(u.Path)
+		}
+		switch {
+		case c.MaxAge < 0:
+			sc.Expires = &now
+		case c.MaxAge > 0:
+			expires := now.Add(time.Duration(c.MaxAge) * time.Second)
+			sc.Expires = &expires
+		case !c.Expires.IsZero():
+			expires := c.Expires
+			sc.Expires = &expires
+		}
+
+		replaced := false
+		for i, old := range s.SavedCookies {
+			if old.Name == sc.Name && old.Domain == sc.Domain && old.Path == sc.Path {
+				s.SavedCookies[i], replaced = sc, true
+				break
+			}
+		}
+		if !replaced {
+			s.SavedCookies = append(s.SavedCookies, sc)
+		}
+	}
+}
+
+// Cookies returns the unexpired cookies to send in a request to u.
+func (s *session) Cookies(u *url.URL) []*http.Cookie {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+	var cookies []*http.Cookie
+	for _, c := range s.SavedCookies {
+		if c.Expires != nil && !c.Expires.After(now) ||
+			c.Secure && u.Scheme != "https" ||
+			!pathMatch(u.Path, c.Path) {
+			continue
+		}
+		if c.HostOnly && host != c.Domain || !c.HostOnly && !domainMatch(host, c.Domain) {
+			continue
+		}
+		cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return cookies
+}
+
+func domainMatch(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func pathMatch(reqPath, cookiePath string) bool {
+	if reqPath == "" {
+		reqPath = "/"
+	}
+	return reqPath == cookiePath || strings.HasPrefix(reqPath, strings.TrimSuffix(cookiePath, "/")+"/")
+}
+
+// defaultCookiePath is the path of a cookie set without one: the
+// directory of the request path.
+func defaultCookiePath(reqPath string) string {
+	if !strings.HasPrefix(reqPath, "/") || strings.Count(reqPath, "/") == 1 {
+		return "/"
+	}
+	return path.Dir(reqPath)
+}
+
+// sessionCommand runs http session: list, show or delete sessions.
+func sessionCommand(ctx *command.Context, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		dir, err := sessionDir()
+		if err != nil {
+			return err
+		}
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return err
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			fmt.Fprintln(ctx.Stdout, strings.TrimSuffix(filepath.Base(file), ".json"))
+		}
+		return nil
+	case args[0] == "show" && len(args) == 2:
+		file, err := sessionPath(args[1])
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("no session named %s", args[1])
+		}
+		if err != nil {
+			return err
+		}
+		var theme *color.Theme
+		if isTerminal(ctx.Stdout) {
+			theme = color.Current()
+		}
+		return formatter.WriteJSON(ctx.Stdout, data, theme)
+	case args[0] == "delete" && len(args) >= 2:
+		for _, name := range args[1:] {
+			file, err := sessionPath(name)
+			if err != nil {
+				return err
+			}
+			if err := os.Remove(file); errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("no session named %s", name)
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("usage: session list | show NAME | delete NAME...")
+}
@@ -0,0 +1,604 @@
+// internal/shell/builtins/http_test.go
+package builtins
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krzko/gosh/internal/shell/command"
+	"github.com/krzko/gosh/internal/shell/httpclient"
+)
+
+// echoServer replies to each request with a description of it.
+func echoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Header().Set("X-Method", r.Method)
+		io.WriteString(w, r.Method+" "+r.URL.RequestURI()+"\n")
+		io.WriteString(w, "Content-Type: "+r.Header.Get("Content-Type")+"\n")
+		io.WriteString(w, "X-Token: "+r.Header.Get("X-Token")+"\n")
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func runHTTP(t *testing.T, dir string, args ...string) (string, string, error) {
+	t.Helper()
+	return runHTTPConfig(t, nil, dir, args...)
+}
+
+// runHTTPConfig runs http with the client settings cfg, as if from the
+// config file.
+func runHTTPConfig(t *testing.T, cfg *httpclient.Config, dir string, args ...string) (string, string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	ctx := &command.Context{
+		Context: context.Background(),
+		Stdin:   strings.NewReader("from stdin"),
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+		Dir:     dir,
+	}
+	err := (&HttpCommand{Config: cfg}).Execute(ctx, args)
+	return stdout.String(), stderr.String(), err
+}
+
+func TestHTTPRequests(t *testing.T) {
+	srv := echoServer(t)
+	host := strings.TrimPrefix(srv.URL, "http://")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "body.txt"), []byte("file body"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{
+			args: []string{host + "/get", "q==a b", "X-Token:secret"},
+			want: []string{"GET /get?q=a+b\n", "X-Token: secret\n"},
+		},
+		{
+			args: []string{host, "name=gosh", "n:=3", `tags:=["a","b"]`},
+			want: []string{"POST /\n", "Content-Type: application/json\n", `{"name": "gosh", "n": 3, "tags": ["a","b"]}`},
+		},
+		{
+			args: []string{"PUT", srv.URL + "/x", "-f", "a=1", "b=@body.txt"},
+			want: []string{"PUT /x\n", "Content-Type: application/x-www-form-urlencoded; charset=utf-8\n", "a=1&b=file+body"},
+		},
+		{
+			args: []string{"--multipart", host, "up@body.txt"},
+			want: []string{"Content-Type: multipart/form-data; boundary=", `filename="body.txt"`, "file body"},
+		},
+		{
+			args: []string{"-d", "@body.txt", host},
+			want: []string{"POST /\n", "file body"},
+		},
+		{
+			args: []string{"PATCH", host, "--data=@-"},
+			want: []string{"PATCH /\n", "from stdin"},
+		},
+		{
+			args: []string{"-i", host + "/head"},
+			want: []string{"HTTP/1.1 200 OK\n", "X-Method: GET\n", "\n\nGET /head\n"},
+		},
+		{
+			args: []string{"-v", host, "a=b"},
+			want: []string{"POST / HTTP/1.1\nHost: " + host + "\n", "Content-Length: 10\n", "\n{\"a\": \"b\"}\n\nHTTP/1.1 200 OK\n"},
+		},
+	}
+	for _, tt := range tests {
+		stdout, _, err := runHTTP(t, dir, tt.args...)
+		if err != nil {
+			t.Errorf("http %q error = %v", tt.args, err)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(stdout, want) {
+				t.Errorf("http %q output = %q, want it to contain %q", tt.args, stdout, want)
+			}
+		}
+	}
+}
+
+// headerServer replies to each request with every value it was sent for
+// the headers named in keys.
+func headerServer(t *testing.T, keys ...string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, key := range keys {
+			io.WriteString(w, key+": "+strings.Join(r.Header.Values(key), " | ")+"\n")
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHTTPHeaders(t *testing.T) {
+	srv := headerServer(t, "User-Agent", "Accept", "Content-Type")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{
+			args: []string{srv.URL, "a=b"},
+			want: "User-Agent: gosh/" + Version + "\nAccept: application/json, */*;q=0.5\nContent-Type: application/json\n",
+		},
+		{
+			args: []string{"POST", srv.URL, "User-Agent:me", "Accept:text/plain", "Content-Type:text/x-json", "a=b"},
+			want: "User-Agent: me\nAccept: text/plain\nContent-Type: text/x-json\n",
+		},
+		{
+			args: []string{srv.URL, "Accept:", "a=b"},
+			want: "User-Agent: gosh/" + Version + "\nAccept: \nContent-Type: application/json\n",
+		},
+	}
+	for _, tt := range tests {
+		stdout, _, err := runHTTP(t, "", tt.args...)
+		if err != nil {
+			t.Errorf("http %q error = %v", tt.args, err)
+			continue
+		}
+		if stdout != tt.want {
+			t.Errorf("http %q output = %q, want %q", tt.args, stdout, tt.want)
+		}
+	}
+}
+
+func TestHTTPErrors(t *testing.T) {
+	srv := echoServer(t)
+
+	stdout, stderr, err := runHTTP(t, "", srv.URL+"/missing")
+	var status command.ExitStatus
+	if !errors.As(err, &status) || status != 4 {
+		t.Errorf("http 404 error = %v, want exit status 4", err)
+	}
+	if !strings.Contains(stdout, "GET /missing") || !strings.Contains(stderr, "404 Not Found") {
+		t.Errorf("http 404 stdout = %q, stderr = %q", stdout, stderr)
+	}
+
+	for _, args := range [][]string{
+		{},
+		{srv.URL, "notanitem"},
+		{srv.URL, "file@x"},
+		{"-d", "x", srv.URL, "a=b"},
+		{"-f", srv.URL, "n:=1"},
+		{"-c", srv.URL},
+		{"--sha256", "abc", "-o", "x", srv.URL},
+		{"--sha256", strings.Repeat("0", 64), srv.URL},
+		{srv.URL, "bad:=json{"},
+		{"--nope", srv.URL},
+	} {
+		if _, _, err := runHTTP(t, "", args...); err == nil {
+			t.Errorf("http %q error = nil, want an error", args)
+		}
+	}
+}
+
+// fileServer serves content at /report with a Content-Disposition header
+// naming it report.bin, and at any other path without one. It records
+// the Range header of each request.
+func fileServer(t *testing.T, content []byte) (*httptest.Server, *[]string) {
+	t.Helper()
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path == "/report" {
+			w.Header().Set("Content-Disposition", `attachment; filename="../report.bin"`)
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &ranges
+}
+
+func TestHTTPDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef\x00"), 4096)
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+	srv, ranges := fileServer(t, content)
+	dir := t.TempDir()
+
+	check := func(name string) {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, content) {
+			t.Errorf("%s has %d bytes, want the %d downloaded", name, len(data), len(content))
+		}
+		if _, err := os.Stat(filepath.Join(dir, name+".part")); err == nil {
+			t.Errorf("%s.part left behind", name)
+		}
+	}
+
+	if _, _, err := runHTTP(t, dir, "--download", srv.URL+"/report"); err != nil {
+		t.Fatal(err)
+	}
+	check("report.bin")
+
+	for _, name := range []string{"data.bin", "data.bin.1"} {
+		if _, _, err := runHTTP(t, dir, "--download", srv.URL+"/files/data.bin"); err != nil {
+			t.Fatal(err)
+		}
+		check(name)
+	}
+
+	stdout, _, err := runHTTP(t, dir, "-o", "out", "--sha256", checksum, srv.URL+"/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("out")
+	if stdout != "" {
+		t.Errorf("download wrote %q to stdout", stdout)
+	}
+
+	// An interrupted download is resumed from where it stopped.
+	if err := os.WriteFile(filepath.Join(dir, "resumed.part"), content[:1000], 0644); err != nil {
+		t.Fatal(err)
+	}
+	*ranges = nil
+	if _, _, err := runHTTP(t, dir, "-c", "-o", "resumed", "--sha256", checksum, srv.URL+"/x"); err != nil {
+		t.Fatal(err)
+	}
+	check("resumed")
+	if len(*ranges) != 1 || (*ranges)[0] != "bytes=1000-" {
+		t.Errorf("resume requested ranges %q, want bytes=1000-", *ranges)
+	}
+
+	// One that had finished downloading is just renamed.
+	if err := os.WriteFile(filepath.Join(dir, "complete.part"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := runHTTP(t, dir, "-c", "-o", "complete", srv.URL+"/x"); err != nil {
+		t.Fatal(err)
+	}
+	check("complete")
+
+	_, _, err = runHTTP(t, dir, "-o", "bad", "--sha256", strings.Repeat("0", 64), srv.URL+"/x")
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("download with the wrong checksum error = %v", err)
+	}
+	_, _, err = runHTTP(t, dir, "-o", "missing", srv.URL+"/missing")
+	var status command.ExitStatus
+	if !errors.As(err, &status) || status != 4 {
+		t.Errorf("download of 404 error = %v, want exit status 4", err)
+	}
+	for _, name := range []string{"bad", "bad.part", "missing", "missing.part"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("failed download left %s", name)
+		}
+	}
+}
+
+func TestProgressLine(t *testing.T) {
+	tests := []struct {
+		label             string
+		done, total, rate int64
+		width             int
+		want              string
+	}{
+		{"f.iso", 512, 1024, 2048, 40, "f.iso  50% [=====>    ] 512/1.0K 2.0K/s"},
+		{"f.iso", 1024, 1024, 2048, 50, "f.iso 100% [===================] 1.0K/1.0K 2.0K/s"},
+		{"a-very-long-file-name.iso", 512, 1024, 0, 40, " 50% [=========>         ] 512/1.0K 0/s"},
+		{"f.iso", 2048, -1, 1024, 30, "f.iso 2.0K 1.0K/s            "},
+	}
+	for _, tt := range tests {
+		got := progressLine(tt.label, tt.done, tt.total, tt.rate, tt.width)
+		if got != tt.want {
+			t.Errorf("progressLine(%q, %d, %d, %d, %d) = %q, want %q", tt.label, tt.done, tt.total, tt.rate, tt.width, got, tt.want)
+		}
+		if len(got) != tt.width-1 {
+			t.Errorf("progressLine(%q, ...) is %d columns, want %d", tt.label, len(got), tt.width-1)
+		}
+	}
+}
+
+func TestRequestURL(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"example.com/x", "https://example.com/x"},
+		{"http://example.com", "http://example.com"},
+		{":8080/path?q=1", "https://localhost:8080/path?q=1"},
+		{":/path", "https://localhost/path"},
+		{":", "https://localhost"},
+	}
+	for _, tt := range tests {
+		u, err := requestURL(tt.url, "https")
+		if err != nil {
+			t.Errorf("requestURL(%q) error = %v", tt.url, err)
+			continue
+		}
+		if u.String() != tt.want {
+			t.Errorf("requestURL(%q) = %q, want %q", tt.url, u, tt.want)
+		}
+	}
+	if _, err := requestURL("/path", "https"); err == nil {
+		t.Errorf("requestURL(%q) error = nil, want no host", "/path")
+	}
+}
+
+func TestHTTPSession(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "abc", Path: "/"})
+		case "/logout":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Path: "/", MaxAge: -1})
+		}
+		for _, key := range []string{"Cookie", "Authorization", "X-Env"} {
+			io.WriteString(w, key+": "+r.Header.Get(key)+"\n")
+		}
+	}))
+	t.Cleanup(srv.Close)
+	dir := t.TempDir()
+	token := filepath.Join(dir, "token")
+
+	steps := []struct {
+		args []string
+		want string
+	}{
+		{
+			args: []string{"--session=dev", "--base", srv.URL, "-a", "user:pw", "/login", "X-Env:dev"},
+			want: "Cookie: \nAuthorization: Basic dXNlcjpwdw==\nX-Env: dev\n",
+		},
+		{
+			args: []string{"--session", "dev", "/whoami"},
+			want: "Cookie: sid=abc\nAuthorization: Basic dXNlcjpwdw==\nX-Env: dev\n",
+		},
+		{
+			args: []string{"--session=dev", "-A", "bearer", "-a", "@token", "/whoami", "X-Env:"},
+			want: "Cookie: sid=abc\nAuthorization: Bearer one\nX-Env: \n",
+		},
+		{
+			args: []string{"--session=dev", "/logout"},
+			want: "Cookie: sid=abc\nAuthorization: Bearer two\nX-Env: \n",
+		},
+		{
+			args: []string{"--session=dev", "/whoami"},
+			want: "Cookie: \nAuthorization: Bearer two\nX-Env: \n",
+		},
+		{
+			args: []string{"--session=dev", "/whoami", "Authorization:Basic other"},
+			want: "Cookie: \nAuthorization: Basic other\nX-Env: \n",
+		},
+	}
+	for i, step := range steps {
+		// The token file is read again for each request.
+		tok := "one"
+		if i > 2 {
+			tok = "two"
+		}
+		if err := os.WriteFile(token, []byte(tok+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		stdout, _, err := runHTTP(t, dir, step.args...)
+		if err != nil {
+			t.Fatalf("http %q error = %v", step.args, err)
+		}
+		if stdout != step.want {
+			t.Errorf("http %q output = %q, want %q", step.args, stdout, step.want)
+		}
+	}
+
+	stdout, _, err := runHTTP(t, dir, "session", "list")
+	if err != nil || stdout != "dev\n" {
+		t.Errorf("http session list = %q, %v, want dev", stdout, err)
+	}
+	stdout, _, err = runHTTP(t, dir, "session", "show", "dev")
+	if err != nil || !strings.Contains(stdout, `"base": "`+srv.URL+`"`) || !strings.Contains(stdout, `"type": "bearer"`) {
+		t.Errorf("http session show dev = %q, %v", stdout, err)
+	}
+	if _, _, err := runHTTP(t, dir, "session", "delete", "dev"); err != nil {
+		t.Errorf("http session delete dev error = %v", err)
+	}
+	if stdout, _, err := runHTTP(t, dir, "session"); err != nil || stdout != "" {
+		t.Errorf("http session after delete = %q, %v, want none", stdout, err)
+	}
+	for _, args := range [][]string{
+		{"session", "delete", "dev"},
+		{"session", "show", "../dev"},
+		{"session", "rename", "dev"},
+		{"--session=a/b", srv.URL},
+		{"-A", "bearer", srv.URL},
+		{"-A", "digest", "-a", "x", srv.URL},
+	} {
+		if _, _, err := runHTTP(t, dir, args...); err == nil {
+			t.Errorf("http %q error = nil, want an error", args)
+		}
+	}
+}
+
+func TestHTTPSessionHeaders(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	srv := headerServer(t, "Authorization", "X-Env")
+
+	steps := []struct {
+		args []string
+		want string
+	}{
+		{
+			args: []string{"--session=dev", srv.URL, "Authorization:Bearer T1", "X-Env:dev"},
+			want: "Authorization: Bearer T1\nX-Env: dev\n",
+		},
+		{
+			args: []string{"--session=dev", srv.URL, "Authorization:Bearer T2"},
+			want: "Authorization: Bearer T2\nX-Env: dev\n",
+		},
+		{
+			args: []string{"--session=dev", srv.URL, "X-Env:a", "X-Env:b"},
+			want: "Authorization: Bearer T2\nX-Env: a | b\n",
+		},
+		{
+			args: []string{"--session=dev", srv.URL, "Authorization:"},
+			want: "Authorization: \nX-Env: b\n",
+		},
+	}
+	for _, step := range steps {
+		stdout, _, err := runHTTP(t, "", step.args...)
+		if err != nil {
+			t.Fatalf("http %q error = %v", step.args, err)
+		}
+		if stdout != step.want {
+			t.Errorf("http %q output = %q, want %q", step.args, stdout, step.want)
+		}
+	}
+}
+
+func TestHTTPClientOptions(t *testing.T) {
+	var failures int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky":
+			body, _ := io.ReadAll(r.Body)
+			if failures > 0 {
+				failures--
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprintf(w, "ok %s", body)
+		case "/slow":
+			time.Sleep(500 * time.Millisecond)
+		case "/moved":
+			http.Redirect(w, r, "/", http.StatusFound)
+		default:
+			fmt.Fprintf(w, "%s %s", r.Method, r.URL)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	cfg := httpclient.DefaultConfig()
+	cfg.RetryBackoff = time.Millisecond
+
+	failures = 2
+	stdout, stderr, err := runHTTPConfig(t, &cfg, "", "--retry", "2", srv.URL+"/flaky", "a=b")
+	if err != nil || stdout != `ok {"a": "b"}` {
+		t.Errorf("http --retry 2 = %q, %v", stdout, err)
+	}
+	if want := "http: HTTP/1.1 503 Service Unavailable; retrying in 2ms (2/2)\n"; !strings.Contains(stderr, want) {
+		t.Errorf("http --retry 2 stderr = %q, want it to contain %q", stderr, want)
+	}
+
+	failures = 2
+	cfg.Retries = 1
+	_, _, err = runHTTPConfig(t, &cfg, "", srv.URL+"/flaky")
+	var status command.ExitStatus
+	if !errors.As(err, &status) || status != 5 {
+		t.Errorf("http with one retry from the config error = %v, want exit status 5", err)
+	}
+	cfg.Retries = 0
+
+	// Connection errors are retried too.
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	_, stderr, err = runHTTPConfig(t, &cfg, "", "--retry=1", closed.URL)
+	if err == nil || !strings.Contains(stderr, "(1/1)") {
+		t.Errorf("http --retry=1 to a closed server = %v, stderr %q", err, stderr)
+	}
+
+	if _, _, err := runHTTPConfig(t, &cfg, "", "--timeout", "0.05", srv.URL+"/slow"); err == nil {
+		t.Errorf("http --timeout 0.05 error = nil, want a timeout")
+	}
+
+	stdout, _, err = runHTTPConfig(t, &cfg, "", "--max-redirects=0", srv.URL+"/moved")
+	if !errors.As(err, &status) || status != 3 || !strings.Contains(stdout, "Found") {
+		t.Errorf("http --max-redirects=0 = %q, %v, want the redirect with exit status 3", stdout, err)
+	}
+
+	// The test server answers as a proxy would, with the absolute URL.
+	stdout, _, err = runHTTPConfig(t, &cfg, "", "--proxy", srv.URL, "http://example.invalid/x")
+	if err != nil || stdout != "GET http://example.invalid/x" {
+		t.Errorf("http --proxy = %q, %v", stdout, err)
+	}
+
+	for _, args := range [][]string{
+		{"--timeout", "soon", srv.URL},
+		{"--retry", "-1", srv.URL},
+		{"--cacert", "missing.pem", srv.URL},
+		{"--http2", srv.URL},
+	} {
+		if _, _, err := runHTTPConfig(t, &cfg, "", args...); err == nil {
+			t.Errorf("http %q error = nil, want an error", args)
+		}
+	}
+}
+
+func TestHTTPTiming(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "body")
+	}))
+	t.Cleanup(srv.Close)
+
+	stdout, _, err := runHTTP(t, "", "--insecure", "--timing=json", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Phases []struct {
+			Name       string  `json:"name"`
+			StartMS    float64 `json:"start_ms"`
+			DurationMS float64 `json:"duration_ms"`
+		} `json:"phases"`
+		TotalMS float64 `json:"total_ms"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("--timing=json output %q: %v", stdout, err)
+	}
+	var names []string
+	end := 0.0
+	for _, p := range report.Phases {
+		names = append(names, p.Name)
+		end = max(end, p.StartMS+p.DurationMS)
+	}
+	// An IP address is not looked up.
+	if got := strings.Join(names, " "); got != "connect tls ttfb transfer" {
+		t.Errorf("--timing=json phases = %q, want connect tls ttfb transfer", got)
+	}
+	if end > report.TotalMS+0.001 {
+		t.Errorf("--timing=json phases end at %vms, after the total %vms", end, report.TotalMS)
+	}
+
+	srv.URL = strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	stdout, _, err = runHTTP(t, "", "--insecure", "--timing", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"PHASE", "DNS", "TLS", "TTFB", "Transfer", "Total", "█"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("--timing output = %q, want it to contain %q", stdout, want)
+		}
+	}
+	if strings.Contains(stdout, "body") {
+		t.Errorf("--timing output = %q, want no body", stdout)
+	}
+
+	if _, _, err := runHTTP(t, "", "--timing=xml", srv.URL); err == nil {
+		t.Errorf("--timing=xml error = nil, want an error")
+	}
+}
@@ -0,0 +1,135 @@
+// internal/shell/builtins/http_timing.go
+package builtins
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krzko/gosh/internal/utils/color"
+	"github.com/krzko/gosh/internal/utils/formatter"
+)
+
+// timing records when each phase of a request happened, for --timing.
+// Only the last round trip is kept, so after a retry or a redirect it is
+// the one that gave the response.
+type timing struct {
+	mu sync.Mutex
+	m  timingMarks
+}
+
+type timingMarks struct {
+	start                     time.Time
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	gotConn, firstByte        time.Time
+	reused                    bool
+}
+
+// trace returns the hooks that record the times.
+func (t *timing) trace() *httptrace.ClientTrace {
+	m := &t.m
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.m = timingMarks{start: time.Now()}
+		},
+		DNSStart:     func(httptrace.DNSStartInfo) { t.mark(&m.dnsStart) },
+		DNSDone:      func(httptrace.DNSDoneInfo) { t.mark(&m.dnsDone) },
+		ConnectStart: func(_, _ string) { t.mark(&m.connectStart) },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.mark(&m.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() { t.mark(&m.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.mark(&m.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mark(&m.gotConn)
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			m.reused = info.Reused
+		},
+		GotFirstResponseByte: func() { t.mark(&m.firstByte) },
+	}
+}
+
+// mark records the time in one of the marks, unless it is already set,
+// as when connecting tries several addresses.
+func (t *timing) mark(field *time.Time) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if field.IsZero() {
+		*field = now
+	}
+}
+
+// phases returns the phases of the request, given that the body was read
+// by done. Those that did not happen, such as connecting on a reused
+// connection, are left out.
+func (t *timing) phases(done time.Time) ([]formatter.Phase, time.Duration) {
+	t.mu.Lock()
+	m := t.m
+	t.mu.Unlock()
+	var phases []formatter.Phase
+	add := func(name string, from, to time.Time) {
+		if !from.IsZero() && !to.IsZero() {
+			phases = append(phases, formatter.Phase{Name: name, Start: from.Sub(m.start), Duration: to.Sub(from)})
+		}
+	}
+	add("DNS", m.dnsStart, m.dnsDone)
+	add("Connect", m.connectStart, m.connectDone)
+	add("TLS", m.tlsStart, m.tlsDone)
+	add("TTFB", m.gotConn, m.firstByte)
+	add("Transfer", m.firstByte, done)
+	return phases, done.Sub(m.start)
+}
+
+// write writes the phases of the request as a table, or as JSON if
+// format is "json".
+func (t *timing) write(w io.Writer, format string, done time.Time) error {
+	phases, total := t.phases(done)
+	if format != "json" {
+		return formatter.New().FormatWaterfall(w, phases, total)
+	}
+
+	type jsonPhase struct {
+		Name       string  `json:"name"`
+		StartMS    float64 `json:"start_ms"`
+		DurationMS float64 `json:"duration_ms"`
+	}
+	report := struct {
+		Phases  []jsonPhase `json:"phases"`
+		TotalMS float64     `json:"total_ms"`
+		Reused  bool        `json:"reused_connection"`
+	}{TotalMS: milliseconds(total), Reused: t.reused()}
+	for _, p := range phases {
+		report.Phases = append(report.Phases, jsonPhase{strings.ToLower(p.Name), milliseconds(p.Start), milliseconds(p.Duration)})
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	var theme *color.Theme
+	if isTerminal(w) {
+		theme = color.Current()
+	}
+	return formatter.WriteJSON(w, data, theme)
+}
+
+func (t *timing) reused() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.m.reused
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -2,42 +2,261 @@
 package builtins
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
-	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/krzko/gosh/internal/shell/command"
+	"github.com/krzko/gosh/internal/shell/httpclient"
+	"github.com/krzko/gosh/internal/utils/color"
+	"github.com/krzko/gosh/internal/utils/formatter"
 )
 
-// executeRequest performs an HTTP GET request to the specified URL and writes the response to stdout.
-func executeRequest(rawURL string, defaultScheme string) error {
-	parsedURL, err := url.Parse(rawURL)
+// executeRequest runs http or https with args, using defaultScheme for a
+// URL given without one and the client settings in cfg, or the defaults
+// if it is nil. The request is abandoned when ctx is cancelled.
+// A response with a status other than 2xx is still written out, though
+// not saved to a file, and gives exit status 3, 4 or 5 for 3xx, 4xx and
+// 5xx.
+func executeRequest(ctx *command.Context, args []string, defaultScheme string, cfg *httpclient.Config) error {
+	if len(args) > 0 && args[0] == "session" {
+		return sessionCommand(ctx, args[1:])
+	}
+	if cfg == nil {
+		defaults := httpclient.DefaultConfig()
+		cfg = &defaults
+	}
+	a, err := parseHTTPArgs(args, *cfg)
 	if err != nil {
-		return fmt.Errorf("invalid URL: %w", err)
+		return err
 	}
-
-	// If scheme is missing, prepend the default scheme
-	if parsedURL.Scheme == "" {
-		parsedURL, err = url.Parse(defaultScheme + "://" + rawURL)
-		if err != nil {
-			return fmt.Errorf("invalid URL after adding default scheme: %w", err)
+	if a.auth != nil && a.auth.File != "" {
+		a.auth.File = builtinPath(ctx, a.auth.File)
+	}
+	for _, file := range []*string{&a.client.CACert, &a.client.Cert, &a.client.Key} {
+		if *file != "" {
+			*file = builtinPath(ctx, *file)
 		}
 	}
+	client, err := httpclient.New(a.client, ctx.Env)
+	if err != nil {
+		return err
+	}
+	var sess *session
+	if a.session != "" {
+		if sess, err = loadSession(a.session); err != nil {
+			return err
+		}
+		client.Jar = sess
+	}
+	req, body, err := a.request(ctx, defaultScheme, sess)
+	if err != nil {
+		return err
+	}
+	offset, err := a.resumeFrom(ctx, req)
+	if err != nil {
+		return err
+	}
+	var tm *timing
+	if a.timing != "" {
+		tm = &timing{}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), tm.trace()))
+	}
+	if a.verbose {
+		writeRequest(ctx.Stdout, req, body)
+	}
 
-	resp, err := http.Get(parsedURL.String())
+	resp, err := send(ctx, client, req, a.client, defaultScheme)
 	if err != nil {
-		return fmt.Errorf("failed to perform GET request: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
+	if sess != nil {
+		sess.update(a)
+		if err := sess.save(); err != nil {
+			return fmt.Errorf("failed to save session: %w", err)
+		}
+	}
 
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("HTTP error: %s", resp.Status)
+	if a.include || a.verbose {
+		writeResponseHead(ctx.Stdout, resp)
+	}
+	switch {
+	case a.saving() && (resp.StatusCode < 300 || alreadyComplete(resp, offset)):
+		if err := a.saveResponse(ctx, resp, offset); err != nil {
+			return err
+		}
+		if tm != nil {
+			return tm.write(ctx.Stdout, a.timing, time.Now())
+		}
+		return nil
+	case a.saving():
+	case tm != nil:
+		// The timing is shown instead of the body, which is read to
+		// time its transfer.
+		if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+			return fmt.Errorf("failed to read response body: %w", err)
+		}
+	default:
+		if err := writeResponseBody(ctx, resp); err != nil {
+			return fmt.Errorf("failed to write response body: %w", err)
+		}
+	}
+	if tm != nil {
+		if err := tm.write(ctx.Stdout, a.timing, time.Now()); err != nil {
+			return err
+		}
 	}
 
-	_, err = io.Copy(os.Stdout, resp.Body)
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	if !a.include && !a.verbose {
+		fmt.Fprintf(ctx.Stderr, "%s: warning: %s %s\n", defaultScheme, resp.Proto, resp.Status)
+	}
+	return command.ExitStatus(resp.StatusCode / 100)
+}
+
+// send sends req, retrying it as cfg allows after a connection error or
+// a 5xx response, with exponential backoff.
+func send(ctx *command.Context, client *http.Client, req *http.Request, cfg httpclient.Config, scheme string) (*http.Response, error) {
+	backoff := cfg.RetryBackoff
+	for retry := 1; ; retry++ {
+		resp, err := client.Do(req)
+		if retry > cfg.Retries || ctx.Context.Err() != nil {
+			return resp, err
+		}
+		var reason string
+		switch {
+		case err != nil && !isConnectionError(err):
+			return nil, err
+		case err != nil:
+			reason = err.Error()
+		case resp.StatusCode >= 500:
+			reason = resp.Proto + " " + resp.Status
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		default:
+			return resp, nil
+		}
+
+		fmt.Fprintf(ctx.Stderr, "%s: %s; retrying in %s (%d/%d)\n", scheme, reason, backoff, retry, cfg.Retries)
+		select {
+		case <-ctx.Context.Done():
+			return nil, ctx.Context.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+
+		req = req.Clone(req.Context())
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// isConnectionError reports whether err is a failure to connect or of
+// the connection, rather than one that retrying cannot fix such as an
+// untrusted certificate.
+func isConnectionError(err error) bool {
+	var opErr *net.OpError
+	var urlErr *url.Error
+	return errors.As(err, &opErr) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &urlErr) && urlErr.Timeout()
+}
+
+// writeRequest writes the request line, headers and body, as -v shows
+// them.
+func writeRequest(w io.Writer, req *http.Request, body []byte) {
+	fmt.Fprintf(w, "%s %s %s\n", req.Method, req.URL.RequestURI(), req.Proto)
+	fmt.Fprintf(w, "Host: %s\n", req.Host)
+	header := req.Header.Clone()
+	if body != nil {
+		header.Set("Content-Length", fmt.Sprint(len(body)))
+	}
+	writeHeader(w, header)
+	fmt.Fprintln(w)
+	if body != nil {
+		if utf8.Valid(body) {
+			fmt.Fprintf(w, "%s\n\n", body)
+		} else {
+			fmt.Fprintf(w, "[%d bytes of binary data]\n\n", len(body))
+		}
+	}
+}
+
+// writeResponseHead writes the status line and headers, as -i shows
+// them.
+func writeResponseHead(w io.Writer, resp *http.Response) {
+	fmt.Fprintf(w, "%s %s\n", resp.Proto, resp.Status)
+	writeHeader(w, resp.Header)
+	fmt.Fprintln(w)
+}
+
+func writeHeader(w io.Writer, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range header[key] {
+			fmt.Fprintf(w, "%s: %s\n", key, value)
+		}
+	}
+}
+
+// writeResponseBody copies the body to standard output. JSON going to a
+// terminal is indented and colored, and binary data is not written to a
+// terminal at all.
+func writeResponseBody(ctx *command.Context, resp *http.Response) error {
+	if !isTerminal(ctx.Stdout) {
+		_, err := io.Copy(ctx.Stdout, resp.Body)
+		return err
+	}
+	if !isJSON(resp.Header.Get("Content-Type")) {
+		body := bufio.NewReader(resp.Body)
+		if isBinary(body) {
+			fmt.Fprintln(ctx.Stdout, "[binary data not shown; save it with -o FILE or --download]")
+			return nil
+		}
+		_, err := io.Copy(ctx.Stdout, body)
+		return err
+	}
+	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to write response body: %w", err)
+		return err
 	}
+	return formatter.WriteJSON(ctx.Stdout, data, color.Current())
+}
 
-	return nil
+// isBinary reports whether the start of the data in r has a NUL byte,
+// which text does not.
+func isBinary(r *bufio.Reader) bool {
+	start, _ := r.Peek(512)
+	return bytes.IndexByte(start, 0) >= 0
+}
+
+// isJSON reports whether contentType is application/json or another JSON
+// type such as application/problem+json.
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
 }
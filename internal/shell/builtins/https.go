@@ -3,30 +3,24 @@ package builtins
 
 import (
 	"fmt"
+
+	"github.com/krzko/gosh/internal/shell/command"
+	"github.com/krzko/gosh/internal/shell/httpclient"
 )
 
 // HttpsCommand represents the 'https' builtin command.
-type HttpsCommand struct{}
-
-// Execute performs an HTTPS GET request to the specified URL.
-// Usage: https [URL]
-func (c *HttpsCommand) Execute(args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("URL required")
-	}
-
-	rawURL := args[0]
-	if err := executeRequest(rawURL, "https"); err != nil {
-		return err
-	}
+type HttpsCommand struct {
+	// Config holds the client settings from the config file, or nil
+	// for the defaults.
+	Config *httpclient.Config
+}
 
-	return nil
+// Execute performs the HTTPS request described by args.
+func (c *HttpsCommand) Execute(ctx *command.Context, args []string) error {
+	return executeRequest(ctx, args, "https", c.Config)
 }
 
 // Help returns the help message for the 'https' command.
 func (c *HttpsCommand) Help() string {
-	return `https: Transfer data from a URL using HTTPS
-Usage: https [URL]
-
-If the scheme (https://) is omitted, 'https://' is assumed.`
+	return fmt.Sprintf(httpHelp, "https", "HTTPS")
 }
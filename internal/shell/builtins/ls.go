@@ -2,10 +2,14 @@
 package builtins
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
+	"github.com/krzko/gosh/internal/shell/command"
+	"github.com/krzko/gosh/internal/utils/color"
 	"github.com/krzko/gosh/internal/utils/formatter"
 	"golang.org/x/term"
 )
@@ -94,7 +98,39 @@ func (l *LsCommand) readDirectory(path string) ([]formatter.FileInfo, error) {
 	return fileInfos, nil
 }
 
-func (l *LsCommand) Execute(args []string) error {
+// fileInfo describes a single file operand the way readDirectory
+// describes directory entries.
+func (l *LsCommand) fileInfo(path string, info os.FileInfo) formatter.FileInfo {
+	owner, group := getOwnerGroup(info.Sys())
+	return formatter.FileInfo{
+		Name:        path,
+		Size:        info.Size(),
+		Mode:        info.Mode(),
+		ModTime:     info.ModTime(),
+		IsDir:       info.IsDir(),
+		Owner:       owner,
+		Group:       group,
+		Permissions: info.Mode().String(),
+	}
+}
+
+func (l *LsCommand) list(ctx *command.Context, entries []formatter.FileInfo, opts LsOptions) error {
+	// Output that is not going to a terminal, such as into a pipe or a
+	// command substitution, is left uncolored, one name per line.
+	if !isTerminal(ctx.Stdout) {
+		plain := formatter.NewWithTheme(color.PlainTheme())
+		if opts.Long {
+			return plain.FormatLongList(ctx.Stdout, entries)
+		}
+		return plain.FormatSimpleList(ctx.Stdout, entries)
+	}
+	if opts.Long {
+		return l.formatter.FormatLongList(ctx.Stdout, entries)
+	}
+	return l.formatter.FormatCompact(ctx.Stdout, entries, terminalWidth(ctx.Stdout))
+}
+
+func (l *LsCommand) Execute(ctx *command.Context, args []string) error {
 	paths, opts, err := l.parseOptions(args)
 	if err != nil {
 		return err
@@ -104,42 +140,81 @@ func (l *LsCommand) Execute(args []string) error {
 		paths = []string{"."}
 	}
 
-	for i, path := range paths {
-		if i > 0 {
-			fmt.Printf("\n%s:\n", path)
+	// As in other ls implementations, file operands are listed together
+	// first, followed by the contents of each directory operand.
+	var files []formatter.FileInfo
+	var dirs []string
+	failed := false
+	for _, path := range paths {
+		info, err := os.Stat(builtinPath(ctx, path))
+		if err != nil {
+			fmt.Fprintf(ctx.Stderr, "ls: cannot access %s: %v\n", path, errors.Unwrap(err))
+			failed = true
+			continue
 		}
+		if info.IsDir() {
+			dirs = append(dirs, path)
+		} else {
+			files = append(files, l.fileInfo(path, info))
+		}
+	}
 
-		entries, err := l.readDirectory(path)
-		if err != nil {
+	if len(files) > 0 {
+		if err := l.list(ctx, files, opts); err != nil {
 			return err
 		}
+	}
 
-		if !opts.All {
-			entries = filterHiddenFiles(entries)
+	for i, path := range dirs {
+		if i > 0 || len(files) > 0 {
+			fmt.Fprintln(ctx.Stdout)
 		}
-
-		if opts.Long {
-			err = l.formatter.FormatLongList(entries)
-		} else {
-			width, _, err := term.GetSize(int(os.Stdout.Fd()))
-			if err != nil {
-				width = 80 // fallback width
-			}
-			err = l.formatter.FormatCompact(entries, width)
+		if len(paths) > 1 {
+			fmt.Fprintf(ctx.Stdout, "%s:\n", path)
 		}
 
+		entries, err := l.readDirectory(builtinPath(ctx, path))
 		if err != nil {
+			fmt.Fprintf(ctx.Stderr, "ls: cannot open directory %s: %v\n", path, errors.Unwrap(err))
+			failed = true
+			continue
+		}
+
+		if !opts.All {
+			entries = filterHiddenFiles(entries)
+		}
+
+		if err := l.list(ctx, entries, opts); err != nil {
 			return err
 		}
 	}
 
+	if failed {
+		return command.ExitStatus(2)
+	}
 	return nil
 }
 
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// terminalWidth returns the width of the terminal w writes to, or 80 if it
+// is not a terminal.
+func terminalWidth(w io.Writer) int {
+	if f, ok := w.(*os.File); ok {
+		if width, _, err := term.GetSize(int(f.Fd())); err == nil && width > 0 {
+			return width
+		}
+	}
+	return 80 // fallback width
+}
+
 func (l *LsCommand) Help() string {
 	return `ls - list directory contents
 
-Usage: ls [OPTIONS] [PATH]
+Usage: ls [OPTIONS] [PATH...]
 
 Options:
     -l    use long listing format
@@ -3,17 +3,14 @@ package builtins
 
 import (
 	"fmt"
-	"os"
+
+	"github.com/krzko/gosh/internal/shell/command"
 )
 
 type PwdCommand struct{}
 
-func (p *PwdCommand) Execute(args []string) error {
-	dir, err := os.Getwd()
-	if err != nil {
-		return err
-	}
-	fmt.Println(dir)
+func (p *PwdCommand) Execute(ctx *command.Context, args []string) error {
+	fmt.Fprintln(ctx.Stdout, ctx.Dir)
 	return nil
 }
 
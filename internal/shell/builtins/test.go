@@ -0,0 +1,364 @@
+// internal/shell/builtins/test.go
+package builtins
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/krzko/gosh/internal/shell/command"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// TestCommand evaluates conditional expressions, as test or, with Bracket
+// set, as [ with a closing ].
+type TestCommand struct {
+	Bracket bool
+}
+
+func (c *TestCommand) Execute(ctx *command.Context, args []string) error {
+	name := "test"
+	if c.Bracket {
+		name = "["
+		if len(args) == 0 || args[len(args)-1] != "]" {
+			fmt.Fprintf(ctx.Stderr, "[: missing `]'\n")
+			return command.ExitStatus(2)
+		}
+		args = args[:len(args)-1]
+	}
+
+	ok, err := (&testEval{ctx: ctx}).eval(args)
+	if err != nil {
+		fmt.Fprintf(ctx.Stderr, "%s: %v\n", name, err)
+		return command.ExitStatus(2)
+	}
+	if !ok {
+		return command.ExitStatus(1)
+	}
+	return nil
+}
+
+func (c *TestCommand) Help() string {
+	usage := "test expression"
+	if c.Bracket {
+		usage = "[ expression ]"
+	}
+	return `test: Evaluate a conditional expression
+Usage: ` + usage + `
+
+Exits with status 0 if the expression is true, 1 if it is false and 2
+if it is malformed.
+
+Files:
+  -e file    file exists         -f file    regular file
+  -d file    directory           -L file    symbolic link (also -h)
+  -r file    readable            -w file    writable
+  -x file    executable          -s file    not empty
+  -b file    block device        -c file    character device
+  -p file    named pipe          -S file    socket
+  -t fd      fd is a terminal
+  a -nt b    a is newer than b   a -ot b    a is older than b
+  a -ef b    a and b are the same file
+
+Strings:
+  -n s       s is not empty      -z s       s is empty
+  s          s is not empty
+  a = b      equal (also ==)     a != b     not equal
+  a < b      sorts before        a > b      sorts after
+
+Integers:
+  a -eq b, a -ne b, a -lt b, a -le b, a -gt b, a -ge b
+
+Expressions:
+  ! expr     expr is false       ( expr )   grouping
+  x -a y     both are true       x -o y     either is true`
+}
+
+var unaryTests = map[string]bool{
+	"-n": true, "-z": true, "-e": true, "-f": true, "-d": true, "-r": true,
+	"-w": true, "-x": true, "-s": true, "-L": true, "-h": true, "-b": true,
+	"-c": true, "-p": true, "-S": true, "-t": true,
+}
+
+var binaryTests = map[string]bool{
+	"=": true, "==": true, "!=": true, "<": true, ">": true,
+	"-eq": true, "-ne": true, "-lt": true, "-le": true, "-gt": true, "-ge": true,
+	"-nt": true, "-ot": true, "-ef": true, "-a": true, "-o": true,
+}
+
+// testEval evaluates the arguments of test.
+type testEval struct {
+	ctx  *command.Context
+	args []string
+	pos  int
+}
+
+// eval evaluates args. Up to four arguments are interpreted by their
+// number as POSIX specifies, so that an operand such as "!" or "(" is
+// not mistaken for an operator; longer expressions are parsed with the
+// usual precedence of !, -a and -o.
+func (t *testEval) eval(args []string) (bool, error) {
+	switch len(args) {
+	case 0:
+		return false, nil
+	case 1:
+		return args[0] != "", nil
+	case 2:
+		if args[0] == "!" {
+			ok, err := t.eval(args[1:])
+			return !ok, err
+		}
+		if unaryTests[args[0]] {
+			return t.unary(args[0], args[1])
+		}
+		return false, fmt.Errorf("%s: unary operator expected", args[0])
+	case 3:
+		if binaryTests[args[1]] {
+			return t.binary(args[0], args[1], args[2])
+		}
+		if args[0] == "!" {
+			ok, err := t.eval(args[1:])
+			return !ok, err
+		}
+		if args[0] == "(" && args[2] == ")" {
+			return t.eval(args[1:2])
+		}
+	case 4:
+		if args[0] == "!" {
+			ok, err := t.eval(args[1:])
+			return !ok, err
+		}
+		if args[0] == "(" && args[3] == ")" {
+			return t.eval(args[1:3])
+		}
+	}
+
+	t.args, t.pos = args, 0
+	ok, err := t.or()
+	if err == nil && t.pos < len(t.args) {
+		err = fmt.Errorf("%s: unexpected argument", t.args[t.pos])
+	}
+	return ok, err
+}
+
+func (t *testEval) peek(offset int) (string, bool) {
+	if t.pos+offset >= len(t.args) {
+		return "", false
+	}
+	return t.args[t.pos+offset], true
+}
+
+func (t *testEval) or() (bool, error) {
+	ok, err := t.and()
+	for err == nil {
+		if arg, _ := t.peek(0); arg != "-o" {
+			break
+		}
+		t.pos++
+		var right bool
+		right, err = t.and()
+		ok = ok || right
+	}
+	return ok, err
+}
+
+func (t *testEval) and() (bool, error) {
+	ok, err := t.not()
+	for err == nil {
+		if arg, _ := t.peek(0); arg != "-a" {
+			break
+		}
+		t.pos++
+		var right bool
+		right, err = t.not()
+		ok = ok && right
+	}
+	return ok, err
+}
+
+func (t *testEval) not() (bool, error) {
+	if arg, _ := t.peek(0); arg == "!" {
+		t.pos++
+		ok, err := t.not()
+		return !ok, err
+	}
+	return t.primary()
+}
+
+func (t *testEval) primary() (bool, error) {
+	arg, ok := t.peek(0)
+	if !ok {
+		return false, fmt.Errorf("argument expected")
+	}
+	if op, ok := t.peek(1); ok && binaryTests[op] && op != "-a" && op != "-o" {
+		right, ok := t.peek(2)
+		if !ok {
+			return false, fmt.Errorf("%s: argument expected", op)
+		}
+		t.pos += 3
+		return t.binary(arg, op, right)
+	}
+	if arg == "(" {
+		t.pos++
+		result, err := t.or()
+		if err != nil {
+			return false, err
+		}
+		if closing, _ := t.peek(0); closing != ")" {
+			return false, fmt.Errorf("`)' expected")
+		}
+		t.pos++
+		return result, nil
+	}
+	if unaryTests[arg] {
+		operand, ok := t.peek(1)
+		if !ok {
+			return false, fmt.Errorf("%s: argument expected", arg)
+		}
+		t.pos += 2
+		return t.unary(arg, operand)
+	}
+	t.pos++
+	return arg != "", nil
+}
+
+// path resolves a file operand against the builtin's working directory.
+func (t *testEval) path(name string) string {
+	if filepath.IsAbs(name) || t.ctx.Dir == "" {
+		return name
+	}
+	return filepath.Join(t.ctx.Dir, name)
+}
+
+func (t *testEval) unary(op, operand string) (bool, error) {
+	switch op {
+	case "-n":
+		return operand != "", nil
+	case "-z":
+		return operand == "", nil
+	case "-t":
+		fd, err := strconv.Atoi(strings.TrimSpace(operand))
+		if err != nil {
+			return false, fmt.Errorf("%s: integer expression expected", operand)
+		}
+		return t.isTerminal(fd), nil
+	case "-r":
+		return unix.Access(t.path(operand), unix.R_OK) == nil, nil
+	case "-w":
+		return unix.Access(t.path(operand), unix.W_OK) == nil, nil
+	case "-x":
+		return unix.Access(t.path(operand), unix.X_OK) == nil, nil
+	case "-L", "-h":
+		info, err := os.Lstat(t.path(operand))
+		return err == nil && info.Mode()&os.ModeSymlink != 0, nil
+	}
+
+	info, err := os.Stat(t.path(operand))
+	if err != nil {
+		return false, nil
+	}
+	mode := info.Mode()
+	switch op {
+	case "-e":
+		return true, nil
+	case "-f":
+		return mode.IsRegular(), nil
+	case "-d":
+		return mode.IsDir(), nil
+	case "-s":
+		return info.Size() > 0, nil
+	case "-b":
+		return mode&os.ModeDevice != 0 && mode&os.ModeCharDevice == 0, nil
+	case "-c":
+		return mode&os.ModeCharDevice != 0, nil
+	case "-p":
+		return mode&os.ModeNamedPipe != 0, nil
+	case "-S":
+		return mode&os.ModeSocket != 0, nil
+	}
+	return false, fmt.Errorf("%s: unary operator expected", op)
+}
+
+// isTerminal reports whether fd is a terminal, looking at the builtin's
+// own streams for 0, 1 and 2.
+func (t *testEval) isTerminal(fd int) bool {
+	var stream any
+	switch fd {
+	case 0:
+		stream = t.ctx.Stdin
+	case 1:
+		stream = t.ctx.Stdout
+	case 2:
+		stream = t.ctx.Stderr
+	default:
+		return term.IsTerminal(fd)
+	}
+	f, ok := stream.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+func (t *testEval) binary(left, op, right string) (bool, error) {
+	switch op {
+	case "=", "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	case "<":
+		return left < right, nil
+	case ">":
+		return left > right, nil
+	case "-a":
+		return left != "" && right != "", nil
+	case "-o":
+		return left != "" || right != "", nil
+	case "-nt", "-ot":
+		l, lerr := os.Stat(t.path(left))
+		r, rerr := os.Stat(t.path(right))
+		if op == "-ot" {
+			l, lerr, r, rerr = r, rerr, l, lerr
+		}
+		if lerr != nil {
+			return false, nil
+		}
+		return rerr != nil || l.ModTime().After(r.ModTime()), nil
+	case "-ef":
+		l, lerr := os.Stat(t.path(left))
+		r, rerr := os.Stat(t.path(right))
+		return lerr == nil && rerr == nil && os.SameFile(l, r), nil
+	}
+
+	a, err := testInt(left)
+	if err != nil {
+		return false, err
+	}
+	b, err := testInt(right)
+	if err != nil {
+		return false, err
+	}
+	switch op {
+	case "-eq":
+		return a == b, nil
+	case "-ne":
+		return a != b, nil
+	case "-lt":
+		return a < b, nil
+	case "-le":
+		return a <= b, nil
+	case "-gt":
+		return a > b, nil
+	case "-ge":
+		return a >= b, nil
+	}
+	return false, fmt.Errorf("%s: binary operator expected", op)
+}
+
+func testInt(s string) (int64, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: integer expression expected", s)
+	}
+	return n, nil
+}
@@ -4,6 +4,8 @@ package builtins
 import (
 	"fmt"
 	"runtime"
+
+	"github.com/krzko/gosh/internal/shell/command"
 )
 
 type VerCommand struct{}
@@ -14,11 +16,11 @@ var (
 	BuildTime  = "unknown"
 )
 
-func (v *VerCommand) Execute(args []string) error {
-	fmt.Printf("gosh version %s (%s)\n", Version, runtime.Version())
-	fmt.Printf("Build: %s\n", BuildTime)
-	fmt.Printf("Commit: %s\n", CommitHash)
-	fmt.Printf("Platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+func (v *VerCommand) Execute(ctx *command.Context, args []string) error {
+	fmt.Fprintf(ctx.Stdout, "gosh version %s (%s)\n", Version, runtime.Version())
+	fmt.Fprintf(ctx.Stdout, "Build: %s\n", BuildTime)
+	fmt.Fprintf(ctx.Stdout, "Commit: %s\n", CommitHash)
+	fmt.Fprintf(ctx.Stdout, "Platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
 	return nil
 }
 
@@ -1,7 +1,72 @@
 // internal/shell/command/command.go
 package command
 
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/krzko/gosh/internal/shell/vars"
+)
+
 type BuiltinCommand interface {
-	Execute(args []string) error
+	Execute(ctx *Context, args []string) error
 	Help() string
 }
+
+// Context is what a builtin runs with. Builtins must read and write
+// through its streams rather than os.Stdin and os.Stdout, so that they
+// can be redirected and used as any stage of a pipeline.
+type Context struct {
+	// Context is cancelled when the user interrupts the builtin with
+	// Ctrl-C. Builtins that block, such as on the network, must give up
+	// once it is done.
+	Context context.Context
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Dir is the working directory the builtin was started in, and
+	// Chdir changes the shell's. If Chdir is nil, the builtin has the
+	// process's working directory to itself.
+	Dir   string
+	Chdir func(dir string) error
+	// Env is the environment the builtin runs with, as KEY=value pairs,
+	// including any assignments given before the command name.
+	Env []string
+	// Vars is the shell's variable store.
+	Vars *vars.Store
+}
+
+// Getenv returns the value of an environment variable from ctx.Env.
+func (ctx *Context) Getenv(name string) string {
+	for i := len(ctx.Env) - 1; i >= 0; i-- {
+		if key, value, ok := strings.Cut(ctx.Env[i], "="); ok && key == name {
+			return value
+		}
+	}
+	return ""
+}
+
+// ExitStatus is returned by a builtin to set a specific non-zero exit
+// status without printing an error message.
+type ExitStatus int
+
+func (s ExitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(s))
+}
+
+// ExitRequest is returned by the exit builtin to ask the shell to stop
+// once the current command has finished.
+type ExitRequest struct {
+	Status int
+	// Default is set when no status was given, in which case the shell
+	// exits with the status of the last command.
+	Default bool
+}
+
+func (r *ExitRequest) Error() string {
+	return "exit"
+}
@@ -4,21 +4,56 @@ package completion
 import (
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"github.com/krzko/gosh/internal/shell/command"
 )
 
+// Config controls tab completion.
+type Config struct {
+	// Enabled turns completion on.
+	Enabled bool
+	// ShowHidden offers dot files even when the word being completed
+	// does not start with a dot.
+	ShowHidden bool
+}
+
+// DefaultConfig returns the completion settings used without a config
+// file.
+func DefaultConfig() Config {
+	return Config{Enabled: true}
+}
+
 type Completer struct {
-	builtins []string
+	builtins map[string]command.BuiltinCommand
+	aliases  map[string]string
+	config   Config
 }
 
-func NewCompleter() *Completer {
+func NewCompleter(cfg Config) *Completer {
 	return &Completer{
-		builtins: []string{"cd", "ls", "pwd", "http", "https", "history", "exit", "help"},
+		config: cfg,
 	}
 }
 
+// SetBuiltins makes the completer offer the names of builtins as
+// commands. The map may change between completions.
+func (c *Completer) SetBuiltins(builtins map[string]command.BuiltinCommand) {
+	c.builtins = builtins
+}
+
+// SetAliases makes the completer offer the names of aliases as commands.
+// The map may change between completions.
+func (c *Completer) SetAliases(aliases map[string]string) {
+	c.aliases = aliases
+}
+
 // Do implements the readline.AutoCompleter interface
 func (c *Completer) Do(line []rune, pos int) ([][]rune, int) {
+	if !c.config.Enabled {
+		return nil, 0
+	}
 	lineStr := string(line[:pos])
 	if lineStr == "" {
 		return c.completeCommands(""), 0
@@ -53,11 +88,27 @@ func (c *Completer) Do(line []rune, pos int) ([][]rune, int) {
 
 func (c *Completer) completeCommands(prefix string) [][]rune {
 	var suggestions [][]rune
-	for _, cmd := range c.builtins {
-		if strings.HasPrefix(cmd, prefix) {
-			suggestions = append(suggestions, []rune(cmd))
+	var builtins []string
+	for name := range c.builtins {
+		if strings.HasPrefix(name, prefix) {
+			builtins = append(builtins, name)
+		}
+	}
+	sort.Strings(builtins)
+	for _, name := range builtins {
+		suggestions = append(suggestions, []rune(name))
+	}
+
+	var aliases []string
+	for name := range c.aliases {
+		if _, ok := c.builtins[name]; strings.HasPrefix(name, prefix) && !ok {
+			aliases = append(aliases, name)
 		}
 	}
+	sort.Strings(aliases)
+	for _, name := range aliases {
+		suggestions = append(suggestions, []rune(name))
+	}
 	return suggestions
 }
 
@@ -72,8 +123,8 @@ func (c *Completer) completePaths(prefix string) [][]rune {
 		}
 	}
 
-	base := filepath.Base(prefix)
-	if dir != "." && dir != prefix {
+	base := ""
+	if prefix != "" {
 		base = filepath.Base(prefix)
 	}
 
@@ -84,6 +135,9 @@ func (c *Completer) completePaths(prefix string) [][]rune {
 
 	for _, entry := range entries {
 		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") && !c.config.ShowHidden {
+			continue
+		}
 		if strings.HasPrefix(name, base) {
 			fullPath := name
 			if dir != "." {
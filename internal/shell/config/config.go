@@ -0,0 +1,297 @@
+// internal/shell/config/config.go
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/krzko/gosh/internal/shell/completion"
+	"github.com/krzko/gosh/internal/shell/history"
+	"github.com/krzko/gosh/internal/shell/httpclient"
+	"github.com/krzko/gosh/internal/shell/prompt"
+	"github.com/krzko/gosh/internal/utils/color"
+)
+
+// Config holds the settings read from the config file, a TOML file such
+// as:
+//
+//	theme = "light"
+//
+//	[prompt]
+//	show_git_branch = true
+//
+//	[history]
+//	size = 5000
+//
+//	[http]
+//	timeout = "30s"
+type Config struct {
+	Prompt     prompt.Config
+	History    history.Config
+	Completion completion.Config
+	HTTP       httpclient.Config
+
+	// Path is the file the config was loaded from, or "" if there was
+	// none.
+	Path string
+	// sources maps each key set by the file to where it was set.
+	sources map[string]string
+}
+
+// Error is a problem with a line of a config file.
+type Error struct {
+	Path string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Msg)
+}
+
+// Default returns the settings used without a config file.
+func Default() *Config {
+	return &Config{
+		Prompt:     prompt.DefaultConfig(),
+		History:    history.DefaultConfig(),
+		Completion: completion.DefaultConfig(),
+		HTTP:       httpclient.DefaultConfig(),
+		sources:    make(map[string]string),
+	}
+}
+
+// Dir returns gosh's directory under $XDG_CONFIG_HOME, or ~/.config if
+// that is not set.
+func Dir() string {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configDir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configDir, "gosh")
+}
+
+// DefaultPath returns the config file read at startup.
+func DefaultPath() string {
+	if dir := Dir(); dir != "" {
+		return filepath.Join(dir, "config.toml")
+	}
+	return ""
+}
+
+// setting is a key the config file may set, with a pointer to the field
+// it sets.
+type setting struct {
+	key   string
+	field any
+	// check, if set, validates a new value.
+	check func(value any) error
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{key: "theme", field: &c.Prompt.Theme, check: checkTheme},
+		{key: "prompt.format", field: &c.Prompt.Format},
+		{key: "prompt.show_git_branch", field: &c.Prompt.ShowGitBranch},
+		{key: "prompt.show_hostname", field: &c.Prompt.ShowHostname},
+		{key: "prompt.show_path", field: &c.Prompt.ShowPath},
+		{key: "prompt.ps2", field: &c.Prompt.PS2},
+		{key: "history.file", field: &c.History.File, check: checkNotEmpty},
+		{key: "history.size", field: &c.History.Size, check: checkNotNegative},
+		{key: "completion.enabled", field: &c.Completion.Enabled},
+		{key: "completion.show_hidden", field: &c.Completion.ShowHidden},
+		{key: "http.connect_timeout", field: &c.HTTP.ConnectTimeout},
+		{key: "http.response_timeout", field: &c.HTTP.ResponseTimeout},
+		{key: "http.timeout", field: &c.HTTP.Timeout},
+		{key: "http.retry", field: &c.HTTP.Retries, check: checkNotNegative},
+		{key: "http.retry_backoff", field: &c.HTTP.RetryBackoff},
+		{key: "http.proxy", field: &c.HTTP.Proxy},
+		{key: "http.insecure", field: &c.HTTP.Insecure},
+		{key: "http.cacert", field: &c.HTTP.CACert},
+		{key: "http.cert", field: &c.HTTP.Cert},
+		{key: "http.key", field: &c.HTTP.Key},
+		{key: "http.max_redirects", field: &c.HTTP.MaxRedirects, check: checkNotNegative},
+		{key: "http.version", field: &c.HTTP.HTTPVersion, check: checkHTTPVersion},
+	}
+}
+
+// Load reads the config file at path. A missing file is not an error. If
+// some settings are invalid, the config with the rest applied is returned
+// along with an error for each, giving its line.
+func Load(path string) (*Config, error) {
+	c := Default()
+	if path == "" {
+		return c, nil
+	}
+	src, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	c.Path = path
+
+	entries, err := parseTOML(string(src))
+	if err != nil {
+		var cerr *Error
+		if errors.As(err, &cerr) {
+			cerr.Path = path
+		}
+		return c, err
+	}
+
+	settings := make(map[string]setting)
+	for _, s := range c.settings() {
+		settings[s.key] = s
+	}
+
+	var errs []error
+	for _, e := range entries {
+		s, ok := settings[e.key]
+		if !ok {
+			errs = append(errs, &Error{Path: path, Line: e.line, Msg: fmt.Sprintf("unknown key %q", e.key)})
+			continue
+		}
+		if err := s.set(e.value); err != nil {
+			errs = append(errs, &Error{Path: path, Line: e.line, Msg: fmt.Sprintf("%s: %v", e.key, err)})
+			continue
+		}
+		c.sources[e.key] = fmt.Sprintf("%s:%d", path, e.line)
+	}
+	return c, errors.Join(errs...)
+}
+
+func (s setting) set(value any) error {
+	if s.check != nil {
+		if err := s.check(value); err != nil {
+			return err
+		}
+	}
+	switch field := s.field.(type) {
+	case *string:
+		v, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected a string, got %s", describe(value))
+		}
+		*field = v
+	case *bool:
+		v, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("expected true or false, got %s", describe(value))
+		}
+		*field = v
+	case *int:
+		v, ok := value.(int)
+		if !ok {
+			return fmt.Errorf("expected an integer, got %s", describe(value))
+		}
+		*field = v
+	case *time.Duration:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected a duration such as \"30s\", got %s", describe(value))
+		}
+		v, err := time.ParseDuration(s)
+		if err != nil || v < 0 {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		*field = v
+	}
+	return nil
+}
+
+func describe(value any) string {
+	switch v := value.(type) {
+	case string:
+		return "string " + strconv.Quote(v)
+	case bool:
+		return fmt.Sprintf("boolean %t", v)
+	case int:
+		return fmt.Sprintf("integer %d", v)
+	}
+	return fmt.Sprint(value)
+}
+
+func checkTheme(value any) error {
+	if name, ok := value.(string); ok {
+		_, err := color.Named(name)
+		return err
+	}
+	return nil
+}
+
+func checkNotEmpty(value any) error {
+	if value == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	return nil
+}
+
+func checkHTTPVersion(value any) error {
+	if version, ok := value.(string); ok {
+		return httpclient.CheckHTTPVersion(version)
+	}
+	return nil
+}
+
+func checkNotNegative(value any) error {
+	if n, ok := value.(int); ok && n < 0 {
+		return fmt.Errorf("must not be negative")
+	}
+	return nil
+}
+
+// Source returns where the value of key came from: "file:line", or
+// "default".
+func (c *Config) Source(key string) string {
+	if source, ok := c.sources[key]; ok {
+		return source
+	}
+	return "default"
+}
+
+// Print writes the given keys, or all of them, with their effective
+// values and where each came from, in the config file's syntax.
+func (c *Config) Print(w io.Writer, keys ...string) error {
+	settings := c.settings()
+	if len(keys) > 0 {
+		byKey := make(map[string]setting)
+		for _, s := range settings {
+			byKey[s.key] = s
+		}
+		settings = settings[:0]
+		for _, key := range keys {
+			s, ok := byKey[key]
+			if !ok {
+				return fmt.Errorf("unknown key %q", key)
+			}
+			settings = append(settings, s)
+		}
+	}
+
+	for _, s := range settings {
+		var value string
+		switch field := s.field.(type) {
+		case *string:
+			value = strconv.Quote(*field)
+		case *bool:
+			value = strconv.FormatBool(*field)
+		case *int:
+			value = strconv.Itoa(*field)
+		case *time.Duration:
+			value = strconv.Quote(field.String())
+		}
+		fmt.Fprintf(w, "%-24s = %-32s # %s\n", s.key, value, c.Source(s.key))
+	}
+	return nil
+}
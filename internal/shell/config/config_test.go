@@ -0,0 +1,133 @@
+// internal/shell/config/config_test.go
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `# gosh settings
+theme = "light"
+
+[prompt]
+format = "λ ${pwd} "  # lambda
+show_git_branch = true
+
+[history]
+size = 5_000
+file = '~/gosh/history'
+`)
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if c.Prompt.Theme != "light" || c.Prompt.Format != "λ ${pwd} " || !c.Prompt.ShowGitBranch {
+		t.Errorf("Prompt = %+v", c.Prompt)
+	}
+	if c.History.Size != 5000 || c.History.File != "~/gosh/history" {
+		t.Errorf("History = %+v", c.History)
+	}
+	if !c.Completion.Enabled {
+		t.Errorf("Completion.Enabled = false, want default true")
+	}
+
+	if got, want := c.Source("history.size"), path+":9"; got != want {
+		t.Errorf("Source(history.size) = %q, want %q", got, want)
+	}
+	if got := c.Source("prompt.show_path"); got != "default" {
+		t.Errorf("Source(prompt.show_path) = %q, want default", got)
+	}
+
+	var out strings.Builder
+	if err := c.Print(&out, "theme"); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, `"light"`) || !strings.Contains(got, path+":2") {
+		t.Errorf("Print() = %q", got)
+	}
+}
+
+func TestLoadHTTP(t *testing.T) {
+	path := writeConfig(t, `[http]
+timeout = "1m30s"
+retry = 3
+proxy = "proxy.example:3128"
+version = "1.1"
+`)
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if c.HTTP.Timeout != 90*time.Second || c.HTTP.Retries != 3 || c.HTTP.Proxy != "proxy.example:3128" || c.HTTP.HTTPVersion != "1.1" {
+		t.Errorf("HTTP = %+v", c.HTTP)
+	}
+	if c.HTTP.ConnectTimeout != 10*time.Second || c.HTTP.MaxRedirects != 10 {
+		t.Errorf("HTTP = %+v, want default connect timeout and redirects", c.HTTP)
+	}
+
+	var out strings.Builder
+	if err := c.Print(&out, "http.timeout", "http.connect_timeout"); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, `"1m30s"`) || !strings.Contains(got, `"10s"`) {
+		t.Errorf("Print() = %q", got)
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	c, err := Load(filepath.Join(t.TempDir(), "config.toml"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if c.Path != "" || c.Prompt.Theme != "default" {
+		t.Errorf("Load() = %+v, want defaults", c)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{"[prompt]\ncolour = true\n", []string{`:2: unknown key "prompt.colour"`}},
+		{"[history]\nsize = \"big\"\n", []string{`:2: history.size: expected an integer, got string "big"`}},
+		{"theme = \"neon\"\n", []string{`:1: theme: unknown theme "neon"`}},
+		{"\n[prompt\n", []string{`:2: missing ] after table name`}},
+		{"theme = \"light\"\ntheme = \"plain\"\n", []string{`:2: theme is already set on line 1`}},
+		{"[completion]\nenabled = yes\n", []string{`:2: completion.enabled: invalid value "yes"`}},
+		{"format = 1\n[history]\nsize = -1\n", []string{`:1: unknown key "format"`, `:3: history.size: must not be negative`}},
+		{"[http]\ntimeout = 30\n", []string{`:2: http.timeout: expected a duration such as "30s", got integer 30`}},
+		{"[http]\nconnect_timeout = \"soon\"\n", []string{`:2: http.connect_timeout: invalid duration "soon"`}},
+		{"[http]\nversion = \"3\"\n", []string{`:2: http.version: unknown HTTP version "3"`}},
+	}
+
+	for _, tt := range tests {
+		path := writeConfig(t, tt.src)
+		c, err := Load(path)
+		if err == nil {
+			t.Errorf("Load(%q) error = nil, want %q", tt.src, tt.want)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(err.Error(), path+want) {
+				t.Errorf("Load(%q) error = %q, want %q", tt.src, err, want)
+			}
+		}
+		if c == nil {
+			t.Errorf("Load(%q) config = nil", tt.src)
+		}
+	}
+}
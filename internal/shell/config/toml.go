@@ -0,0 +1,182 @@
+// internal/shell/config/toml.go
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// entry is a key set in a config file, with the table it appears in
+// prefixed, as in "prompt.theme".
+type entry struct {
+	key   string
+	value any
+	line  int
+}
+
+// parseTOML parses the subset of TOML that the config file uses: tables,
+// and keys set to strings, integers or booleans. Comments and blank lines
+// are ignored.
+func parseTOML(src string) ([]entry, error) {
+	var entries []entry
+	seen := make(map[string]int)
+	table := ""
+
+	for i, line := range strings.Split(src, "\n") {
+		lineNo := i + 1
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end < 0 {
+				return nil, &Error{Line: lineNo, Msg: "missing ] after table name"}
+			}
+			if rest := strings.TrimSpace(line[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, &Error{Line: lineNo, Msg: fmt.Sprintf("unexpected %q after table name", rest)}
+			}
+			name := strings.TrimSpace(line[1:end])
+			if !validKey(name) {
+				return nil, &Error{Line: lineNo, Msg: fmt.Sprintf("invalid table name %q", name)}
+			}
+			table = name
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, &Error{Line: lineNo, Msg: "expected key = value"}
+		}
+		key := strings.TrimSpace(line[:eq])
+		if !validKey(key) {
+			return nil, &Error{Line: lineNo, Msg: fmt.Sprintf("invalid key %q", key)}
+		}
+		if table != "" {
+			key = table + "." + key
+		}
+		if prev, ok := seen[key]; ok {
+			return nil, &Error{Line: lineNo, Msg: fmt.Sprintf("%s is already set on line %d", key, prev)}
+		}
+		seen[key] = lineNo
+
+		value, err := parseValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, &Error{Line: lineNo, Msg: fmt.Sprintf("%s: %v", key, err)}
+		}
+		entries = append(entries, entry{key: key, value: value, line: lineNo})
+	}
+	return entries, nil
+}
+
+// validKey reports whether key is made of bare keys, separated by dots.
+func validKey(key string) bool {
+	for _, part := range strings.Split(key, ".") {
+		if part == "" {
+			return false
+		}
+		for _, r := range part {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// parseValue parses a value and any comment after it.
+func parseValue(s string) (any, error) {
+	if s == "" {
+		return nil, fmt.Errorf("missing value")
+	}
+
+	var value any
+	var rest string
+	switch s[0] {
+	case '"':
+		str, n, err := basicString(s)
+		if err != nil {
+			return nil, err
+		}
+		value, rest = str, s[n:]
+	case '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string")
+		}
+		value, rest = s[1:end+1], s[end+2:]
+	default:
+		word, _, _ := strings.Cut(s, "#")
+		word = strings.TrimSpace(word)
+		switch word {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		default:
+			n, err := strconv.ParseInt(strings.ReplaceAll(word, "_", ""), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", word)
+			}
+			value = int(n)
+		}
+	}
+
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return nil, fmt.Errorf("unexpected %q after value", rest)
+	}
+	return value, nil
+}
+
+// basicString parses a double-quoted string at the start of s, returning
+// it and the number of bytes it took up.
+func basicString(s string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); {
+		c := s[i]
+		switch c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 >= len(s) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			i += 2
+			switch s[i-1] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'e':
+				b.WriteByte('\x1b')
+			case '"', '\\':
+				b.WriteByte(s[i-1])
+			case 'u', 'U':
+				size := 4
+				if s[i-1] == 'U' {
+					size = 8
+				}
+				if i+size > len(s) {
+					return "", 0, fmt.Errorf("invalid escape \\%c", s[i-1])
+				}
+				r, err := strconv.ParseUint(s[i:i+size], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(r)) {
+					return "", 0, fmt.Errorf("invalid escape \\%c%s", s[i-1], s[i:i+size])
+				}
+				b.WriteRune(rune(r))
+				i += size
+			default:
+				return "", 0, fmt.Errorf("invalid escape \\%c", s[i-1])
+			}
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
@@ -0,0 +1,205 @@
+// internal/shell/executor/control.go
+package executor
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/krzko/gosh/internal/shell/ast"
+	"github.com/krzko/gosh/internal/shell/command"
+	"github.com/krzko/gosh/internal/shell/expand"
+)
+
+// runIf runs the first branch whose condition succeeds, or the else
+// branch. Its status is that of the branch, or 0 if none ran.
+func (e *Executor) runIf(c *ast.IfClause) int {
+	for ; c != nil; c = c.Else {
+		if c.Cond != nil {
+			status := e.runStmts(c.Cond)
+			if e.stopped() {
+				return status
+			}
+			if status != 0 {
+				continue
+			}
+		}
+		return e.runStmts(c.Then)
+	}
+	return e.setStatus(0)
+}
+
+// runWhile runs the body of a while or until loop for as long as the
+// condition succeeds or, for until, fails. Its status is that of the last
+// body run, or 0 if it never ran.
+func (e *Executor) runWhile(c *ast.WhileClause) int {
+	e.loopDepth++
+	defer func() { e.loopDepth-- }()
+
+	status := 0
+	for {
+		cond := e.runStmts(c.Cond)
+		if e.endIteration() || (cond == 0) == c.Until {
+			break
+		}
+		status = e.runStmts(c.Do)
+		if e.endIteration() {
+			break
+		}
+	}
+	return e.setStatus(status)
+}
+
+// runFor runs the body of a for loop once for each field of its words, or
+// each positional parameter without "in".
+func (e *Executor) runFor(c *ast.ForClause) int {
+	items := e.params
+	if c.InSet {
+		var err error
+		items, err = expand.Fields(e.expandConfig(), c.Items...)
+		defer closeFiles(e.takeSubstFiles())
+		if err != nil {
+			return e.expandFailed(err)
+		}
+	}
+
+	e.loopDepth++
+	defer func() { e.loopDepth-- }()
+
+	status := 0
+	for _, item := range items {
+		e.vars.Set(c.Name.Value, item)
+		status = e.runStmts(c.Do)
+		if e.endIteration() {
+			break
+		}
+	}
+	return e.setStatus(status)
+}
+
+// runCase runs the statements of the first item with a pattern matching
+// the word. Its status is theirs, or 0 if no pattern matched.
+func (e *Executor) runCase(c *ast.CaseClause) int {
+	cfg := e.expandConfig()
+	word, err := expand.Literal(cfg, c.Word)
+	// Process substitutions are only of use while the words are
+	// matched, and must not be handed to the commands of the item run.
+	files := e.takeSubstFiles()
+	defer func() { closeFiles(files) }()
+	if err != nil {
+		return e.expandFailed(err)
+	}
+
+	for _, item := range c.Items {
+		for _, w := range item.Patterns {
+			pattern, err := expand.Pattern(cfg, w)
+			files = append(files, e.takeSubstFiles()...)
+			if err != nil {
+				return e.expandFailed(err)
+			}
+			matched, err := expand.Match(pattern, word)
+			if err != nil {
+				e.errorf("%v", err)
+				return e.setStatus(1)
+			}
+			if matched {
+				e.setStatus(0)
+				return e.runStmts(item.Stmts)
+			}
+		}
+	}
+	return e.setStatus(0)
+}
+
+// endIteration is called by a loop after running each list. It takes up a
+// pending break or continue aimed at this loop and reports whether the
+// loop should stop, which it also must to pass one on to an outer loop.
+func (e *Executor) endIteration() bool {
+	if e.breaking > 0 {
+		e.breaking--
+		return true
+	}
+	if e.continuing > 0 {
+		e.continuing--
+		return e.continuing > 0
+	}
+	return e.stopped()
+}
+
+// loopControl is returned by break and continue to stop the rest of the
+// enclosing loops' bodies.
+type loopControl struct {
+	// n is the number of loops to leave, or to continue the nth of.
+	n         int
+	continues bool
+}
+
+func (l *loopControl) Error() string {
+	if l.continues {
+		return fmt.Sprintf("continue %d", l.n)
+	}
+	return fmt.Sprintf("break %d", l.n)
+}
+
+// loopCount parses the loop count given to break or continue, which is
+// capped at the number of loops running.
+func (e *Executor) loopCount(args []string) (int, error) {
+	if e.loopDepth == 0 {
+		return 0, fmt.Errorf("only meaningful in a `for', `while', or `until' loop")
+	}
+	if len(args) > 1 {
+		return 0, fmt.Errorf("too many arguments")
+	}
+	n := 1
+	if len(args) == 1 {
+		var err error
+		n, err = strconv.Atoi(args[0])
+		if err != nil {
+			return 0, fmt.Errorf("%s: numeric argument required", args[0])
+		}
+		if n < 1 {
+			return 0, fmt.Errorf("%s: loop count out of range", args[0])
+		}
+	}
+	return min(n, e.loopDepth), nil
+}
+
+// BreakCommand leaves the enclosing loops.
+type BreakCommand struct {
+	executor *Executor
+}
+
+func (c *BreakCommand) Execute(ctx *command.Context, args []string) error {
+	n, err := c.executor.loopCount(args)
+	if err != nil {
+		return err
+	}
+	return &loopControl{n: n}
+}
+
+func (c *BreakCommand) Help() string {
+	return `break: Leave a loop
+Usage: break [n]
+
+Leaves the innermost for, while or until loop, or the n innermost.`
+}
+
+// ContinueCommand starts the next iteration of an enclosing loop.
+type ContinueCommand struct {
+	executor *Executor
+}
+
+func (c *ContinueCommand) Execute(ctx *command.Context, args []string) error {
+	n, err := c.executor.loopCount(args)
+	if err != nil {
+		return err
+	}
+	return &loopControl{n: n, continues: true}
+}
+
+func (c *ContinueCommand) Help() string {
+	return `continue: Start the next iteration of a loop
+Usage: continue [n]
+
+Skips the rest of the innermost for, while or until loop's body, or
+leaves the n-1 innermost loops and continues the nth.`
+}
@@ -2,107 +2,808 @@
 package executor
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 
+	"github.com/krzko/gosh/internal/shell/ast"
 	"github.com/krzko/gosh/internal/shell/builtins"
 	"github.com/krzko/gosh/internal/shell/command"
+	"github.com/krzko/gosh/internal/shell/expand"
+	"github.com/krzko/gosh/internal/shell/parser"
+	"github.com/krzko/gosh/internal/shell/vars"
 )
 
 type Command struct {
-	Name string
-	Args []string
-	Pipe *Command
+	Name      string
+	Args      []string
+	Env       []string
+	Redirects []Redirect
+	// Body is set instead of Name for a compound command, which runs
+	// within the shell.
+	Body ast.Command
+	// Files are the pipes of the process substitutions in the command,
+	// which it is given under their own descriptor numbers so that their
+	// /dev/fd paths refer to them.
+	Files []*os.File
 }
 
 type Executor struct {
 	builtins map[string]command.BuiltinCommand
+	options  map[string]bool
+	vars     *vars.Store
+	// aliases maps alias names to their values. They are expanded by the
+	// parser, which shares the map.
+	aliases map[string]string
+	// funcs holds the functions defined so far. A function hides a
+	// builtin or external command of the same name.
+	funcs map[string]*ast.FuncDecl
+
+	// arg0 and params are the shell's name, $0, and its positional
+	// parameters, $1 onwards.
+	arg0   string
+	params []string
+
+	stdin  *os.File
+	stdout *os.File
+	stderr *os.File
+
+	// dir is the shell's working directory, which relative paths in
+	// commands, redirections and patterns are taken from. The process's
+	// is shared with the subshells running alongside the shell, so only
+	// the shell itself, not a subshell, keeps it in step with dir.
+	dir        string
+	isSubshell bool
+
+	// status is the exit status of the last command, exposed as $?.
+	status int
+	// pipeStatus holds the status of each stage of the last pipeline,
+	// exposed as $PIPESTATUS.
+	pipeStatus []int
+	// substStatus is the status of the last command substitution run,
+	// which becomes that of a command made up only of assignments.
+	// substFiles holds the pipes of process substitutions waiting for
+	// the command they are part of to be built.
+	substStatus int
+	substFiles  []*os.File
+	// exited is set once the exit builtin has run, or an expansion
+	// error has stopped a shell that is not interactive.
+	exited bool
+	// interactive is set for a shell reading commands from the user.
+	// Subshells are not interactive.
+	interactive bool
+	// returning is set once the return builtin has run, until the
+	// function or sourced file it returns from has stopped. callDepth
+	// counts the functions and sourced files running.
+	returning bool
+	callDepth int
+	// loopDepth counts the loops running in the current function.
+	// breaking and continuing count the loops a break or continue has
+	// yet to leave, the last of which a continue then resumes.
+	loopDepth  int
+	breaking   int
+	continuing int
+
+	jobs *jobTable
+	// lastBackground is the pid of the last background job, exposed as
+	// $!.
+	lastBackground int
+	// jobControl is set by EnableJobControl, after which jobs run in
+	// their own process groups and take turns owning the terminal tty.
+	// pgid is the shell's own process group.
+	jobControl bool
+	tty        int
+	pgid       int
+
+	// mu guards ctx and cancel. ctx is the context foreground builtins
+	// run with; Interrupt cancels it and replaces it with a fresh one.
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	// interrupted is set by Interrupt and stops the rest of the current
+	// input from running.
+	interrupted atomic.Bool
 }
 
 func New() *Executor {
-	return &Executor{
-		builtins: registerBuiltins(),
+	e := &Executor{
+		options: map[string]bool{},
+		vars:    newVars(),
+		aliases: map[string]string{"ll": "ls -la"},
+		funcs:   map[string]*ast.FuncDecl{},
+		arg0:    "gosh",
+		stdin:   os.Stdin,
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+		jobs:    &jobTable{},
 	}
+	e.dir, _ = os.Getwd()
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	e.builtins = e.registerBuiltins()
+	return e
 }
 
-func (e *Executor) Execute(cmd *Command) error {
-	if builtin, ok := e.builtins[cmd.Name]; ok {
-		return builtin.Execute(cmd.Args)
+// subshell returns a copy of the executor for running commands
+// concurrently with it, as for a list run in the background. Changes the
+// copy makes to variables, options and the working directory do not
+// affect the original.
+func (e *Executor) subshell() *Executor {
+	sub := &Executor{
+		options: maps.Clone(e.options),
+		vars:    e.vars.Clone(),
+		aliases: maps.Clone(e.aliases),
+		funcs:   maps.Clone(e.funcs),
+		arg0:    e.arg0,
+		params:  e.params,
+		stdin:   e.stdin,
+		stdout:  e.stdout,
+		stderr:  e.stderr,
+		dir:     e.dir,
+		status:  e.status,
+		jobs:    &jobTable{},
+		// The working directory is the subshell's own.
+		isSubshell: true,
+		// A subshell started inside a function can still return from
+		// it.
+		callDepth: e.callDepth,
 	}
+	sub.ctx, sub.cancel = context.WithCancel(context.Background())
+	// The subshell has the same builtins, including those added with
+	// AddBuiltin, but acting on itself.
+	sub.builtins = maps.Clone(e.builtins)
+	sub.bindBuiltins(sub.builtins)
+	return sub
+}
 
-	return e.executeExternal(cmd)
+// chdir changes the shell's working directory to dir, an absolute path.
+func (e *Executor) chdir(dir string) error {
+	if !e.isSubshell {
+		if err := os.Chdir(dir); err != nil {
+			return err
+		}
+	}
+	e.dir = dir
+	return nil
+}
+
+// path returns name as a path relative to the shell's working directory.
+func (e *Executor) path(name string) string {
+	return inDir(e.dir, name)
+}
+
+// inDir returns where name is when relative to dir.
+func inDir(dir, name string) string {
+	if dir == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
+// SetParams sets $0 and the positional parameters $1 onwards, such as the
+// name and arguments of a script.
+func (e *Executor) SetParams(arg0 string, params []string) {
+	e.arg0 = arg0
+	e.params = params
+}
+
+// SetInteractive marks the shell as reading commands from the user, so
+// that errors which would stop a script only abandon the current input.
+func (e *Executor) SetInteractive(interactive bool) {
+	e.interactive = interactive
+}
+
+// Status returns the exit status of the last command run.
+func (e *Executor) Status() int {
+	return e.status
+}
+
+// Exited reports whether the exit builtin has asked the shell to stop.
+func (e *Executor) Exited() bool {
+	return e.exited
+}
+
+// Run executes every statement in a parsed file in order and returns the
+// exit status of the last one.
+func (e *Executor) Run(f *ast.File) int {
+	e.interrupted.Store(false)
+	return e.runFile(f)
+}
+
+// runFile runs the statements of f.
+func (e *Executor) runFile(f *ast.File) int {
+	return e.runStmts(f.Stmts)
+}
+
+// runStmts runs statements in order, stopping early if the shell exits,
+// a function returns, a loop is broken out of or the input is
+// interrupted.
+func (e *Executor) runStmts(stmts []*ast.Stmt) int {
+	for _, stmt := range stmts {
+		if e.stopped() {
+			break
+		}
+		e.runStmt(stmt)
+	}
+	return e.status
+}
+
+// stopped reports whether the rest of the commands being run should be
+// skipped.
+func (e *Executor) stopped() bool {
+	return e.exited || e.returning || e.breaking > 0 || e.continuing > 0 || e.interrupted.Load()
+}
+
+func (e *Executor) runStmt(stmt *ast.Stmt) int {
+	if stmt.Background {
+		return e.setStatus(e.runBackground(stmt))
+	}
+	switch c := stmt.Cmd.(type) {
+	case *ast.BinaryCmd:
+		return e.runBinary(c)
+	case *ast.FuncDecl:
+		return e.runCompound(c)
+	}
+
+	e.substStatus = 0
+	j := &job{text: ast.String(stmt), foreground: true}
+	e.startJob(j, stages(stmt))
+	status := e.waitForeground(j)
+	if pipe, ok := stmt.Cmd.(*ast.Pipeline); ok && pipe.Negated {
+		if status == 0 {
+			status = 1
+		} else {
+			status = 0
+		}
+	}
+	return e.setStatus(status)
 }
 
-func (e *Executor) executeExternal(cmd *Command) error {
-	if cmd.Pipe != nil {
-		return e.executePipeline(cmd)
+// runBinary runs the right-hand side of && only if the left succeeded, and
+// that of || only if it failed.
+func (e *Executor) runBinary(bin *ast.BinaryCmd) int {
+	status := e.runStmt(bin.X)
+	if e.stopped() {
+		return status
 	}
+	switch bin.Op {
+	case ast.AndStmt:
+		if status != 0 {
+			return status
+		}
+	case ast.OrStmt:
+		if status == 0 {
+			return status
+		}
+	}
+	return e.runStmt(bin.Y)
+}
 
-	command := exec.Command(cmd.Name, cmd.Args...)
-	command.Stdin = os.Stdin
-	command.Stdout = os.Stdout
-	command.Stderr = os.Stderr
-	return command.Run()
+// SetStatus sets the exit status reported by $?, for failures that happen
+// outside the executor such as syntax errors.
+func (e *Executor) SetStatus(status int) {
+	e.status = status
 }
 
-func (e *Executor) executePipeline(first *Command) error {
-	var commands []*exec.Cmd
-	current := first
+func (e *Executor) setStatus(status int) int {
+	e.status = status
+	return status
+}
 
-	for current != nil {
-		cmd := exec.Command(current.Name, current.Args...)
-		commands = append(commands, cmd)
-		current = current.Pipe
+func (e *Executor) expandConfig() *expand.Config {
+	cfg := &expand.Config{
+		Env:       environ{e},
+		Params:    e.params,
+		NoGlob:    e.options["noglob"],
+		Dir:       e.dir,
+		CmdSubst:  e.cmdSubst,
+		ProcSubst: e.procSubst,
+	}
+	switch {
+	case e.options["failglob"]:
+		cfg.GlobMode = expand.GlobFail
+	case e.options["nullglob"]:
+		cfg.GlobMode = expand.GlobNull
 	}
+	return cfg
+}
 
-	// Connect the pipeline
-	for i := 0; i < len(commands)-1; i++ {
-		pipe, err := commands[i].StdoutPipe()
+// lower expands a simple or compound command into the Command that
+// executes it. It returns nil for statements that consist only of
+// assignments.
+func (e *Executor) lower(stmt *ast.Stmt) (*Command, error) {
+	switch c := stmt.Cmd.(type) {
+	case *ast.CallExpr:
+		redirs, err := e.lowerRedirects(stmt.Redirs)
 		if err != nil {
-			return err
+			return e.withSubstFiles(nil, err)
 		}
-		commands[i+1].Stdin = pipe
+		return e.withSubstFiles(e.lowerCall(c, redirs))
+	case *ast.Block, *ast.FuncDecl, *ast.IfClause, *ast.WhileClause, *ast.ForClause, *ast.CaseClause:
+		redirs, err := e.lowerRedirects(stmt.Redirs)
+		if err != nil {
+			return e.withSubstFiles(nil, err)
+		}
+		return e.withSubstFiles(&Command{Body: c, Redirects: redirs}, nil)
+	default:
+		return nil, fmt.Errorf("unsupported command type %T", c)
 	}
+}
 
-	// Start all commands
-	for _, cmd := range commands {
-		if err := cmd.Start(); err != nil {
-			return err
+func (e *Executor) lowerCall(call *ast.CallExpr, redirs []Redirect) (*Command, error) {
+	cfg := e.expandConfig()
+
+	var env []string
+	for _, as := range call.Assigns {
+		value, err := expand.Assignment(cfg, as.Value)
+		if err != nil {
+			return nil, err
 		}
+		env = append(env, as.Name+"="+value)
 	}
 
-	// Wait for all commands
-	for _, cmd := range commands {
-		if err := cmd.Wait(); err != nil {
-			return err
+	args, err := expand.Fields(cfg, call.Args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		// A command with no name still performs its redirections, so
+		// that "> file" creates or truncates file.
+		table := newFdTable(e.stdin, e.stdout, e.stderr)
+		err := table.apply(redirs, e.dir)
+		table.close()
+		if err != nil {
+			return nil, redirectError{err}
+		}
+
+		for _, as := range env {
+			name, value, _ := strings.Cut(as, "=")
+			e.vars.Set(name, value)
 		}
+		return nil, nil
 	}
 
-	return nil
+	return &Command{Name: args[0], Args: args[1:], Env: env, Redirects: redirs}, nil
+}
+
+func (e *Executor) lowerRedirects(redirs []*ast.Redirect) ([]Redirect, error) {
+	cfg := e.expandConfig()
+
+	var result []Redirect
+	for _, r := range redirs {
+		fd := defaultFd(r.Op)
+		if r.N != nil {
+			n, err := strconv.Atoi(r.N.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: bad file descriptor", r.N.Value)
+			}
+			fd = n
+		}
+		word := r.Word
+		if r.Hdoc != nil {
+			word = r.Hdoc
+		}
+		target, err := expand.Literal(cfg, word)
+		if err != nil {
+			return nil, err
+		}
+		if r.Op == ast.HereString {
+			target += "\n"
+		}
+		result = append(result, Redirect{Op: r.Op, Fd: fd, Target: target})
+	}
+	return result, nil
+}
+
+// stages returns the statements run by each stage of stmt: those of a
+// pipeline, or stmt itself.
+func stages(stmt *ast.Stmt) []*ast.Stmt {
+	if pipe, ok := stmt.Cmd.(*ast.Pipeline); ok {
+		return pipe.Stmts
+	}
+	return []*ast.Stmt{stmt}
+}
+
+// runBackground starts stmt as a background job and returns without
+// waiting for it. Lists are run by a subshell on their own goroutine.
+func (e *Executor) runBackground(stmt *ast.Stmt) int {
+	fg := *stmt
+	fg.Background = false
+	j := &job{text: ast.String(&fg)}
+
+	switch fg.Cmd.(type) {
+	case *ast.CallExpr, *ast.Pipeline:
+		e.startJob(j, stages(&fg))
+	default:
+		sub := e.subshell()
+		done := make(chan int, 1)
+		go func() { done <- sub.runStmt(&fg) }()
+		j.procs = append(j.procs, &process{done: done})
+	}
+
+	e.jobs.add(j)
+	e.lastBackground = j.pid()
+	if e.jobControl {
+		fmt.Fprintf(e.stderr, "[%d] %d\n", j.id, j.pid())
+	}
+	return 0
+}
+
+// startJob starts each of stmts as a stage of j, connecting the stages
+// with pipes. Builtins run on their own goroutines unless the job is a
+// single builtin in the foreground. Each stage of a pipeline or
+// background job is expanded and run by a subshell of its own, so that it
+// cannot change the shell's state.
+func (e *Executor) startJob(j *job, stmts []*ast.Stmt) {
+	async := len(stmts) > 1 || !j.foreground
+
+	stdin := e.stdin
+	if !j.foreground && !e.jobControl {
+		// Without job control, background jobs must not compete with
+		// the shell for its input.
+		if null, err := os.Open(os.DevNull); err == nil {
+			stdin = null
+		}
+	}
+
+	for i, stmt := range stmts {
+		stdout := e.stdout
+		var next *os.File
+		if i < len(stmts)-1 {
+			r, w, err := os.Pipe()
+			if err != nil {
+				e.errorf("%v", err)
+			} else {
+				stdout, next = w, r
+			}
+		}
+
+		// Each stage owns its ends of the pipes through its table, so the
+		// shell's copies are closed once the stage has started (or, for
+		// a builtin, finished) and readers see EOF when writers exit.
+		table := newFdTable(stdin, stdout, e.stderr)
+		if stdin != e.stdin {
+			table.opened = append(table.opened, stdin)
+		}
+		if stdout != e.stdout {
+			table.opened = append(table.opened, stdout)
+		}
+		stdin = next
+
+		sh := e
+		if async {
+			sh = e.subshell()
+		}
+		j.procs = append(j.procs, e.startStage(j, sh, stmt, table, async))
+	}
+}
+
+// startStage expands stmt in sh, the shell the stage runs in, and starts
+// it as part of j with the file descriptors in table, taking ownership of
+// the table.
+func (e *Executor) startStage(j *job, sh *Executor, stmt *ast.Stmt, table *fdTable, async bool) *process {
+	cmd, err := sh.lower(stmt)
+	if err != nil {
+		table.close()
+		return &process{state: jobDone, status: sh.expandFailed(err)}
+	}
+	if cmd == nil {
+		table.close()
+		return &process{state: jobDone, status: sh.substStatus}
+	}
+
+	for _, f := range cmd.Files {
+		table.inherit(f)
+	}
+	if err := table.apply(cmd.Redirects, sh.dir); err != nil {
+		table.close()
+		e.errorf("%v", err)
+		return &process{state: jobDone, status: 1}
+	}
+	return e.start(j, sh, cmd, table, async)
+}
+
+// waitForeground waits for a foreground job to finish or stop and
+// returns its exit status. A stopped job is added to the job table.
+func (e *Executor) waitForeground(j *job) int {
+	// A job killed with Ctrl-C takes its builtins with it, and the
+	// rest of the input is abandoned.
+	j.waitExternal()
+	if j.killedBy(syscall.SIGINT) {
+		e.Interrupt()
+	}
+	j.wait()
+	if e.jobControl && j.pgid != 0 {
+		if err := e.setForeground(e.pgid); err != nil {
+			e.errorf("%v", err)
+		}
+	}
+
+	if j.state() == jobStopped {
+		j.foreground = false
+		j.reported = jobStopped
+		e.jobs.add(j)
+		fmt.Fprintf(e.stderr, "\n%s\n", e.jobs.format(j, false))
+	} else if e.jobs.contains(j) {
+		e.jobs.remove(j)
+	}
+	return e.setPipeStatus(j.statuses()...)
+}
+
+// start begins running cmd in sh, the shell the stage of j runs in, with
+// the file descriptors in table, taking ownership of the table, and
+// returns the running process. Builtins and commands run within the shell
+// run on the calling goroutine unless async is set, in which case sh is a
+// subshell and they run on its own goroutine.
+func (e *Executor) start(j *job, sh *Executor, cmd *Command, table *fdTable, async bool) *process {
+	if _, ok := sh.funcs[cmd.Name]; ok || cmd.Body != nil {
+		return sh.startInShell(cmd, table, async)
+	}
+	if builtin, ok := sh.builtins[cmd.Name]; ok {
+		ctx := e.builtinContext(j, sh, cmd, table)
+		run := func() int {
+			defer table.close()
+			err := builtin.Execute(ctx, cmd.Args)
+			return sh.exitStatus(cmd.Name, err)
+		}
+		if !async {
+			return &process{state: jobDone, status: run()}
+		}
+
+		done := make(chan int, 1)
+		go func() { done <- run() }()
+		return &process{done: done}
+	}
+
+	// External commands are found on the shell's PATH, not the one the
+	// shell itself was started with.
+	name := cmd.Name
+	if !strings.Contains(name, "/") {
+		path, ok := sh.lookPath(name, cmd.Env)
+		if !ok {
+			table.close()
+			err := &exec.Error{Name: name, Err: exec.ErrNotFound}
+			return &process{state: jobDone, status: e.exitStatus(name, err)}
+		}
+		name = sh.path(path)
+	}
+
+	command := e.command(j, sh, cmd, table, name, cmd.Args)
+	command.Args[0] = cmd.Name
+	err := command.Start()
+	if errors.Is(err, syscall.ENOEXEC) {
+		// An executable file without a #! line is a script for this
+		// shell, as in other shells.
+		if self, selfErr := os.Executable(); selfErr == nil {
+			command = e.command(j, sh, cmd, table, self, append([]string{command.Path}, cmd.Args...))
+			err = command.Start()
+		}
+	}
+	table.close()
+	if err != nil {
+		return &process{state: jobDone, status: e.exitStatus(cmd.Name, err)}
+	}
+
+	pid := command.Process.Pid
+	if e.jobControl && j.pgid == 0 {
+		j.pgid = pid
+	}
+	return &process{pid: pid, proc: command.Process}
+}
+
+// startInShell runs a compound command or function call within the shell,
+// with its standard streams taken from table. If async is set, as for a
+// stage of a pipeline, it runs on its own goroutine.
+func (e *Executor) startInShell(cmd *Command, table *fdTable, async bool) *process {
+	run := func() int {
+		defer table.close()
+		restore := e.useFdTable(table)
+		defer restore()
+		if cmd.Body != nil {
+			return e.runCompound(cmd.Body)
+		}
+		return e.callFunction(e.funcs[cmd.Name], cmd.Args, cmd.Env)
+	}
+	if !async {
+		return &process{state: jobDone, status: run()}
+	}
+
+	done := make(chan int, 1)
+	go func() { done <- run() }()
+	return &process{done: done}
+}
+
+// runCompound runs a compound command, without its redirections, which
+// are applied by the caller.
+func (e *Executor) runCompound(cmd ast.Command) int {
+	switch c := cmd.(type) {
+	case *ast.Block:
+		return e.runStmts(c.Stmts)
+	case *ast.FuncDecl:
+		e.funcs[c.Name.Value] = c
+		return e.setStatus(0)
+	case *ast.IfClause:
+		return e.runIf(c)
+	case *ast.WhileClause:
+		return e.runWhile(c)
+	case *ast.ForClause:
+		return e.runFor(c)
+	case *ast.CaseClause:
+		return e.runCase(c)
+	}
+	e.errorf("unsupported command type %T", cmd)
+	return e.setStatus(1)
+}
+
+// useFdTable replaces the shell's standard streams with those in table,
+// returning a function that restores them. A closed stream is replaced
+// by closedFile.
+func (e *Executor) useFdTable(table *fdTable) (restore func()) {
+	saved := [3]*os.File{e.stdin, e.stdout, e.stderr}
+	e.stdin, e.stdout, e.stderr = table.stream(0), table.stream(1), table.stream(2)
+	return func() { e.stdin, e.stdout, e.stderr = saved[0], saved[1], saved[2] }
+}
+
+// command prepares the process that runs name with args for cmd in sh.
+func (e *Executor) command(j *job, sh *Executor, cmd *Command, table *fdTable, name string, args []string) *exec.Cmd {
+	command := exec.Command(name, args...)
+	command.Dir = sh.dir
+	command.Env = sh.commandEnv(cmd)
+	command.SysProcAttr = e.procAttr(j)
+	table.configure(command)
+	return command
+}
+
+// builtinContext builds the context a builtin runs with in sh from its
+// file descriptors. Reading or writing a closed descriptor fails. Only builtins in the foreground can be interrupted, through the
+// context of the shell running j, so that Interrupt reaches those in a
+// foreground pipeline too.
+func (e *Executor) builtinContext(j *job, sh *Executor, cmd *Command, table *fdTable) *command.Context {
+	ctx := &command.Context{
+		Context: context.Background(),
+		Stdin:   closedFd{},
+		Stdout:  closedFd{},
+		Stderr:  closedFd{},
+		Dir:     sh.dir,
+		Chdir:   sh.chdir,
+		Env:     sh.commandEnv(cmd),
+		Vars:    sh.vars,
+	}
+	if !table.closed[0] {
+		ctx.Stdin = table.files[0]
+	}
+	if !table.closed[1] {
+		ctx.Stdout = table.files[1]
+	}
+	if !table.closed[2] {
+		ctx.Stderr = table.files[2]
+	}
+	if j.foreground {
+		e.mu.Lock()
+		ctx.Context = e.ctx
+		e.mu.Unlock()
+	}
+	return ctx
+}
+
+// Interrupt cancels the builtins running in the foreground and stops the
+// rest of the current input from running, as Ctrl-C does. External
+// commands receive SIGINT from the terminal themselves. It is safe to
+// call from any goroutine.
+func (e *Executor) Interrupt() {
+	e.interrupted.Store(true)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.cancel()
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+}
+
+// setPipeStatus records the status of each stage of the command just run
+// and returns the status of the command as a whole: that of the last
+// stage, or with pipefail set, of the last stage to fail.
+func (e *Executor) setPipeStatus(statuses ...int) int {
+	e.pipeStatus = statuses
+	return e.pipelineStatus(statuses)
+}
+
+// pipelineStatus returns the status of a pipeline as a whole from those of
+// its stages.
+func (e *Executor) pipelineStatus(statuses []int) int {
+	if e.options["pipefail"] {
+		for i := len(statuses) - 1; i >= 0; i-- {
+			if statuses[i] != 0 {
+				return statuses[i]
+			}
+		}
+	}
+	return statuses[len(statuses)-1]
+}
+
+// commandEnv returns the environment a command runs with: the exported
+// variables plus any assignments given before the command name.
+func (e *Executor) commandEnv(cmd *Command) []string {
+	return append(e.vars.Environ(), cmd.Env...)
+}
+
+// AddBuiltin makes cmd available as the builtin name.
+func (e *Executor) AddBuiltin(name string, cmd command.BuiltinCommand) {
+	e.builtins[name] = cmd
 }
 
 func (e *Executor) GetBuiltins() map[string]command.BuiltinCommand {
 	return e.builtins
 }
 
-func registerBuiltins() map[string]command.BuiltinCommand {
+// Aliases returns the shell's aliases, for the parser to expand. The
+// alias and unalias builtins change the map.
+func (e *Executor) Aliases() map[string]string {
+	return e.aliases
+}
+
+// NewParser returns a parser that expands the shell's aliases.
+func (e *Executor) NewParser() *parser.Parser {
+	p := parser.New()
+	p.SetAliases(e.aliases)
+	return p
+}
+
+// Vars returns the shell's variable store.
+func (e *Executor) Vars() *vars.Store {
+	return e.vars
+}
+
+// registerBuiltins returns the builtins every shell starts with.
+func (e *Executor) registerBuiltins() map[string]command.BuiltinCommand {
 	builtinMap := map[string]command.BuiltinCommand{
-		"ls":    builtins.NewLsCommand(),
-		"cd":    &builtins.CdCommand{},
-		"http":  &builtins.HttpCommand{},
-		"https": &builtins.HttpsCommand{},
-		"exit":  &builtins.ExitCommand{},
-		"pwd":   &builtins.PwdCommand{},
-		"ver":   &builtins.VerCommand{},
+		"ls":     builtins.NewLsCommand(),
+		"cd":     &builtins.CdCommand{},
+		"http":   &builtins.HttpCommand{},
+		"https":  &builtins.HttpsCommand{},
+		"exit":   &builtins.ExitCommand{},
+		"pwd":    &builtins.PwdCommand{},
+		"ver":    &builtins.VerCommand{},
+		"export": &builtins.ExportCommand{},
+		"unset":  &builtins.UnsetCommand{},
+		"env":    &builtins.EnvCommand{},
+		"test":   &builtins.TestCommand{},
+		"[":      &builtins.TestCommand{Bracket: true},
 	}
+	e.bindBuiltins(builtinMap)
+	return builtinMap
+}
 
-	// Aliases
-	builtinMap["ll"] = &builtins.AliasCommand{
-		Cmd:  builtinMap["ls"],
-		Args: []string{"-la"},
+// bindBuiltins adds the builtins that act on the executor itself to
+// builtinMap, replacing those of the executor it was copied from.
+func (e *Executor) bindBuiltins(builtinMap map[string]command.BuiltinCommand) {
+	bound := map[string]command.BuiltinCommand{
+		"set":      &SetCommand{executor: e},
+		"jobs":     &JobsCommand{executor: e},
+		"fg":       &FgCommand{executor: e},
+		"bg":       &BgCommand{executor: e},
+		"wait":     &WaitCommand{executor: e},
+		"disown":   &DisownCommand{executor: e},
+		"shift":    &ShiftCommand{executor: e},
+		"source":   &SourceCommand{executor: e},
+		"alias":    &builtins.AliasCommand{Aliases: e.aliases},
+		"unalias":  &builtins.UnaliasCommand{Aliases: e.aliases},
+		"return":   &ReturnCommand{executor: e},
+		"local":    &LocalCommand{executor: e},
+		"type":     &TypeCommand{executor: e},
+		"break":    &BreakCommand{executor: e},
+		"continue": &ContinueCommand{executor: e},
 	}
+	maps.Copy(builtinMap, bound)
+	builtinMap["."] = builtinMap["source"]
 
-	builtinMap["help"] = builtins.NewHelpCommand(builtinMap)
-
-	return builtinMap
+	help := builtins.NewHelpCommand(builtinMap)
+	help.Describe = e.describeForHelp
+	builtinMap["help"] = help
 }
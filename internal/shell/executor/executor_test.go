@@ -0,0 +1,711 @@
+// internal/shell/executor/executor_test.go
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/krzko/gosh/internal/shell/command"
+)
+
+// run parses and executes src in a fresh temporary directory and returns
+// the exit status.
+func run(t *testing.T, e *Executor, src string) int {
+	t.Helper()
+	file, err := e.NewParser().Parse(src)
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", src, err)
+	}
+	return e.Run(file)
+}
+
+// chdirTemp changes into a temporary directory for the duration of the
+// test.
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+func readFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSuffix(string(b), "\n")
+}
+
+func TestExitStatus(t *testing.T) {
+	chdirTemp(t)
+
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"true", 0},
+		{"false", 1},
+		{"! false", 0},
+		{"! true", 1},
+		{"sh -c 'exit 3'", 3},
+		{"sh -c 'kill -TERM $$'", 143},
+		{"gosh-no-such-command 2>/dev/null", 127},
+		{"false || sh -c 'exit 4'", 4},
+		{"true && false", 1},
+		{"false; true", 0},
+		{"false | true", 0},
+		{"true | false", 1},
+		{"exit 5", 5},
+		{"echo hi >&- 2>/dev/null", 1},
+		{"{ echo hi; } >&- 2>/dev/null", 1},
+		{"sh -c 'echo hi || exit 3' >&- 2>/dev/null", 3},
+		{"sh -c 'read x || exit 3' <&- 2>/dev/null", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			e := New()
+			if got := run(t, e, tt.input); got != tt.want {
+				t.Errorf("Run(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusParameters(t *testing.T) {
+	dir := chdirTemp(t)
+	e := New()
+
+	run(t, e, "sh -c 'exit 7'; echo $? > status")
+	if got := readFile(t, dir, "status"); got != "7" {
+		t.Errorf("$? = %q, want 7", got)
+	}
+
+	run(t, e, "sh -c 'exit 2' | true | sh -c 'exit 3'")
+	run(t, e, `echo "$PIPESTATUS" > pipestatus`)
+	if got := readFile(t, dir, "pipestatus"); got != "2 0 3" {
+		t.Errorf("$PIPESTATUS = %q, want %q", got, "2 0 3")
+	}
+
+	if got := run(t, e, "false | true"); got != 0 {
+		t.Errorf("without pipefail status = %d, want 0", got)
+	}
+	run(t, e, "set -o pipefail")
+	if got := run(t, e, "false | true"); got != 1 {
+		t.Errorf("with pipefail status = %d, want 1", got)
+	}
+}
+
+func TestExitStopsExecution(t *testing.T) {
+	dir := chdirTemp(t)
+	e := New()
+
+	if got := run(t, e, "false; exit; echo after > out"); got != 1 {
+		t.Errorf("exit status = %d, want 1", got)
+	}
+	if !e.Exited() {
+		t.Error("Exited() = false, want true")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out")); err == nil {
+		t.Error("command after exit was run")
+	}
+}
+
+func TestBuiltinsInPipelines(t *testing.T) {
+	dir := chdirTemp(t)
+	e := New()
+
+	tests := []struct {
+		input string
+		file  string
+		want  string
+	}{
+		{"pwd | tr a-z A-Z > out1", "out1", strings.ToUpper(dir)},
+		{"ver | head -n 1 | cut -c1-4 > out2", "out2", "gosh"},
+		{"help | grep -c pwd > out3", "out3", "1"},
+		{"pwd > out4 2>&1", "out4", dir},
+		{"echo piped | cat | tr a-z A-Z | cat > out5", "out5", "PIPED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if status := run(t, e, tt.input); status != 0 {
+				t.Fatalf("Run(%q) status = %d, want 0", tt.input, status)
+			}
+			if got := readFile(t, dir, tt.file); got != tt.want {
+				t.Errorf("Run(%q) output = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	if status := run(t, e, "pwd | false"); status != 1 {
+		t.Errorf("pwd | false status = %d, want 1", status)
+	}
+	if status := run(t, e, "help | true"); status != 0 {
+		t.Errorf("help | true status = %d, want 0", status)
+	}
+
+	// Builtins in a pipeline run in a subshell and leave the shell as it
+	// was.
+	if status := run(t, e, "echo x | exit 4"); status != 4 || e.Exited() {
+		t.Errorf("echo x | exit 4 status = %d, exited = %v, want 4, false", status, e.Exited())
+	}
+	run(t, e, "export PIPED=1 | cat; echo ${PIPED:-unset} > out6")
+	if got := readFile(t, dir, "out6"); got != "unset" {
+		t.Errorf("export in a pipeline: PIPED = %q, want unset", got)
+	}
+	run(t, e, "set -o pipefail | cat")
+	if status := run(t, e, "false | true"); status != 0 {
+		t.Errorf("set -o pipefail in a pipeline changed the shell's options")
+	}
+
+	// So are the stages' assignments and expansions.
+	run(t, e, `x=1 | cat; echo "[$x]" > out7`)
+	if got := readFile(t, dir, "out7"); got != "[]" {
+		t.Errorf("assignment in a pipeline: x = %q, want []", got)
+	}
+	run(t, e, `echo $((y=5)) | cat; echo "[$y]" > out8`)
+	if got := readFile(t, dir, "out8"); got != "[]" {
+		t.Errorf("arithmetic in a pipeline: y = %q, want []", got)
+	}
+}
+
+// textBuiltin is a builtin that writes its text.
+type textBuiltin string
+
+func (b textBuiltin) Execute(ctx *command.Context, args []string) error {
+	fmt.Fprintln(ctx.Stdout, string(b))
+	return nil
+}
+
+func (b textBuiltin) Help() string { return "" }
+
+func TestAddBuiltin(t *testing.T) {
+	dir := chdirTemp(t)
+	e := New()
+	e.AddBuiltin("ver", textBuiltin("added"))
+	e.AddBuiltin("greet", textBuiltin("hello"))
+
+	// Subshells have the added builtins, including those replacing
+	// the defaults, and their own bound ones.
+	src := `ver > out; ver | cat >> out; echo $(greet) >> out; { ver >> out; } & wait
+{ alias sub=x; alias | grep -c sub; } | cat >> out; alias | grep -c sub >> out`
+	run(t, e, src)
+	if got, want := readFile(t, dir, "out"), "added\nadded\nhello\nadded\n1\n0"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestWorkingDirectory(t *testing.T) {
+	dir := chdirTemp(t)
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, "a"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := New()
+
+	tests := []struct {
+		input string
+		file  string
+		want  string
+	}{
+		{"cd / | cat; pwd > out1", "out1", dir},
+		{"{ cd /; } & wait; pwd > out2", "out2", dir},
+		{"echo a | { cd /; cat; } >/dev/null; pwd > out3", "out3", dir},
+		{"{ cd sub; echo * > out4; pwd >> out4; sh -c pwd >> out4; } | cat", "sub/out4", "a\n" + sub + "\n" + sub},
+		{"cd sub; echo $PWD > out5; cd ..", "sub/out5", sub},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if status := run(t, e, tt.input); status != 0 {
+				t.Fatalf("Run(%q) status = %d, want 0", tt.input, status)
+			}
+			if got := readFile(t, dir, tt.file); got != tt.want {
+				t.Errorf("Run(%q) output = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandPath(t *testing.T) {
+	dir := chdirTemp(t)
+	if err := os.Mkdir(filepath.Join(dir, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\necho found\n"
+	if err := os.WriteFile(filepath.Join(dir, "bin", "mycmd"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input  string
+		status int
+	}{
+		{`export PATH="$PATH:$PWD/bin"; mycmd > out`, 0},
+		{`PATH="$PATH:$PWD/bin"; mycmd > out`, 0},
+		{`PATH=$PWD/bin mycmd > out`, 0},
+		{`PATH=/nonexistent; sh -c true`, 127},
+		{`mycmd`, 127},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			os.Remove(filepath.Join(dir, "out"))
+			if status := run(t, New(), tt.input); status != tt.status {
+				t.Fatalf("Run(%q) status = %d, want %d", tt.input, status, tt.status)
+			}
+			if tt.status != 0 {
+				return
+			}
+			if got := readFile(t, dir, "out"); got != "found" {
+				t.Errorf("Run(%q) output = %q, want %q", tt.input, got, "found")
+			}
+		})
+	}
+}
+
+func TestVariables(t *testing.T) {
+	dir := chdirTemp(t)
+	e := New()
+
+	tests := []struct {
+		input string
+		file  string
+		want  string
+	}{
+		{"GREETING=hello; echo $GREETING world > out1", "out1", "hello world"},
+		{"sh -c 'echo ${GREETING:-unset}' > out2", "out2", "unset"},
+		{"export GREETING; sh -c 'echo $GREETING' > out3", "out3", "hello"},
+		{"ONCE=1 sh -c 'echo $ONCE' > out4; echo ${ONCE:-gone} >> out4", "out4", "1\ngone"},
+		{"unset GREETING; echo ${GREETING-unset} > out5", "out5", "unset"},
+		{"export A=1 B=2; env | grep -c '^[AB]=' > out6", "out6", "2"},
+		{"env -u A C=3 sh -c 'echo ${A:-none}$C' > out7", "out7", "none3"},
+		{"X=a; X=${X}b; echo $X > out8", "out8", "ab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if status := run(t, e, tt.input); status != 0 {
+				t.Fatalf("Run(%q) status = %d, want 0", tt.input, status)
+			}
+			if got := readFile(t, dir, tt.file); got != tt.want {
+				t.Errorf("Run(%q) output = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	// An expansion error, such as a missing parameter required with
+	// ${name:?}, stops a script, and the rest of the line typed into an
+	// interactive shell.
+	for _, input := range []string{"echo ${NOPE:?missing}", "echo $((1/0))"} {
+		interactive := New()
+		interactive.SetInteractive(true)
+		script := New()
+		for _, sh := range []*Executor{interactive, script} {
+			if status := run(t, sh, input+" 2>/dev/null; echo after > out9"); status == 0 {
+				t.Errorf("Run(%q) status = 0, want non-zero", input)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "out9")); err == nil {
+				t.Errorf("Run(%q) ran the command after it", input)
+			}
+		}
+		if interactive.Exited() || !script.Exited() {
+			t.Errorf("Run(%q) Exited() = %v interactive, %v not, want false, true", input, interactive.Exited(), script.Exited())
+		}
+	}
+	// A failed redirection is not an expansion error.
+	run(t, e, "> nodir/out 2>/dev/null; echo after > out9")
+	if got := readFile(t, dir, "out9"); got != "after" || e.Exited() {
+		t.Errorf("failed redirection stopped the script")
+	}
+}
+
+func TestBackgroundJobs(t *testing.T) {
+	dir := chdirTemp(t)
+	e := New()
+
+	tests := []struct {
+		input string
+		file  string
+		want  string
+	}{
+		{"sh -c 'echo bg > out1' & wait", "out1", "bg"},
+		{"false || echo list > out2 & wait", "out2", "list"},
+		{"sleep 0.1 & jobs > out3; wait", "out3", "[1]+  Running                 sleep 0.1 &"},
+		{"true & echo ${!:+set} > out4; wait", "out4", "set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if status := run(t, e, tt.input); status != 0 {
+				t.Fatalf("Run(%q) status = %d, want 0", tt.input, status)
+			}
+			if got := readFile(t, dir, tt.file); got != tt.want {
+				t.Errorf("Run(%q) output = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	if status := run(t, e, "true & sh -c 'exit 3' & wait %2"); status != 3 {
+		t.Errorf("wait %%2 status = %d, want 3", status)
+	}
+	if status := run(t, e, "fg %9"); status == 0 {
+		t.Error("fg %9 status = 0, want non-zero")
+	}
+
+	// Background commands are expanded in their own subshell, so their
+	// assignments and expansions leave the shell as it was.
+	for _, input := range []string{
+		"x=1 & wait",
+		"echo $(x=1) ${x:=2} $((x=3)) > /dev/null & wait",
+	} {
+		run(t, e, input+`; echo "[$x]" > out5`)
+		if got := readFile(t, dir, "out5"); got != "[]" {
+			t.Errorf("Run(%q): x = %q, want []", input, got)
+		}
+	}
+}
+
+func TestStoppedJobs(t *testing.T) {
+	dir := chdirTemp(t)
+	e := New()
+
+	// A job that stops is added to the job table and can be resumed.
+	if status := run(t, e, "sh -c 'kill -STOP $$; echo resumed > out'"); status != 128+int(syscall.SIGSTOP) {
+		t.Fatalf("stopped job status = %d, want %d", status, 128+int(syscall.SIGSTOP))
+	}
+	if status := run(t, e, "jobs > jobs"); status != 0 {
+		t.Fatalf("jobs status = %d", status)
+	}
+	if got, want := readFile(t, dir, "jobs"), "[1]+  Stopped                 sh -c 'kill -STOP $$; echo resumed > out'"; got != want {
+		t.Errorf("jobs output = %q, want %q", got, want)
+	}
+	if status := run(t, e, "fg > /dev/null"); status != 0 {
+		t.Fatalf("fg status = %d, want 0", status)
+	}
+	if got := readFile(t, dir, "out"); got != "resumed" {
+		t.Errorf("resumed job output = %q, want %q", got, "resumed")
+	}
+	if len(e.jobs.jobs) != 0 {
+		t.Errorf("job table has %d jobs after fg, want 0", len(e.jobs.jobs))
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	dir := chdirTemp(t)
+	e := New()
+
+	// Interrupting a foreground builtin cancels it and abandons the rest
+	// of the input.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		e.Interrupt()
+	}()
+	start := time.Now()
+	if status := run(t, e, "env sleep 5; echo after > out"); status != 130 {
+		t.Errorf("interrupted status = %d, want 130", status)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("interrupted command took %v", elapsed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out")); err == nil {
+		t.Error("command after the interrupt was run")
+	}
+
+	// The next input runs normally.
+	if status := run(t, e, "echo next > out"); status != 0 || readFile(t, dir, "out") != "next" {
+		t.Errorf("command after interrupt status = %d", status)
+	}
+}
+
+func TestPositionalParams(t *testing.T) {
+	dir := chdirTemp(t)
+	e := New()
+	e.SetParams("script", []string{"a", "b c"})
+
+	tests := []struct {
+		input string
+		file  string
+		want  string
+	}{
+		{"echo $0 $# $1 > out1", "out1", "script 2 a"},
+		{`printf '<%s>' "$@" > out2`, "out2", "<a><b c>"},
+		{`printf '<%s>' "x$@y" > out3`, "out3", "<xa><b cy>"},
+		{"shift; echo $# $1 > out4", "out4", "1 b c"},
+		{`set -- ; printf '<%s>' "$@" > out5; echo $# >> out5`, "out5", "<>0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if status := run(t, e, tt.input); status != 0 {
+				t.Fatalf("Run(%q) status = %d, want 0", tt.input, status)
+			}
+			if got := readFile(t, dir, tt.file); got != tt.want {
+				t.Errorf("Run(%q) output = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSource(t *testing.T) {
+	dir := chdirTemp(t)
+	e := New()
+
+	script := "SOURCED=yes\necho \"$# $1\"\nfalse\n"
+	if err := os.WriteFile(filepath.Join(dir, "lib.gosh"), []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := run(t, e, "source ./lib.gosh a b > out1"); status != 1 {
+		t.Errorf("source status = %d, want 1", status)
+	}
+	if got := readFile(t, dir, "out1"); got != "2 a" {
+		t.Errorf("source output = %q, want %q", got, "2 a")
+	}
+	if status := run(t, e, ". lib.gosh > out2; echo $SOURCED >> out2"); status != 0 {
+		t.Errorf(". status = %d, want 0", status)
+	}
+	if got := readFile(t, dir, "out2"); got != "0 \nyes" {
+		t.Errorf(". output = %q, want %q", got, "0 \nyes")
+	}
+	if status := run(t, e, "source missing.gosh 2>/dev/null"); status == 0 {
+		t.Error("source of a missing file succeeded")
+	}
+
+	// An alias defined in a file applies to its later lines, including
+	// the functions defined there.
+	script = "alias greet='echo hi'\ngreet > out3\nf() { greet; }\nf >> out3\n"
+	if err := os.WriteFile(filepath.Join(dir, "aliases.gosh"), []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if status := run(t, e, "source aliases.gosh"); status != 0 {
+		t.Errorf("source aliases.gosh status = %d, want 0", status)
+	}
+	if got := readFile(t, dir, "out3"); got != "hi\nhi" {
+		t.Errorf("aliases output = %q, want %q", got, "hi\nhi")
+	}
+}
+
+func TestAliases(t *testing.T) {
+	dir := chdirTemp(t)
+	e := New()
+
+	// An alias takes effect from the next parse, not the line that
+	// defines it.
+	run(t, e, "alias say='echo said' words='echo one | cat >> out; echo two'")
+	run(t, e, "say hi > out; words >> out")
+	if got := readFile(t, dir, "out"); got != "said hi\none\ntwo" {
+		t.Errorf("alias output = %q", got)
+	}
+
+	run(t, e, "alias say words > list")
+	want := "alias say='echo said'\nalias words='echo one | cat >> out; echo two'"
+	if got := readFile(t, dir, "list"); got != want {
+		t.Errorf("alias listing = %q, want %q", got, want)
+	}
+
+	if status := run(t, e, "unalias say missing 2>/dev/null"); status != 1 {
+		t.Errorf("unalias status = %d, want 1", status)
+	}
+	if status := run(t, e, "say 2>/dev/null"); status != 127 {
+		t.Errorf("status after unalias = %d, want 127", status)
+	}
+	if status := run(t, e, "alias 'a b=x' 2>/dev/null"); status == 0 {
+		t.Error("alias with an invalid name succeeded")
+	}
+	run(t, e, "unalias -a")
+	if len(e.Aliases()) != 0 {
+		t.Errorf("aliases after unalias -a = %v", e.Aliases())
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	dir := chdirTemp(t)
+	e := New()
+
+	src := `x=global
+count() { echo "$# $1"; }
+outer() {
+	local x=outer y
+	inner "$@"
+	return 4
+	echo unreachable
+}
+inner() { echo "inner $x [$y] $2"; }
+function quiet { echo "$V"; } > quiet.out
+count a "b c" > out
+outer 1 2 >> out
+echo "$? $x" >> out
+V=tmp quiet
+{ echo block; count; } | cat >> out`
+	if status := run(t, e, src); status != 0 {
+		t.Errorf("Run() status = %d, want 0", status)
+	}
+	want := "2 a\ninner outer [] 2\n4 global\nblock\n0 "
+	if got := readFile(t, dir, "out"); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	if got := readFile(t, dir, "quiet.out"); got != "tmp" {
+		t.Errorf("quiet.out = %q, want %q", got, "tmp")
+	}
+	if v, ok := e.Vars().Get("V"); ok {
+		t.Errorf("V = %q after the call, want unset", v)
+	}
+
+	// Functions hide builtins and are resolved by type.
+	run(t, e, "pwd() { echo mine; }; pwd > pwd.out; type pwd cd > type.out")
+	if got := readFile(t, dir, "pwd.out"); got != "mine" {
+		t.Errorf("pwd output = %q, want %q", got, "mine")
+	}
+	want = "pwd is a function\npwd() { echo mine; }\ncd is a shell builtin"
+	if got := readFile(t, dir, "type.out"); got != want {
+		t.Errorf("type output = %q, want %q", got, want)
+	}
+
+	for _, input := range []string{"return 1", "local x"} {
+		if status := run(t, e, input+" 2>/dev/null"); status != 1 {
+			t.Errorf("%s outside a function: status = %d, want 1", input, status)
+		}
+	}
+}
+
+func TestControlFlow(t *testing.T) {
+	dir := chdirTemp(t)
+	e := New()
+
+	src := `for i in 1 2 3 4 5; do
+	if [ $i -eq 2 ]; then continue
+	elif test $i -ge 4; then break
+	else echo "i $i"
+	fi
+done > out
+for a in x y; do
+	for b in 1 2; do
+		[ $b = 2 ] && continue 2
+		echo "$a$b"
+	done
+	echo unreachable
+done >> out
+until [ -f stop ]; do echo loop > stop; done; cat stop >> out
+while true; do while true; do break 2; done; echo unreachable; done
+set -- p "q r"
+for arg; do echo "arg $arg"; done >> out
+for f in *.none; do echo "glob $f"; done >> out
+case main.go in
+	*.txt) echo text ;;
+	(*.c | *.go) echo source; echo again ;;
+	*) echo other
+esac >> out`
+	if status := run(t, e, src); status != 0 {
+		t.Errorf("Run() status = %d, want 0", status)
+	}
+	want := "i 1\ni 3\nx1\ny1\nloop\narg p\narg q r\nglob *.none\nsource\nagain"
+	if got := readFile(t, dir, "out"); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		src  string
+		want int
+	}{
+		{"if false; then true; fi", 0},
+		{"if false; then true; else false; fi", 1},
+		{"for x in; do false; done", 0},
+		{"case a in b) false;; esac", 0},
+		{"[ abc ]", 0},
+		{"[ -n '' ]", 1},
+		{"[ ! -d . -o -e . ]", 0},
+		{"test 10 -gt 9 -a \\( x != y \\)", 0},
+		{"[ 1 -lt ] 2>/dev/null", 2},
+		{"[ a 2>/dev/null", 2},
+		{"break 2>/dev/null", 1},
+		{"f() { break; }; for x in a; do f 2>/dev/null; done", 1},
+	}
+	for _, tt := range tests {
+		if status := run(t, e, tt.src); status != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.src, status, tt.want)
+		}
+	}
+}
+
+func TestSubstitutions(t *testing.T) {
+	dir := chdirTemp(t)
+	e := New()
+
+	src := `x=$(echo one; echo; echo)
+echo "[$x]" "$(pwd | wc -l)" ` + "`echo back`" + ` > out
+f() { echo "fn $1"; }
+echo "$(f a)" "$(cd /; pwd)" "$(ls)" >> out
+y=$(exit 3)
+echo "$? $(pwd)" >> out
+cat <(echo proc) >> out
+wc -l < <(echo a; echo b) >> out
+cat <(cd /; pwd) >> out; pwd >> out
+echo tee > >(cat > tee.out)`
+	if status := run(t, e, src); status != 0 {
+		t.Errorf("Run() status = %d, want 0", status)
+	}
+	want := "[one] 1 back\nfn a / out\n3 " + dir + "\nproc\n2\n/\n" + dir
+	if got := readFile(t, dir, "out"); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	// The consumer of >(...) is not waited for.
+	var got []byte
+	for i := 0; i < 100 && len(got) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		got, _ = os.ReadFile(filepath.Join(dir, "tee.out"))
+	}
+	if string(got) != "tee\n" {
+		t.Errorf("tee.out = %q, want %q", got, "tee\n")
+	}
+}
+
+func TestHeredocs(t *testing.T) {
+	dir := chdirTemp(t)
+	e := New()
+
+	src := `x=world
+cat <<EOF > out
+hello $x \$x "$(echo sub)" 'q'
+EOF
+cat <<'EOF' >> out
+raw $x
+EOF
+f() {
+	cat <<-END
+		in $1
+	END
+}
+f fn >> out
+tr a-z A-Z <<< "here $x" >> out
+cat 3<<A <&3 >> out; cat <<B >> out
+three
+A
+four
+B`
+	if status := run(t, e, src); status != 0 {
+		t.Errorf("Run() status = %d, want 0", status)
+	}
+	want := "hello world $x \"sub\" 'q'\nraw $x\nin fn\nHERE WORLD\nthree\nfour"
+	if got := readFile(t, dir, "out"); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
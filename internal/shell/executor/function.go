@@ -0,0 +1,254 @@
+// internal/shell/executor/function.go
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/krzko/gosh/internal/shell/ast"
+	"github.com/krzko/gosh/internal/shell/command"
+	"github.com/krzko/gosh/internal/shell/parser"
+)
+
+// maxCallDepth limits how deeply functions may call each other, so that
+// runaway recursion fails rather than exhausting memory.
+const maxCallDepth = 1000
+
+// callFunction runs fn with args as its positional parameters and returns
+// its status. Assignments given before the function name in env are
+// exported to it for the duration of the call.
+func (e *Executor) callFunction(fn *ast.FuncDecl, args, env []string) int {
+	if e.callDepth >= maxCallDepth {
+		e.errorf("%s: maximum function nesting level exceeded (%d)", fn.Name.Value, maxCallDepth)
+		return 1
+	}
+
+	savedParams, savedLoopDepth := e.params, e.loopDepth
+	e.params, e.loopDepth = args, 0
+	e.vars.PushScope()
+	e.callDepth++
+	defer func() {
+		e.callDepth--
+		e.vars.PopScope()
+		e.params, e.loopDepth = savedParams, savedLoopDepth
+	}()
+
+	for _, as := range env {
+		name, value, _ := strings.Cut(as, "=")
+		e.vars.Local(name)
+		e.vars.Set(name, value)
+		e.vars.Export(name)
+	}
+
+	status := e.runStmt(fn.Body)
+	e.returning = false
+	return status
+}
+
+// returnRequest is returned by the return builtin to stop the function or
+// sourced file that is running.
+type returnRequest struct {
+	status int
+}
+
+func (r *returnRequest) Error() string {
+	return fmt.Sprintf("return %d", r.status)
+}
+
+// ReturnCommand stops the running function or sourced file.
+type ReturnCommand struct {
+	executor *Executor
+}
+
+func (c *ReturnCommand) Execute(ctx *command.Context, args []string) error {
+	if c.executor.callDepth == 0 {
+		return fmt.Errorf("can only `return' from a function or sourced script")
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+	status := c.executor.status
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("%s: numeric argument required", args[0])
+		}
+		status = n & 0xff
+	}
+	return &returnRequest{status: status}
+}
+
+func (c *ReturnCommand) Help() string {
+	return `return: Return from a function or sourced file
+Usage: return [n]
+
+Returns with status n, or with the status of the last command run if n
+is omitted.`
+}
+
+// LocalCommand declares variables local to the running function.
+type LocalCommand struct {
+	executor *Executor
+}
+
+func (c *LocalCommand) Execute(ctx *command.Context, args []string) error {
+	if c.executor.callDepth == 0 {
+		return fmt.Errorf("can only be used in a function")
+	}
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !parser.IsName(name) {
+			return fmt.Errorf("`%s': not a valid identifier", arg)
+		}
+		if !ctx.Vars.Local(name) {
+			return fmt.Errorf("can only be used in a function")
+		}
+		if hasValue {
+			ctx.Vars.Set(name, value)
+		}
+	}
+	return nil
+}
+
+func (c *LocalCommand) Help() string {
+	return `local: Declare local variables
+Usage: local name[=value] ...
+
+Each variable is visible to the function and the functions it calls,
+and hides any variable of the same name until the function returns.
+Without a value, the variable starts out unset.`
+}
+
+// keywords are the reserved words of the shell's grammar.
+var keywords = map[string]bool{
+	"{": true, "}": true, "!": true, "function": true,
+	"if": true, "then": true, "elif": true, "else": true, "fi": true,
+	"while": true, "until": true, "for": true, "in": true, "do": true, "done": true,
+	"case": true, "esac": true,
+}
+
+// resolve reports what name refers to when used as a command, in the order
+// the shell looks it up: "alias", "keyword", "function", "builtin" or
+// "file", with the alias's value, the function's definition or the
+// file's path as detail. kind is empty if name is not found.
+func (e *Executor) resolve(name string) (kind, detail string) {
+	if value, ok := e.aliases[name]; ok {
+		return "alias", value
+	}
+	if keywords[name] {
+		return "keyword", ""
+	}
+	if fn, ok := e.funcs[name]; ok {
+		return "function", ast.String(fn)
+	}
+	if _, ok := e.builtins[name]; ok {
+		return "builtin", ""
+	}
+	if path, ok := e.lookPath(name, nil); ok {
+		return "file", path
+	}
+	return "", ""
+}
+
+// describe returns a sentence saying what name refers to, as shown by
+// type.
+func (e *Executor) describe(name string) (string, bool) {
+	kind, detail := e.resolve(name)
+	switch kind {
+	case "alias":
+		return fmt.Sprintf("%s is aliased to `%s'", name, detail), true
+	case "keyword":
+		return fmt.Sprintf("%s is a shell keyword", name), true
+	case "function":
+		return fmt.Sprintf("%s is a function\n%s", name, detail), true
+	case "builtin":
+		return fmt.Sprintf("%s is a shell builtin", name), true
+	case "file":
+		return fmt.Sprintf("%s is %s", name, detail), true
+	}
+	return "", false
+}
+
+// lookPath finds the executable file a command name runs, searching the
+// shell's PATH, or the one among env, the assignments given before the
+// command name, if it is set there.
+func (e *Executor) lookPath(name string, env []string) (string, bool) {
+	executable := func(path string) bool {
+		info, err := os.Stat(e.path(path))
+		return err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0
+	}
+	if strings.Contains(name, "/") {
+		return name, executable(name)
+	}
+	path, _ := e.vars.Get("PATH")
+	for _, as := range env {
+		if value, ok := strings.CutPrefix(as, "PATH="); ok {
+			path = value
+		}
+	}
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			dir = "."
+		}
+		if file := filepath.Join(dir, name); executable(file) {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// TypeCommand shows how names would be interpreted as commands.
+type TypeCommand struct {
+	executor *Executor
+}
+
+func (c *TypeCommand) Execute(ctx *command.Context, args []string) error {
+	kindOnly := false
+	if len(args) > 0 && args[0] == "-t" {
+		kindOnly = true
+		args = args[1:]
+	}
+
+	var err error
+	for _, name := range args {
+		if kindOnly {
+			if kind, _ := c.executor.resolve(name); kind != "" {
+				fmt.Fprintln(ctx.Stdout, kind)
+				continue
+			}
+			err = command.ExitStatus(1)
+			continue
+		}
+		description, ok := c.executor.describe(name)
+		if !ok {
+			fmt.Fprintf(ctx.Stderr, "type: %s: not found\n", name)
+			err = command.ExitStatus(1)
+			continue
+		}
+		fmt.Fprintln(ctx.Stdout, description)
+	}
+	return err
+}
+
+func (c *TypeCommand) Help() string {
+	return `type: Show how names are interpreted as commands
+Usage: type [-t] name ...
+
+Names are looked up as the shell does: aliases, keywords, functions,
+builtins, then files in PATH.
+
+Options:
+  -t    print only alias, keyword, function, builtin or file`
+}
+
+// describeForHelp tells help what a name resolves to when that is not
+// simply a builtin, such as an alias or function hiding one.
+func (e *Executor) describeForHelp(name string) (string, bool) {
+	if kind, _ := e.resolve(name); kind == "builtin" {
+		return "", false
+	}
+	return e.describe(name)
+}
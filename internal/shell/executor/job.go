@@ -0,0 +1,356 @@
+// internal/shell/executor/job.go
+package executor
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+type jobState int
+
+const (
+	jobRunning jobState = iota
+	jobStopped
+	jobDone
+)
+
+// process is a single command of a job: an external process, or a builtin
+// or subshell running on its own goroutine, which has no pid and reports
+// its status on done.
+type process struct {
+	pid    int
+	proc   *os.Process
+	done   chan int
+	state  jobState
+	status int
+	// signal is the signal that killed the process, if any.
+	signal syscall.Signal
+}
+
+// update collects a change in the process's state. With block set it
+// waits for one; otherwise it only picks up a change that has already
+// happened.
+func (p *process) update(block bool) {
+	if p.state == jobDone {
+		return
+	}
+	if p.pid == 0 {
+		if block {
+			p.finish(<-p.done)
+			return
+		}
+		select {
+		case status := <-p.done:
+			p.finish(status)
+		default:
+		}
+		return
+	}
+
+	flags := syscall.WUNTRACED | syscall.WCONTINUED
+	if !block {
+		flags |= syscall.WNOHANG
+	}
+	var ws syscall.WaitStatus
+	for {
+		pid, err := syscall.Wait4(p.pid, &ws, flags, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			// The process has already been reaped, which only happens
+			// if it was never ours to wait for.
+			p.finish(127)
+			return
+		}
+		if pid == 0 {
+			return
+		}
+		break
+	}
+
+	switch {
+	case ws.Stopped():
+		p.state = jobStopped
+		p.status = 128 + int(ws.StopSignal())
+	case ws.Continued():
+		p.state = jobRunning
+	case ws.Signaled():
+		p.signal = ws.Signal()
+		p.finish(128 + int(ws.Signal()))
+	default:
+		p.finish(ws.ExitStatus())
+	}
+}
+
+func (p *process) finish(status int) {
+	p.state = jobDone
+	p.status = status
+	if p.proc != nil {
+		p.proc.Release()
+		p.proc = nil
+	}
+}
+
+// job is a pipeline, or a list run in the background, that the shell
+// tracks as a unit. With job control enabled, its external processes
+// share the process group pgid.
+type job struct {
+	id         int
+	pgid       int
+	text       string
+	procs      []*process
+	foreground bool
+	// reported is the last state the user was told about, so that each
+	// change is only announced once.
+	reported jobState
+}
+
+func (j *job) state() jobState {
+	state := jobDone
+	for _, p := range j.procs {
+		switch p.state {
+		case jobRunning:
+			return jobRunning
+		case jobStopped:
+			state = jobStopped
+		}
+	}
+	return state
+}
+
+func (j *job) statuses() []int {
+	statuses := make([]int, len(j.procs))
+	for i, p := range j.procs {
+		statuses[i] = p.status
+	}
+	return statuses
+}
+
+// pid returns the pid reported for the job: that of its last external
+// process, or 0 if it has none.
+func (j *job) pid() int {
+	for i := len(j.procs) - 1; i >= 0; i-- {
+		if j.procs[i].pid != 0 {
+			return j.procs[i].pid
+		}
+	}
+	return 0
+}
+
+// poll collects any state changes without blocking.
+func (j *job) poll() {
+	for _, p := range j.procs {
+		p.update(false)
+	}
+}
+
+// waitExternal blocks until each of the job's external processes has
+// finished or stopped.
+func (j *job) waitExternal() {
+	for _, p := range j.procs {
+		for p.pid != 0 && p.state == jobRunning {
+			p.update(true)
+		}
+	}
+}
+
+// killedBy reports whether any of the job's processes was killed by sig.
+func (j *job) killedBy(sig syscall.Signal) bool {
+	for _, p := range j.procs {
+		if p.signal == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// wait blocks until the job finishes or stops. External processes are
+// waited for first, so that a job stopped with Ctrl-Z is noticed even if
+// one of its builtins is still running.
+func (j *job) wait() {
+	j.waitExternal()
+	if j.state() == jobStopped {
+		return
+	}
+	for _, p := range j.procs {
+		p.update(true)
+	}
+}
+
+// signal sends sig to every external process in the job.
+func (j *job) signal(sig syscall.Signal) error {
+	if j.pgid != 0 {
+		return syscall.Kill(-j.pgid, sig)
+	}
+	for _, p := range j.procs {
+		if p.pid != 0 && p.state != jobDone {
+			if err := syscall.Kill(p.pid, sig); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// continued marks the job's stopped processes as running again after
+// SIGCONT has been sent.
+func (j *job) continued() {
+	for _, p := range j.procs {
+		if p.state == jobStopped {
+			p.state = jobRunning
+		}
+	}
+}
+
+// jobTable holds the jobs the shell is tracking: those started in the
+// background and those stopped while in the foreground.
+type jobTable struct {
+	jobs []*job
+	// recent orders jobs from least to most recently started, stopped or
+	// resumed. The last is the current job, %+, and the one before it
+	// the previous job, %-.
+	recent []*job
+}
+
+func (t *jobTable) add(j *job) {
+	if j.id == 0 {
+		j.id = 1
+		if n := len(t.jobs); n > 0 {
+			j.id = t.jobs[n-1].id + 1
+		}
+		t.jobs = append(t.jobs, j)
+	}
+	t.touch(j)
+}
+
+func (t *jobTable) touch(j *job) {
+	t.recent = removeJob(t.recent, j)
+	t.recent = append(t.recent, j)
+}
+
+func (t *jobTable) remove(j *job) {
+	t.jobs = removeJob(t.jobs, j)
+	t.recent = removeJob(t.recent, j)
+}
+
+func removeJob(jobs []*job, j *job) []*job {
+	for i, other := range jobs {
+		if other == j {
+			return append(jobs[:i:i], jobs[i+1:]...)
+		}
+	}
+	return jobs
+}
+
+func (t *jobTable) contains(j *job) bool {
+	for _, other := range t.jobs {
+		if other == j {
+			return true
+		}
+	}
+	return false
+}
+
+// mark returns the character shown next to a job in listings: '+' for
+// the current job, '-' for the previous one and ' ' otherwise.
+func (t *jobTable) mark(j *job) byte {
+	n := len(t.recent)
+	switch {
+	case n > 0 && t.recent[n-1] == j:
+		return '+'
+	case n > 1 && t.recent[n-2] == j:
+		return '-'
+	}
+	return ' '
+}
+
+// find resolves a job specification: %n or n for job number n, %+ or %%
+// for the current job, %- for the previous one, %name for the job whose
+// command starts with name and %?text for the one containing text.
+func (t *jobTable) find(spec string) (*job, error) {
+	ref := strings.TrimPrefix(spec, "%")
+	switch ref {
+	case "", "+", "%":
+		if n := len(t.recent); n > 0 {
+			return t.recent[n-1], nil
+		}
+		return nil, fmt.Errorf("%s: no current job", spec)
+	case "-":
+		if n := len(t.recent); n > 1 {
+			return t.recent[n-2], nil
+		}
+		return nil, fmt.Errorf("%s: no previous job", spec)
+	}
+
+	if id, err := strconv.Atoi(ref); err == nil {
+		for _, j := range t.jobs {
+			if j.id == id {
+				return j, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+
+	var found *job
+	for _, j := range t.jobs {
+		var ok bool
+		if text, contains := strings.CutPrefix(ref, "?"); contains {
+			ok = strings.Contains(j.text, text)
+		} else {
+			ok = strings.HasPrefix(j.text, ref)
+		}
+		if !ok {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%s: ambiguous job spec", spec)
+		}
+		found = j
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+	return found, nil
+}
+
+// format returns the line describing a job in listings and
+// notifications, such as "[1]+  Running                 sleep 10 &".
+// With long set the job's pid is included.
+func (t *jobTable) format(j *job, long bool) string {
+	state := "Running"
+	switch j.state() {
+	case jobStopped:
+		state = "Stopped"
+	case jobDone:
+		state = "Done"
+		if status := j.procs[len(j.procs)-1].status; status > 128 {
+			state = signalName(syscall.Signal(status - 128))
+		} else if status != 0 {
+			state = fmt.Sprintf("Exit %d", status)
+		}
+	}
+
+	text := j.text
+	if j.state() == jobRunning {
+		text += " &"
+	}
+
+	if long {
+		return fmt.Sprintf("[%d]%c %d %-24s%s", j.id, t.mark(j), j.pid(), state, text)
+	}
+	return fmt.Sprintf("[%d]%c  %-24s%s", j.id, t.mark(j), state, text)
+}
+
+// signalName returns a signal's description with an initial capital, as
+// in "Terminated" or "Killed".
+func signalName(sig syscall.Signal) string {
+	name := sig.String()
+	if name == "" {
+		return fmt.Sprintf("Signal %d", int(sig))
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
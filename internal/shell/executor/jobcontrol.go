@@ -0,0 +1,74 @@
+// internal/shell/executor/jobcontrol.go
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// EnableJobControl turns on job control if the shell's standard input is
+// the terminal and the shell is in its foreground process group. Each job
+// then runs in its own process group, which is handed the terminal while
+// it runs in the foreground, so that Ctrl-C and Ctrl-Z reach the job and
+// not the shell.
+func (e *Executor) EnableJobControl() error {
+	tty := int(e.stdin.Fd())
+	if !term.IsTerminal(tty) {
+		return nil
+	}
+	fg, err := unix.IoctlGetInt(tty, unix.TIOCGPGRP)
+	if err != nil {
+		return err
+	}
+	pgid := syscall.Getpgrp()
+	if fg != pgid {
+		return fmt.Errorf("job control disabled: not in the terminal's foreground process group")
+	}
+
+	// The shell itself must never be stopped from the terminal. The
+	// signals are caught rather than ignored so that commands the shell
+	// starts get their default dispositions back.
+	signal.Notify(make(chan os.Signal, 1), syscall.SIGTSTP, syscall.SIGTTIN)
+
+	if pid := os.Getpid(); pgid != pid {
+		if err := syscall.Setpgid(0, 0); err != nil {
+			return err
+		}
+		pgid = pid
+	}
+
+	e.tty = tty
+	e.pgid = pgid
+	e.jobControl = true
+	return e.setForeground(pgid)
+}
+
+// setForeground makes pgid the terminal's foreground process group.
+func (e *Executor) setForeground(pgid int) error {
+	// Changing the foreground group from the background raises SIGTTOU
+	// unless it is ignored. It is only ignored for the duration of the
+	// call, since ignored signals are inherited by child processes.
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+	return unix.IoctlSetPointerInt(e.tty, unix.TIOCSPGRP, pgid)
+}
+
+// procAttr returns the attributes for an external process that is part
+// of j: with job control, it joins the job's process group, or starts
+// one, taking the terminal if the job is in the foreground.
+func (e *Executor) procAttr(j *job) *syscall.SysProcAttr {
+	if !e.jobControl {
+		return nil
+	}
+	attr := &syscall.SysProcAttr{Setpgid: true, Pgid: j.pgid}
+	if j.pgid == 0 && j.foreground {
+		attr.Foreground = true
+		attr.Ctty = e.tty
+	}
+	return attr
+}
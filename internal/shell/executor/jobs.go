@@ -0,0 +1,305 @@
+// internal/shell/executor/jobs.go
+package executor
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/krzko/gosh/internal/shell/command"
+)
+
+// NotifyJobs reports background jobs that have finished or stopped since
+// they were last reported, as "[1]+  Done  sleep 10". Finished jobs are
+// then removed from the job table. The shell calls it before each prompt.
+func (e *Executor) NotifyJobs(w io.Writer) {
+	for _, j := range append([]*job(nil), e.jobs.jobs...) {
+		j.poll()
+		state := j.state()
+		if state == j.reported {
+			continue
+		}
+		if state != jobRunning {
+			fmt.Fprintln(w, e.jobs.format(j, false))
+		}
+		j.reported = state
+		if state == jobDone {
+			e.jobs.remove(j)
+		}
+	}
+}
+
+// findJobs resolves job specifications, or returns every job if there
+// are none.
+func (e *Executor) findJobs(specs []string) ([]*job, error) {
+	if len(specs) == 0 {
+		return append([]*job(nil), e.jobs.jobs...), nil
+	}
+	var jobs []*job
+	for _, spec := range specs {
+		j, err := e.jobs.find(spec)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+// JobsCommand lists the shell's jobs.
+type JobsCommand struct {
+	executor *Executor
+}
+
+func (c *JobsCommand) Execute(ctx *command.Context, args []string) error {
+	long, pidsOnly := false, false
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'l':
+				long = true
+			case 'p':
+				pidsOnly = true
+			default:
+				return fmt.Errorf("-%c: invalid option", flag)
+			}
+		}
+		args = args[1:]
+	}
+
+	jobs, err := c.executor.findJobs(args)
+	if err != nil {
+		return err
+	}
+	for _, j := range jobs {
+		j.poll()
+		if pidsOnly {
+			fmt.Fprintln(ctx.Stdout, j.pid())
+			continue
+		}
+		fmt.Fprintln(ctx.Stdout, c.executor.jobs.format(j, long))
+		// A finished job is reported once, here or at the next prompt.
+		j.reported = j.state()
+		if j.reported == jobDone {
+			c.executor.jobs.remove(j)
+		}
+	}
+	return nil
+}
+
+func (c *JobsCommand) Help() string {
+	return `jobs: List background and stopped jobs
+Usage: jobs [-lp] [jobspec...]
+
+Options:
+  -l    include each job's process ID
+  -p    list only process IDs
+
+A jobspec is %n for job n, %+ or %% for the current job, %- for the
+previous job, %name for the job whose command starts with name and
+%?text for the job whose command contains text.`
+}
+
+// FgCommand moves a job to the foreground, resuming it if stopped.
+type FgCommand struct {
+	executor *Executor
+}
+
+func (c *FgCommand) Execute(ctx *command.Context, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+	j, err := c.executor.jobs.find(strings.Join(args, ""))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(ctx.Stdout, j.text)
+	j.foreground = true
+	if c.executor.jobControl && j.pgid != 0 {
+		if err := c.executor.setForeground(j.pgid); err != nil {
+			return err
+		}
+	}
+	if err := c.executor.resume(j); err != nil {
+		return err
+	}
+	if status := c.executor.waitForeground(j); status != 0 {
+		return command.ExitStatus(status)
+	}
+	return nil
+}
+
+func (c *FgCommand) Help() string {
+	return `fg: Move a job to the foreground
+Usage: fg [jobspec]
+
+Resumes the job if it is stopped and waits for it. Without a jobspec,
+the current job is used.`
+}
+
+// BgCommand resumes stopped jobs in the background.
+type BgCommand struct {
+	executor *Executor
+}
+
+func (c *BgCommand) Execute(ctx *command.Context, args []string) error {
+	if len(args) == 0 {
+		args = []string{"%+"}
+	}
+	for _, spec := range args {
+		j, err := c.executor.jobs.find(spec)
+		if err != nil {
+			return err
+		}
+		if j.state() != jobStopped {
+			return fmt.Errorf("job %d already in background", j.id)
+		}
+		if err := c.executor.resume(j); err != nil {
+			return err
+		}
+		c.executor.jobs.touch(j)
+		fmt.Fprintf(ctx.Stdout, "[%d]%c %s &\n", j.id, c.executor.jobs.mark(j), j.text)
+	}
+	return nil
+}
+
+func (c *BgCommand) Help() string {
+	return `bg: Resume jobs in the background
+Usage: bg [jobspec...]
+
+Without a jobspec, the current job is resumed.`
+}
+
+// resume continues a stopped job.
+func (e *Executor) resume(j *job) error {
+	if j.state() != jobStopped {
+		return nil
+	}
+	if err := j.signal(syscall.SIGCONT); err != nil {
+		return err
+	}
+	j.continued()
+	j.reported = jobRunning
+	return nil
+}
+
+// WaitCommand waits for background jobs to finish.
+type WaitCommand struct {
+	executor *Executor
+}
+
+func (c *WaitCommand) Execute(ctx *command.Context, args []string) error {
+	e := c.executor
+	if len(args) == 0 {
+		for _, j := range append([]*job(nil), e.jobs.jobs...) {
+			if j.state() == jobRunning {
+				j.wait()
+			}
+			if j.state() == jobDone {
+				e.jobs.remove(j)
+			}
+		}
+		return nil
+	}
+
+	status := 0
+	for _, arg := range args {
+		j, err := c.find(arg)
+		if err != nil {
+			fmt.Fprintf(ctx.Stderr, "wait: %v\n", err)
+			status = 127
+			continue
+		}
+		j.wait()
+		status = e.pipelineStatus(j.statuses())
+		if j.state() == jobDone {
+			e.jobs.remove(j)
+		}
+	}
+	if status != 0 {
+		return command.ExitStatus(status)
+	}
+	return nil
+}
+
+// find resolves a jobspec or the pid of a process in one of the jobs.
+func (c *WaitCommand) find(arg string) (*job, error) {
+	if strings.HasPrefix(arg, "%") {
+		return c.executor.jobs.find(arg)
+	}
+	pid, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: not a pid or valid job spec", arg)
+	}
+	for _, j := range c.executor.jobs.jobs {
+		for _, p := range j.procs {
+			if p.pid == pid {
+				return j, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("pid %d is not a child of this shell", pid)
+}
+
+func (c *WaitCommand) Help() string {
+	return `wait: Wait for jobs to finish
+Usage: wait [pid|jobspec...]
+
+Without arguments, waits for every running background job and returns 0.
+Otherwise returns the status of the last job waited for.`
+}
+
+// DisownCommand removes jobs from the job table, so that the shell no
+// longer reports on them.
+type DisownCommand struct {
+	executor *Executor
+}
+
+func (c *DisownCommand) Execute(ctx *command.Context, args []string) error {
+	all, runningOnly := false, false
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'a':
+				all = true
+			case 'r':
+				runningOnly = true
+			default:
+				return fmt.Errorf("-%c: invalid option", flag)
+			}
+		}
+		args = args[1:]
+	}
+	if !all && len(args) == 0 {
+		args = []string{"%+"}
+	}
+
+	jobs, err := c.executor.findJobs(args)
+	if err != nil {
+		return err
+	}
+	for _, j := range jobs {
+		if runningOnly && j.state() != jobRunning {
+			continue
+		}
+		c.executor.jobs.remove(j)
+		// The processes are still reaped once they exit, so they do not
+		// linger as zombies.
+		go j.wait()
+	}
+	return nil
+}
+
+func (c *DisownCommand) Help() string {
+	return `disown: Remove jobs from the job table
+Usage: disown [-ar] [jobspec...]
+
+Options:
+  -a    remove all jobs
+  -r    remove only running jobs
+
+Without a jobspec or -a, the current job is removed.`
+}
@@ -0,0 +1,226 @@
+// internal/shell/executor/redirect.go
+package executor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"github.com/krzko/gosh/internal/shell/ast"
+)
+
+// Redirect is a redirection whose target has been expanded and is ready to
+// be applied to a command. For a here-document or here-string, Target is
+// the text to be read.
+type Redirect struct {
+	Op     ast.RedirOp
+	Fd     int
+	Target string
+}
+
+// redirectError is the failure of the redirections of a command with no
+// name, which are applied while it is expanded.
+type redirectError struct {
+	err error
+}
+
+func (e redirectError) Error() string { return e.err.Error() }
+
+func (e redirectError) Unwrap() error { return e.err }
+
+// defaultFd returns the file descriptor an operator applies to when none
+// is given explicitly.
+func defaultFd(op ast.RedirOp) int {
+	switch op {
+	case ast.RedirIn, ast.RedirInOut, ast.DupIn, ast.Heredoc, ast.DashHeredoc, ast.HereString:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// fdTable is the set of open file descriptors a single command runs with.
+type fdTable struct {
+	files map[int]*os.File
+	// closed holds the standard descriptors closed with <&- or >&-,
+	// which the command runs without.
+	closed map[int]bool
+	opened []*os.File
+}
+
+func newFdTable(stdin, stdout, stderr *os.File) *fdTable {
+	t := &fdTable{files: map[int]*os.File{}, closed: map[int]bool{}}
+	for fd, f := range []*os.File{stdin, stdout, stderr} {
+		if f == closedFile {
+			t.closed[fd] = true
+		} else {
+			t.files[fd] = f
+		}
+	}
+	return t
+}
+
+// closedFile stands for a closed descriptor among the shell's standard
+// streams and those of the processes it starts. It is a file that has
+// itself been closed, whose descriptor of -1 os.StartProcess passes on
+// as one for the child to close.
+var closedFile = func() *os.File {
+	f, err := os.Open(os.DevNull)
+	if err == nil {
+		f.Close()
+	}
+	return f
+}()
+
+// closedFd is what builtins read from and write to in place of a closed
+// descriptor, failing as the system calls would.
+type closedFd struct{}
+
+func (closedFd) Read([]byte) (int, error) { return 0, syscall.EBADF }
+
+func (closedFd) Write([]byte) (int, error) { return 0, syscall.EBADF }
+
+// set makes fd refer to f.
+func (t *fdTable) set(fd int, f *os.File) {
+	t.files[fd] = f
+	delete(t.closed, fd)
+}
+
+// apply performs the redirections in order, so that 2>&1 >file and
+// >file 2>&1 behave differently, as they do in other shells. Relative
+// file names are taken from dir.
+func (t *fdTable) apply(redirs []Redirect, dir string) error {
+	for _, r := range redirs {
+		if err := t.applyOne(r, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *fdTable) applyOne(r Redirect, dir string) error {
+	switch r.Op {
+	case ast.RedirOut, ast.ClobberOut:
+		return t.open(r.Fd, inDir(dir, r.Target), os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	case ast.AppendOut:
+		return t.open(r.Fd, inDir(dir, r.Target), os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+	case ast.RedirIn:
+		return t.open(r.Fd, inDir(dir, r.Target), os.O_RDONLY)
+	case ast.RedirInOut:
+		return t.open(r.Fd, inDir(dir, r.Target), os.O_RDWR|os.O_CREATE)
+	case ast.RedirAll, ast.AppendAll:
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if r.Op == ast.AppendAll {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		if err := t.open(1, inDir(dir, r.Target), flags); err != nil {
+			return err
+		}
+		t.set(2, t.files[1])
+		return nil
+	case ast.DupIn, ast.DupOut:
+		return t.dup(r)
+	case ast.Heredoc, ast.DashHeredoc, ast.HereString:
+		return t.here(r.Fd, r.Target)
+	}
+	return fmt.Errorf("unsupported redirection %s", r.Op)
+}
+
+func (t *fdTable) open(fd int, name string, flags int) error {
+	f, err := os.OpenFile(name, flags, 0644)
+	if err != nil {
+		return err
+	}
+	t.opened = append(t.opened, f)
+	t.set(fd, f)
+	return nil
+}
+
+// here makes fd read text. The text is put in an unlinked temporary file
+// rather than a pipe, so that the shell never blocks writing more of it
+// than a command reads.
+func (t *fdTable) here(fd int, text string) error {
+	f, err := os.CreateTemp("", "gosh-here-")
+	if err != nil {
+		return err
+	}
+	os.Remove(f.Name())
+	t.opened = append(t.opened, f)
+	if _, err := f.WriteString(text); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	t.set(fd, f)
+	return nil
+}
+
+func (t *fdTable) dup(r Redirect) error {
+	if r.Target == "-" {
+		delete(t.files, r.Fd)
+		if r.Fd <= 2 {
+			t.closed[r.Fd] = true
+		}
+		return nil
+	}
+	src, err := strconv.Atoi(r.Target)
+	if err != nil {
+		return fmt.Errorf("%s: ambiguous redirect", r.Target)
+	}
+	f, ok := t.files[src]
+	if !ok {
+		return fmt.Errorf("%d: bad file descriptor", src)
+	}
+	t.set(r.Fd, f)
+	return nil
+}
+
+// configure points a process's standard streams and any higher file
+// descriptors at the table's files. Closed descriptors are closed in the
+// process too.
+func (t *fdTable) configure(cmd *exec.Cmd) {
+	cmd.Stdin = t.stream(0)
+	cmd.Stdout = t.stream(1)
+	cmd.Stderr = t.stream(2)
+
+	maxFd := 2
+	for fd := range t.files {
+		if fd > maxFd {
+			maxFd = fd
+		}
+	}
+	if maxFd > 2 {
+		cmd.ExtraFiles = make([]*os.File, maxFd-2)
+		for fd := 3; fd <= maxFd; fd++ {
+			cmd.ExtraFiles[fd-3] = t.files[fd]
+		}
+	}
+}
+
+// stream returns the file for one of the standard descriptors, which is
+// closedFile if the descriptor is closed.
+func (t *fdTable) stream(fd int) *os.File {
+	if t.closed[fd] {
+		return closedFile
+	}
+	return t.files[fd]
+}
+
+// inherit passes f, which the table takes ownership of, to the command
+// under its own descriptor number.
+func (t *fdTable) inherit(f *os.File) {
+	t.set(int(f.Fd()), f)
+	t.opened = append(t.opened, f)
+}
+
+// close closes every file the table opened itself.
+func (t *fdTable) close() {
+	for _, f := range t.opened {
+		f.Close()
+	}
+	t.opened = nil
+}
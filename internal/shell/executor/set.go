@@ -0,0 +1,83 @@
+// internal/shell/executor/set.go
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/krzko/gosh/internal/shell/command"
+)
+
+// shellOptions lists the options the set builtin accepts.
+var shellOptions = []string{"pipefail", "noglob", "nullglob", "failglob"}
+
+// SetCommand changes shell options.
+type SetCommand struct {
+	executor *Executor
+}
+
+func (s *SetCommand) Execute(ctx *command.Context, args []string) error {
+	if len(args) == 0 || (len(args) == 1 && (args[0] == "-o" || args[0] == "+o")) {
+		names := append([]string(nil), shellOptions...)
+		sort.Strings(names)
+		for _, name := range names {
+			state := "off"
+			if s.executor.options[name] {
+				state = "on"
+			}
+			fmt.Fprintf(ctx.Stdout, "%-15s %s\n", name, state)
+		}
+		return nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		flag := args[i]
+		if flag == "--" || !strings.HasPrefix(flag, "-") && !strings.HasPrefix(flag, "+") {
+			// The remaining arguments replace the positional parameters.
+			if flag == "--" {
+				i++
+			}
+			s.executor.params = append([]string(nil), args[i:]...)
+			return nil
+		}
+		if flag != "-o" && flag != "+o" {
+			return fmt.Errorf("%s: invalid option", flag)
+		}
+		if i+1 >= len(args) {
+			return fmt.Errorf("%s: option name required", flag)
+		}
+		i++
+		if !isShellOption(args[i]) {
+			return fmt.Errorf("%s: invalid option name", args[i])
+		}
+		s.executor.options[args[i]] = flag == "-o"
+	}
+	return nil
+}
+
+func isShellOption(name string) bool {
+	for _, opt := range shellOptions {
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SetCommand) Help() string {
+	return `set: Set or unset shell options
+Usage: set [-o option] [+o option] [--] [arg...]
+
+With no arguments, lists the current option settings. Any arguments
+after the options, or after --, become the positional parameters $1,
+$2 and so on; "set --" alone clears them.
+
+Options:
+  pipefail    the status of a pipeline is that of the last command to
+              fail, rather than that of the last command
+  noglob      disable pathname expansion (*, ?, [...] and **)
+  nullglob    patterns that match no files expand to nothing
+  failglob    patterns that match no files are an error; without
+              nullglob or failglob they are left unchanged`
+}
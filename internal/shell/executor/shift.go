@@ -0,0 +1,40 @@
+// internal/shell/executor/shift.go
+package executor
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/krzko/gosh/internal/shell/command"
+)
+
+// ShiftCommand drops positional parameters from the front of the list.
+type ShiftCommand struct {
+	executor *Executor
+}
+
+func (c *ShiftCommand) Execute(ctx *command.Context, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+	n := 1
+	if len(args) == 1 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 0 {
+			return fmt.Errorf("%s: numeric argument required", args[0])
+		}
+	}
+	if n > len(c.executor.params) {
+		return command.ExitStatus(1)
+	}
+	c.executor.params = c.executor.params[n:]
+	return nil
+}
+
+func (c *ShiftCommand) Help() string {
+	return `shift: Shift positional parameters
+Usage: shift [n]
+
+Renames $n+1, $n+2 ... to $1, $2 ... n defaults to 1, and shift fails
+without changing anything if there are fewer than n parameters.`
+}
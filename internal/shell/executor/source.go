@@ -0,0 +1,120 @@
+// internal/shell/executor/source.go
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/krzko/gosh/internal/shell/ast"
+	"github.com/krzko/gosh/internal/shell/command"
+)
+
+// SourceFile runs the commands in the file at path in the current shell,
+// so that variables and options they set remain set afterwards. If args
+// is not nil it replaces the positional parameters while the file runs.
+// Each command is parsed just before it runs, so that aliases defined in
+// the file apply to the commands after them. It returns the status of the
+// last command run, or 2 after a syntax error.
+func (e *Executor) SourceFile(path string, args []string) (int, error) {
+	src, err := os.ReadFile(e.path(path))
+	if err != nil {
+		return 1, err
+	}
+
+	if args != nil {
+		saved := e.params
+		e.params = args
+		defer func() { e.params = saved }()
+	}
+	e.callDepth++
+	defer func() { e.callDepth-- }()
+	err = e.NewParser().ParseEach(string(src), func(file *ast.File) bool {
+		e.runFile(file)
+		return !e.stopped()
+	})
+	e.returning = false
+	if err != nil {
+		return 2, fmt.Errorf("%s: %w", path, err)
+	}
+	return e.status, nil
+}
+
+// SourceCommand runs a file's commands in the current shell.
+type SourceCommand struct {
+	executor *Executor
+}
+
+func (c *SourceCommand) Execute(ctx *command.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("filename argument required")
+	}
+	path, err := findSource(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	var params []string
+	if len(args) > 1 {
+		params = args[1:]
+	}
+
+	var status int
+	c.executor.withStdio(ctx, func() {
+		status, err = c.executor.SourceFile(path, params)
+	})
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return command.ExitStatus(status)
+	}
+	return nil
+}
+
+// findSource locates the file to source. A name without a slash is looked
+// up in PATH first and then in the working directory.
+func findSource(ctx *command.Context, name string) (string, error) {
+	if strings.Contains(name, "/") {
+		return name, nil
+	}
+	for _, dir := range filepath.SplitList(ctx.Getenv("PATH")) {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path, nil
+		}
+	}
+	if _, err := os.Stat(inDir(ctx.Dir, name)); err != nil {
+		return "", fmt.Errorf("%s: no such file or directory", name)
+	}
+	return name, nil
+}
+
+// withStdio runs fn with the executor's standard streams replaced by the
+// builtin's, so that commands run on behalf of a redirected builtin are
+// redirected too.
+func (e *Executor) withStdio(ctx *command.Context, fn func()) {
+	saved := [3]*os.File{e.stdin, e.stdout, e.stderr}
+	if f, ok := ctx.Stdin.(*os.File); ok {
+		e.stdin = f
+	}
+	if f, ok := ctx.Stdout.(*os.File); ok {
+		e.stdout = f
+	}
+	if f, ok := ctx.Stderr.(*os.File); ok {
+		e.stderr = f
+	}
+	defer func() { e.stdin, e.stdout, e.stderr = saved[0], saved[1], saved[2] }()
+	fn()
+}
+
+func (c *SourceCommand) Help() string {
+	return `source: Run commands from a file in the current shell
+Usage: source file [arg...]
+       . file [arg...]
+
+Variables and options set by the file stay set. Any arguments become the
+positional parameters while the file runs. A file name without a slash
+is looked up in PATH, then in the current directory.`
+}
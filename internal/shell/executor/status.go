@@ -0,0 +1,172 @@
+// internal/shell/executor/status.go
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/krzko/gosh/internal/shell/command"
+	"github.com/krzko/gosh/internal/shell/vars"
+)
+
+// exitStatus converts the result of running a command into an exit status.
+// Errors other than a plain non-zero exit are reported on stderr.
+func (e *Executor) exitStatus(name string, err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var status command.ExitStatus
+	if errors.As(err, &status) {
+		return int(status)
+	}
+
+	var ret *returnRequest
+	if errors.As(err, &ret) {
+		e.returning = true
+		return ret.status
+	}
+
+	var loop *loopControl
+	if errors.As(err, &loop) {
+		if loop.continues {
+			e.continuing = loop.n
+		} else {
+			e.breaking = loop.n
+		}
+		return 0
+	}
+
+	var exit *command.ExitRequest
+	if errors.As(err, &exit) {
+		e.exited = true
+		if exit.Default {
+			return e.status
+		}
+		return exit.Status
+	}
+
+	// A builtin interrupted with Ctrl-C stops quietly, with the status
+	// of a process killed by SIGINT.
+	if errors.Is(err, context.Canceled) {
+		return 128 + int(syscall.SIGINT)
+	}
+
+	// A builtin writing into a pipe whose reader has gone away stops
+	// quietly, as a process killed by SIGPIPE would.
+	if errors.Is(err, syscall.EPIPE) {
+		return 128 + int(syscall.SIGPIPE)
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return 128 + int(ws.Signal())
+		}
+		return exitErr.ExitCode()
+	}
+
+	// Failures to start a process: 127 if it could not be found and 126
+	// if it could not be executed.
+	var execErr *exec.Error
+	if errors.As(err, &execErr) {
+		e.errorf("%s: command not found", name)
+		return 127
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) && pathErr.Op == "fork/exec" {
+		if errors.Is(err, fs.ErrNotExist) {
+			e.errorf("%s: no such file or directory", name)
+			return 127
+		}
+		e.errorf("%s: %v", name, pathErr.Err)
+		return 126
+	}
+
+	e.errorf("%s: %v", name, err)
+	return 1
+}
+
+func (e *Executor) errorf(format string, args ...interface{}) {
+	fmt.Fprintf(e.stderr, "gosh: "+format+"\n", args...)
+}
+
+// expandFailed reports an error expanding a command's words and returns
+// status 1. The error makes a shell that is not interactive exit, and an
+// interactive one abandon the rest of the current input. A redirection
+// failing for a command with no name only fails that command.
+func (e *Executor) expandFailed(err error) int {
+	e.errorf("%v", err)
+	var redir redirectError
+	if !errors.As(err, &redir) {
+		if e.interactive {
+			e.interrupted.Store(true)
+		} else {
+			e.exited = true
+		}
+	}
+	return e.setStatus(1)
+}
+
+// environ resolves parameters for expansion, serving the shell's special
+// parameters and falling back to its variables.
+type environ struct {
+	e *Executor
+}
+
+func (env environ) Get(name string) (string, bool) {
+	switch name {
+	case "?":
+		return strconv.Itoa(env.e.status), true
+	case "$":
+		return strconv.Itoa(os.Getpid()), true
+	case "0":
+		return env.e.arg0, true
+	case "!":
+		if env.e.lastBackground == 0 {
+			return "", false
+		}
+		return strconv.Itoa(env.e.lastBackground), true
+	case "PIPESTATUS":
+		statuses := make([]string, len(env.e.pipeStatus))
+		for i, status := range env.e.pipeStatus {
+			statuses[i] = strconv.Itoa(status)
+		}
+		return strings.Join(statuses, " "), true
+	}
+	return env.e.vars.Get(name)
+}
+
+func (env environ) Set(name, value string) error {
+	env.e.vars.Set(name, value)
+	return nil
+}
+
+// newVars creates the shell's variable store from the process environment,
+// filling in the variables the shell itself maintains.
+func newVars() *vars.Store {
+	store := vars.FromEnviron(os.Environ())
+	if dir, err := os.Getwd(); err == nil {
+		store.Set("PWD", dir)
+		store.Export("PWD")
+	}
+	if _, ok := store.Get("HOSTNAME"); !ok {
+		if hostname, err := os.Hostname(); err == nil {
+			store.Set("HOSTNAME", hostname)
+		}
+	}
+	if _, ok := store.Get("USER"); !ok {
+		if u, err := user.Current(); err == nil {
+			store.Set("USER", u.Username)
+		}
+	}
+	return store
+}
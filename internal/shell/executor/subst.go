@@ -0,0 +1,94 @@
+// internal/shell/executor/subst.go
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/krzko/gosh/internal/shell/ast"
+)
+
+// cmdSubst runs the commands of a command substitution in a subshell and
+// returns what they write to standard output. Their status is kept for a
+// command that consists only of assignments.
+func (e *Executor) cmdSubst(stmts []*ast.Stmt) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	sub := e.subshell()
+	sub.stdout = w
+
+	out := make(chan []byte, 1)
+	go func() {
+		data, _ := io.ReadAll(r)
+		r.Close()
+		out <- data
+	}()
+
+	// Ctrl-C stops the substitution along with the command it is part
+	// of.
+	e.mu.Lock()
+	ctx := e.ctx
+	e.mu.Unlock()
+	stop := context.AfterFunc(ctx, sub.Interrupt)
+
+	e.substStatus = sub.runStmts(stmts)
+	stop()
+	w.Close()
+	return string(<-out), nil
+}
+
+// procSubst starts the commands of a process substitution in a subshell,
+// connected to a pipe, and returns a /dev/fd path naming the other end.
+// That end stays open in e.substFiles until the command the substitution
+// is part of takes it over.
+func (e *Executor) procSubst(p *ast.ProcSubst) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	sub := e.subshell()
+	end, other := r, w
+	if p.Out {
+		end, other = w, r
+		sub.stdin = r
+	} else {
+		sub.stdout = w
+	}
+
+	go func() {
+		sub.runStmts(p.Stmts)
+		other.Close()
+	}()
+	e.substFiles = append(e.substFiles, end)
+	return fmt.Sprintf("/dev/fd/%d", end.Fd()), nil
+}
+
+// takeSubstFiles returns the pipes of the process substitutions expanded
+// since it was last called.
+func (e *Executor) takeSubstFiles() []*os.File {
+	files := e.substFiles
+	e.substFiles = nil
+	return files
+}
+
+// withSubstFiles hands the pipes of the process substitutions expanded
+// for cmd over to it, or closes them if there is no command to run.
+func (e *Executor) withSubstFiles(cmd *Command, err error) (*Command, error) {
+	files := e.takeSubstFiles()
+	if cmd == nil {
+		closeFiles(files)
+		return nil, err
+	}
+	cmd.Files = files
+	return cmd, err
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
@@ -0,0 +1,460 @@
+// internal/shell/expand/arith.go
+package expand
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// arithOps are the operators of arithmetic expressions, longest first so
+// that scanning takes the longest match.
+var arithOps = []string{
+	"<<=", ">>=",
+	"**", "++", "--", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||",
+	"+=", "-=", "*=", "/=", "%=", "&=", "^=", "|=",
+	"+", "-", "*", "/", "%", "<", ">", "=", "!", "~", "&", "^", "|",
+	"?", ":", "(", ")", ",",
+}
+
+// arithLevels are the left-associative binary operators, from the loosest
+// binding to the tightest.
+var arithLevels = [][]string{
+	{"||"}, {"&&"}, {"|"}, {"^"}, {"&"}, {"==", "!="},
+	{"<", "<=", ">", ">="}, {"<<", ">>"}, {"+", "-"}, {"*", "/", "%"},
+}
+
+// maxArithDepth limits how deeply variables whose values are themselves
+// expressions are evaluated, so that x=x does not recurse forever.
+const maxArithDepth = 100
+
+type arithToken struct {
+	// kind is 'n' for a number, 'v' for a variable name and 'o' for an
+	// operator.
+	kind byte
+	text string
+}
+
+// arith evaluates arithmetic expressions with 64-bit integers, as bash
+// does, assigning to variables through cfg.
+type arith struct {
+	cfg    *Config
+	toks   []arithToken
+	pos    int
+	depth  int
+	noEval int
+}
+
+// Arith evaluates the arithmetic expression expr. A variable name in it
+// stands for the value of the variable, which is itself evaluated as an
+// expression; one that is unset or empty counts as 0.
+func Arith(cfg *Config, expr string) (int64, error) {
+	n, err := (&arith{cfg: cfg}).eval(expr)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", strings.TrimSpace(expr), err)
+	}
+	return n, nil
+}
+
+func (a *arith) eval(expr string) (int64, error) {
+	if a.depth >= maxArithDepth {
+		return 0, fmt.Errorf("expression recursion level exceeded")
+	}
+	toks, err := scanArith(expr)
+	if err != nil || len(toks) == 0 {
+		return 0, err
+	}
+	sub := &arith{cfg: a.cfg, toks: toks, depth: a.depth + 1, noEval: a.noEval}
+	n, err := sub.comma()
+	if err == nil && sub.pos < len(sub.toks) {
+		err = fmt.Errorf("syntax error in expression (error token is %q)", sub.toks[sub.pos].text)
+	}
+	return n, err
+}
+
+func scanArith(expr string) ([]arithToken, error) {
+	var toks []arithToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(expr) && (isNameByte(expr[j]) || expr[j] == '#' || expr[j] == '@') {
+				j++
+			}
+			toks = append(toks, arithToken{kind: 'n', text: expr[i:j]})
+			i = j
+		case isNameByte(c):
+			j := i
+			for j < len(expr) && isNameByte(expr[j]) {
+				j++
+			}
+			toks = append(toks, arithToken{kind: 'v', text: expr[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, o := range arithOps {
+				if strings.HasPrefix(expr[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("syntax error: invalid arithmetic operator (error token is %q)", expr[i:])
+			}
+			toks = append(toks, arithToken{kind: 'o', text: op})
+			i += len(op)
+		}
+	}
+	return toks, nil
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// peek returns the operator at the current token, or "" if it is not one.
+func (a *arith) peek(offset int) string {
+	if a.pos+offset < len(a.toks) && a.toks[a.pos+offset].kind == 'o' {
+		return a.toks[a.pos+offset].text
+	}
+	return ""
+}
+
+func (a *arith) comma() (int64, error) {
+	n, err := a.assign()
+	for err == nil && a.peek(0) == "," {
+		a.pos++
+		n, err = a.assign()
+	}
+	return n, err
+}
+
+func (a *arith) assign() (int64, error) {
+	if a.pos < len(a.toks) && a.toks[a.pos].kind == 'v' {
+		op := a.peek(1)
+		if op == "=" || len(op) >= 2 && strings.HasSuffix(op, "=") && !isComparison(op) {
+			name := a.toks[a.pos].text
+			a.pos += 2
+			n, err := a.assign()
+			if err != nil {
+				return 0, err
+			}
+			if op != "=" {
+				old, err := a.variable(name)
+				if err != nil {
+					return 0, err
+				}
+				if n, err = binaryOp(strings.TrimSuffix(op, "="), old, n); err != nil {
+					return 0, err
+				}
+			}
+			return n, a.set(name, n)
+		}
+	}
+	return a.ternary()
+}
+
+func isComparison(op string) bool {
+	return op == "==" || op == "!=" || op == "<=" || op == ">="
+}
+
+func (a *arith) ternary() (int64, error) {
+	cond, err := a.binary(0)
+	if err != nil || a.peek(0) != "?" {
+		return cond, err
+	}
+	a.pos++
+	// Only the branch chosen is evaluated for its side effects.
+	x, err := a.branch(cond != 0)
+	if err != nil {
+		return 0, err
+	}
+	if a.peek(0) != ":" {
+		return 0, fmt.Errorf("`:' expected for conditional expression")
+	}
+	a.pos++
+	y, err := a.branch(cond == 0)
+	if cond != 0 {
+		return x, err
+	}
+	return y, err
+}
+
+// branch evaluates a branch of a conditional expression, with its side
+// effects only if taken is set.
+func (a *arith) branch(taken bool) (int64, error) {
+	if !taken {
+		a.noEval++
+		defer func() { a.noEval-- }()
+	}
+	return a.assign()
+}
+
+func (a *arith) binary(level int) (int64, error) {
+	if level == len(arithLevels) {
+		return a.power()
+	}
+	x, err := a.binary(level + 1)
+	for err == nil {
+		op := a.peek(0)
+		if !contains(arithLevels[level], op) {
+			break
+		}
+		a.pos++
+		// && and || do not evaluate their right side if the left
+		// decides the result.
+		skip := op == "&&" && x == 0 || op == "||" && x != 0
+		if skip {
+			a.noEval++
+		}
+		var y int64
+		y, err = a.binary(level + 1)
+		if skip {
+			a.noEval--
+		}
+		if err != nil {
+			break
+		}
+		if a.noEval > 0 && (op == "/" || op == "%") && y == 0 {
+			continue
+		}
+		x, err = binaryOp(op, x, y)
+	}
+	return x, err
+}
+
+func contains(ops []string, op string) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *arith) power() (int64, error) {
+	x, err := a.unary()
+	if err != nil || a.peek(0) != "**" {
+		return x, err
+	}
+	a.pos++
+	y, err := a.power()
+	if err != nil {
+		return 0, err
+	}
+	return binaryOp("**", x, y)
+}
+
+func (a *arith) unary() (int64, error) {
+	switch op := a.peek(0); op {
+	case "!", "~", "+", "-":
+		a.pos++
+		x, err := a.unary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "!":
+			return boolInt(x == 0), nil
+		case "~":
+			return ^x, nil
+		case "-":
+			return -x, nil
+		}
+		return x, nil
+	case "++", "--":
+		a.pos++
+		if a.pos == len(a.toks) || a.toks[a.pos].kind != 'v' {
+			return 0, fmt.Errorf("syntax error: operand expected after %s", op)
+		}
+		name := a.toks[a.pos].text
+		a.pos++
+		x, err := a.variable(name)
+		if err != nil {
+			return 0, err
+		}
+		x += step(op)
+		return x, a.set(name, x)
+	}
+	return a.postfix()
+}
+
+func step(op string) int64 {
+	if op == "--" {
+		return -1
+	}
+	return 1
+}
+
+func (a *arith) postfix() (int64, error) {
+	if a.pos == len(a.toks) {
+		return 0, fmt.Errorf("syntax error: operand expected")
+	}
+	tok := a.toks[a.pos]
+	a.pos++
+	switch tok.kind {
+	case 'n':
+		return parseArithNumber(tok.text)
+	case 'v':
+		x, err := a.variable(tok.text)
+		if err != nil {
+			return 0, err
+		}
+		if op := a.peek(0); op == "++" || op == "--" {
+			a.pos++
+			return x, a.set(tok.text, x+step(op))
+		}
+		return x, nil
+	}
+	if tok.text == "(" {
+		x, err := a.comma()
+		if err != nil {
+			return 0, err
+		}
+		if a.peek(0) != ")" {
+			return 0, fmt.Errorf("missing `)'")
+		}
+		a.pos++
+		return x, nil
+	}
+	return 0, fmt.Errorf("syntax error: operand expected (error token is %q)", tok.text)
+}
+
+// variable returns the value of name, evaluating it as an expression.
+func (a *arith) variable(name string) (int64, error) {
+	value, _ := a.cfg.lookup(name)
+	if strings.TrimSpace(value) == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n, nil
+	}
+	return (&arith{cfg: a.cfg, depth: a.depth, noEval: a.noEval}).eval(value)
+}
+
+func (a *arith) set(name string, n int64) error {
+	if a.noEval > 0 || a.cfg == nil || a.cfg.Env == nil {
+		return nil
+	}
+	return a.cfg.Env.Set(name, strconv.FormatInt(n, 10))
+}
+
+func binaryOp(op string, x, y int64) (int64, error) {
+	switch op {
+	case "||":
+		return boolInt(x != 0 || y != 0), nil
+	case "&&":
+		return boolInt(x != 0 && y != 0), nil
+	case "|":
+		return x | y, nil
+	case "^":
+		return x ^ y, nil
+	case "&":
+		return x & y, nil
+	case "==":
+		return boolInt(x == y), nil
+	case "!=":
+		return boolInt(x != y), nil
+	case "<":
+		return boolInt(x < y), nil
+	case "<=":
+		return boolInt(x <= y), nil
+	case ">":
+		return boolInt(x > y), nil
+	case ">=":
+		return boolInt(x >= y), nil
+	case "<<":
+		return x << uint64(y&63), nil
+	case ">>":
+		return x >> uint64(y&63), nil
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/", "%":
+		if y == 0 {
+			return 0, fmt.Errorf("division by 0")
+		}
+		if op == "/" {
+			return x / y, nil
+		}
+		return x % y, nil
+	case "**":
+		if y < 0 {
+			return 0, fmt.Errorf("exponent less than 0")
+		}
+		n := int64(1)
+		for ; y > 0; y-- {
+			n *= x
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("unknown operator %s", op)
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// parseArithNumber parses a decimal, octal (leading 0), hexadecimal
+// (leading 0x) or base#digits constant.
+func parseArithNumber(s string) (int64, error) {
+	if base, digits, ok := strings.Cut(s, "#"); ok {
+		b, err := strconv.Atoi(base)
+		if err != nil || b < 2 || b > 64 {
+			return 0, fmt.Errorf("%s: invalid arithmetic base", s)
+		}
+		var n int64
+		for i := 0; i < len(digits); i++ {
+			d := digitValue(digits[i], b)
+			if d < 0 || d >= b {
+				return 0, fmt.Errorf("%s: value too great for base", s)
+			}
+			n = n*int64(b) + int64(d)
+		}
+		return n, nil
+	}
+
+	base, digits := 10, s
+	switch {
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		base, digits = 16, s[2:]
+	case len(s) > 1 && s[0] == '0':
+		base, digits = 8, s[1:]
+	}
+	n, err := strconv.ParseUint(digits, base, 64)
+	if err != nil || strings.Contains(digits, "_") {
+		return 0, fmt.Errorf("%s: value too great for base", s)
+	}
+	return int64(n), nil
+}
+
+// digitValue returns the value of c as a digit in bash's base#digits
+// notation: 0-9, a-z, A-Z, @ and _, with letters of either case equal in
+// bases up to 36.
+func digitValue(c byte, base int) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'z':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'Z':
+		if base <= 36 {
+			return int(c-'A') + 10
+		}
+		return int(c-'A') + 36
+	case c == '@':
+		return 62
+	case c == '_':
+		return 63
+	}
+	return -1
+}
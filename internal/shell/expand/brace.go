@@ -0,0 +1,244 @@
+// internal/shell/expand/brace.go
+package expand
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/krzko/gosh/internal/shell/ast"
+)
+
+// maxBraceWords limits the words brace expansion may produce, so that a
+// mistyped {1..100000000} fails instead of exhausting memory.
+const maxBraceWords = 1 << 16
+
+// braceItem is a piece of a word during brace expansion: either one of
+// the unquoted characters {, , and } or any other part of the word.
+type braceItem struct {
+	brace byte
+	part  ast.WordPart
+}
+
+// Braces performs brace expansion on w, returning the words it expands
+// to: a{b,c}d gives abd and acd, and {1..3} gives 1, 2 and 3. Only
+// unquoted braces count, and a word without a valid brace expression is
+// returned unchanged.
+func Braces(w *ast.Word) []*ast.Word {
+	if w == nil || !hasBrace(w) {
+		return []*ast.Word{w}
+	}
+	var words []*ast.Word
+	for _, items := range expandBraces(braceItems(w)) {
+		words = append(words, braceWord(items))
+	}
+	return words
+}
+
+func hasBrace(w *ast.Word) bool {
+	for _, part := range w.Parts {
+		if lit, ok := part.(*ast.Lit); ok && strings.Contains(lit.Value, "{") {
+			return true
+		}
+	}
+	return false
+}
+
+// braceItems splits the unquoted text of w at its brace characters.
+func braceItems(w *ast.Word) []braceItem {
+	var items []braceItem
+	for _, part := range w.Parts {
+		lit, ok := part.(*ast.Lit)
+		if !ok {
+			items = append(items, braceItem{part: part})
+			continue
+		}
+		start := 0
+		for i := 0; i < len(lit.Value); i++ {
+			switch c := lit.Value[i]; c {
+			case '\\':
+				i++
+			case '{', ',', '}':
+				if start < i {
+					items = append(items, braceItem{part: &ast.Lit{ValuePos: lit.ValuePos, Value: lit.Value[start:i]}})
+				}
+				items = append(items, braceItem{brace: c})
+				start = i + 1
+			}
+		}
+		if start < len(lit.Value) {
+			items = append(items, braceItem{part: &ast.Lit{ValuePos: lit.ValuePos, Value: lit.Value[start:]}})
+		}
+	}
+	return items
+}
+
+// expandBraces expands the leftmost valid brace expression in items, and
+// then those in each of the results.
+func expandBraces(items []braceItem) [][]braceItem {
+	for open := range items {
+		if items[open].brace != '{' {
+			continue
+		}
+		alts, end := braceAlternatives(items, open)
+		if alts == nil {
+			continue
+		}
+		prefix, suffix := items[:open], items[end+1:]
+		var results [][]braceItem
+		for _, alt := range alts {
+			word := make([]braceItem, 0, len(prefix)+len(alt)+len(suffix))
+			word = append(append(append(word, prefix...), alt...), suffix...)
+			results = append(results, expandBraces(word)...)
+			if len(results) > maxBraceWords {
+				return [][]braceItem{items}
+			}
+		}
+		return results
+	}
+	return [][]braceItem{items}
+}
+
+// braceAlternatives returns the alternatives of the brace expression
+// opened at items[open] and the index of its closing brace, or nil if it
+// is not a valid expression: one with a comma at its top level, or a
+// sequence.
+func braceAlternatives(items []braceItem, open int) ([][]braceItem, int) {
+	depth := 0
+	var alts [][]braceItem
+	start := open + 1
+	for i := open + 1; i < len(items); i++ {
+		switch items[i].brace {
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if alts == nil {
+				return braceSequence(items[open+1 : i]), i
+			}
+			return append(alts, items[start:i]), i
+		case ',':
+			if depth == 0 {
+				alts = append(alts, items[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return nil, 0
+}
+
+// braceSequence expands the contents of {x..y} or {x..y..incr}, where x
+// and y are both integers or both single letters.
+func braceSequence(items []braceItem) [][]braceItem {
+	if len(items) != 1 {
+		return nil
+	}
+	lit, ok := items[0].part.(*ast.Lit)
+	if !ok {
+		return nil
+	}
+	fields := strings.Split(lit.Value, "..")
+	if len(fields) != 2 && len(fields) != 3 {
+		return nil
+	}
+	incr := 1
+	if len(fields) == 3 {
+		n, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil
+		}
+		incr = max(n, -n, 1)
+	}
+
+	var values []string
+	if from, to, ok := letterRange(fields[0], fields[1]); ok {
+		for c := from; ; c = stepToward(c, to, incr) {
+			values = append(values, string(rune(c)))
+			if c == to || stepToward(c, to, incr) == c {
+				break
+			}
+		}
+	} else {
+		from, err1 := strconv.Atoi(fields[0])
+		to, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil {
+			return nil
+		}
+		if max(to-from, from-to)/incr > maxBraceWords {
+			return nil
+		}
+		width := 0
+		if zeroPadded(fields[0]) || zeroPadded(fields[1]) {
+			width = max(len(fields[0]), len(fields[1]))
+		}
+		for n := from; ; n = stepToward(n, to, incr) {
+			values = append(values, padInt(n, width))
+			if n == to || stepToward(n, to, incr) == n {
+				break
+			}
+		}
+	}
+
+	alts := make([][]braceItem, len(values))
+	for i, v := range values {
+		alts[i] = []braceItem{{part: &ast.Lit{ValuePos: lit.ValuePos, Value: v}}}
+	}
+	return alts
+}
+
+func letterRange(from, to string) (int, int, bool) {
+	isLetter := func(s string) bool {
+		return len(s) == 1 && (s[0] >= 'a' && s[0] <= 'z' || s[0] >= 'A' && s[0] <= 'Z')
+	}
+	if !isLetter(from) || !isLetter(to) {
+		return 0, 0, false
+	}
+	return int(from[0]), int(to[0]), true
+}
+
+// stepToward moves n by incr toward to, without passing it; it returns n
+// itself if another step would.
+func stepToward(n, to, incr int) int {
+	switch {
+	case n < to && n+incr <= to:
+		return n + incr
+	case n > to && n-incr >= to:
+		return n - incr
+	}
+	return n
+}
+
+func zeroPadded(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	return len(s) > 1 && s[0] == '0'
+}
+
+func padInt(n, width int) string {
+	s := strconv.Itoa(n)
+	if n < 0 {
+		return "-" + strings.Repeat("0", max(width-len(s), 0)) + s[1:]
+	}
+	return strings.Repeat("0", max(width-len(s), 0)) + s
+}
+
+// braceWord joins items back into a word, with the brace characters
+// that were not part of an expression as literal text.
+func braceWord(items []braceItem) *ast.Word {
+	w := &ast.Word{}
+	for _, item := range items {
+		part := item.part
+		if item.brace != 0 {
+			part = &ast.Lit{Value: string(item.brace)}
+		}
+		if lit, ok := part.(*ast.Lit); ok && len(w.Parts) > 0 {
+			if prev, ok := w.Parts[len(w.Parts)-1].(*ast.Lit); ok {
+				w.Parts[len(w.Parts)-1] = &ast.Lit{ValuePos: prev.ValuePos, Value: prev.Value + lit.Value}
+				continue
+			}
+		}
+		w.Parts = append(w.Parts, part)
+	}
+	return w
+}
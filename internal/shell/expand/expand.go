@@ -0,0 +1,549 @@
+// internal/shell/expand/expand.go
+package expand
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/krzko/gosh/internal/shell/ast"
+)
+
+// Environ resolves and assigns parameters during expansion.
+type Environ interface {
+	Get(name string) (string, bool)
+	Set(name, value string) error
+}
+
+// GlobMode selects what happens to a pattern that matches no files.
+type GlobMode int
+
+const (
+	// GlobKeep leaves the pattern in place as a literal argument.
+	GlobKeep GlobMode = iota
+	// GlobNull removes the pattern from the arguments.
+	GlobNull
+	// GlobFail makes expansion fail with a "no match" error.
+	GlobFail
+)
+
+// Config holds what expansion needs from the shell. A nil *Config expands
+// every parameter to the empty string and globs with GlobKeep.
+type Config struct {
+	Env Environ
+	// Params are the positional parameters, $1 onwards.
+	Params []string
+
+	// NoGlob disables pathname expansion.
+	NoGlob bool
+	// GlobMode selects the behaviour for patterns that match nothing.
+	GlobMode GlobMode
+	// Dir is the directory relative patterns are matched in. If empty,
+	// it is the current directory.
+	Dir string
+
+	// CmdSubst runs the commands of a command substitution and returns
+	// what they wrote to standard output. If nil, command substitutions
+	// expand to nothing.
+	CmdSubst func(stmts []*ast.Stmt) (string, error)
+	// ProcSubst starts the commands of a process substitution and returns
+	// the path of the pipe connected to them.
+	ProcSubst func(p *ast.ProcSubst) (string, error)
+}
+
+// Fields expands a list of words into the final argument strings. The
+// expansions are done in the order POSIX gives: brace expansion, then
+// tilde, parameter, command and arithmetic expansion, splitting into
+// fields at the characters of IFS, pathname expansion of unquoted
+// pattern characters and finally quote removal. As in other shells, a
+// word that looks like an assignment, such as an argument to export, has
+// its tilde prefixes expanded as an assignment's value would.
+func Fields(cfg *Config, words ...*ast.Word) ([]string, error) {
+	var braced []*ast.Word
+	for _, w := range words {
+		braced = append(braced, Braces(w)...)
+	}
+
+	fields := make([]string, 0, len(braced))
+	for _, w := range braced {
+		wordFields, err := cfg.word(w, true, tildeArg)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range wordFields {
+			if cfg.noGlob() || !HasMeta(f.pattern) {
+				fields = append(fields, f.literal)
+				continue
+			}
+
+			matches, err := GlobIn(cfg.dir(), f.pattern)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) > 0 {
+				fields = append(fields, matches...)
+				continue
+			}
+			switch cfg.globMode() {
+			case GlobNull:
+			case GlobFail:
+				return nil, fmt.Errorf("no match: %s", f.literal)
+			default:
+				fields = append(fields, f.literal)
+			}
+		}
+	}
+	return fields, nil
+}
+
+func (cfg *Config) noGlob() bool {
+	return cfg != nil && cfg.NoGlob
+}
+
+func (cfg *Config) dir() string {
+	if cfg == nil {
+		return ""
+	}
+	return cfg.Dir
+}
+
+func (cfg *Config) globMode() GlobMode {
+	if cfg == nil {
+		return GlobKeep
+	}
+	return cfg.GlobMode
+}
+
+// Literal expands a single word into a string, removing quotes and
+// escapes. There is no brace expansion, field splitting or pathname
+// expansion, and the fields of "$@" are joined with spaces.
+func Literal(cfg *Config, w *ast.Word) (string, error) {
+	return cfg.literal(w, tildeStart)
+}
+
+// Assignment expands the value of a variable assignment as Literal does,
+// except that a tilde prefix is also expanded after each unquoted ':', as
+// in PATH=$PATH:~/bin.
+func Assignment(cfg *Config, w *ast.Word) (string, error) {
+	return cfg.literal(w, tildeValue)
+}
+
+func (cfg *Config) literal(w *ast.Word, tildes tildeMode) (string, error) {
+	fields, err := cfg.word(w, false, tildes)
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = f.literal
+	}
+	return strings.Join(parts, " "), err
+}
+
+// Pattern expands a word for use as a shell pattern. Quoted characters
+// are escaped so that they match literally.
+func Pattern(cfg *Config, w *ast.Word) (string, error) {
+	fields, err := cfg.word(w, false, tildeStart)
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = f.pattern
+	}
+	return strings.Join(parts, " "), err
+}
+
+// field is the result of expanding a word, in two forms: literal, with
+// quotes and escapes removed, and pattern, in which characters that came
+// from quoted text are escaped so they lose any special meaning.
+type field struct {
+	literal string
+	pattern string
+}
+
+type fieldBuilder struct {
+	lit strings.Builder
+	pat strings.Builder
+	// fields holds the fields completed so far.
+	fields []field
+
+	// split is set if unquoted expansions are split into fields at the
+	// characters of ifs.
+	split bool
+	ifs   string
+	// started is set once the current field has text, or quoted text
+	// that may be empty, so that it is kept.
+	started bool
+	// pending is set when IFS white space has ended the current field;
+	// the next text written starts a new one.
+	pending bool
+}
+
+// next ends the current field and starts another.
+func (b *fieldBuilder) next() {
+	b.fields = append(b.fields, field{literal: b.lit.String(), pattern: b.pat.String()})
+	b.lit.Reset()
+	b.pat.Reset()
+	b.started = false
+	b.pending = false
+}
+
+// begin is called before text is added to the current field.
+func (b *fieldBuilder) begin() {
+	if b.pending {
+		b.next()
+	}
+	b.started = true
+}
+
+// writeLit appends unquoted text from the word itself. Backslash escapes
+// keep their meaning in the pattern form, where they make the next
+// character literal.
+func (b *fieldBuilder) writeLit(s string) {
+	b.begin()
+	b.lit.WriteString(unescape(s))
+	b.pat.WriteString(s)
+}
+
+// writeQuoted appends s as literal text. The field is kept even if s is
+// empty.
+func (b *fieldBuilder) writeQuoted(s string) {
+	b.begin()
+	b.lit.WriteString(s)
+	b.pat.WriteString(QuotePattern(s))
+}
+
+// writeExpansion appends the result of an unquoted expansion, in which
+// any pattern characters keep their meaning.
+func (b *fieldBuilder) writeExpansion(s string) {
+	for _, r := range s {
+		if !b.splitAt(r) {
+			b.begin()
+			b.lit.WriteRune(r)
+			b.pat.WriteRune(r)
+		}
+	}
+}
+
+// writeSplitLit appends unquoted text from the word of an expansion such
+// as ${x:-word}, which is split like the result of the expansion, except
+// at escaped characters.
+func (b *fieldBuilder) writeSplitLit(s string) {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			b.begin()
+			b.lit.WriteByte(s[i+1])
+			b.pat.WriteString(s[i : i+2])
+			i++
+			continue
+		}
+		if !b.splitAt(rune(s[i])) {
+			b.begin()
+			b.lit.WriteByte(s[i])
+			b.pat.WriteByte(s[i])
+		}
+	}
+}
+
+// splitAt reports whether r separates fields, when splitting, and ends
+// the field if so: a run of IFS white space ends it, as does each other
+// IFS character, which may leave an empty field.
+func (b *fieldBuilder) splitAt(r rune) bool {
+	if !b.split || !strings.ContainsRune(b.ifs, r) {
+		return false
+	}
+	if r == ' ' || r == '\t' || r == '\n' {
+		if b.started {
+			b.pending = true
+		}
+	} else {
+		b.next()
+	}
+	return true
+}
+
+// tildeMode selects where the tilde prefixes of a word are expanded.
+type tildeMode int
+
+const (
+	// tildeStart expands one at the start of the word.
+	tildeStart tildeMode = iota
+	// tildeValue, for the value of an assignment, expands one at the
+	// start and after each unquoted ':'.
+	tildeValue
+	// tildeArg expands those of the value of a word that looks like an
+	// assignment, after its first '=', and otherwise acts as
+	// tildeStart.
+	tildeArg
+)
+
+// word expands w into its fields: usually one, but "$@" produces one per
+// positional parameter and, with split set, unquoted expansions may
+// produce any number.
+func (cfg *Config) word(w *ast.Word, split bool, tildes tildeMode) ([]field, error) {
+	if w == nil {
+		return []field{{}}, nil
+	}
+	b := fieldBuilder{split: split}
+	if split {
+		b.ifs = cfg.ifs()
+	}
+
+	if tildes == tildeArg && !isAssignment(w) {
+		tildes = tildeStart
+	}
+	for i, part := range w.Parts {
+		lit, ok := part.(*ast.Lit)
+		if !ok || i > 0 && tildes == tildeStart {
+			if err := cfg.writePart(&b, part, false); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		s := lit.Value
+		if i == 0 && tildes == tildeArg {
+			name := strings.IndexByte(s, '=') + 1
+			b.writeLit(s[:name])
+			s = s[name:]
+		}
+		cfg.writeTildes(&b, s, i == 0, i == len(w.Parts)-1, tildes != tildeStart)
+	}
+	if b.started {
+		b.next()
+	}
+	return b.fields, nil
+}
+
+// isAssignment reports whether w looks like a variable assignment,
+// starting with an unquoted name and '='.
+func isAssignment(w *ast.Word) bool {
+	if len(w.Parts) == 0 {
+		return false
+	}
+	lit, ok := w.Parts[0].(*ast.Lit)
+	if !ok {
+		return false
+	}
+	name, _, ok := strings.Cut(lit.Value, "=")
+	if !ok || name == "" || name[0] >= '0' && name[0] <= '9' {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isNameByte(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeTildes writes the unquoted text s of a word, expanding a tilde
+// prefix at its start if start is set and, with colons set, after each
+// unquoted ':'. last is set if s ends the word.
+func (cfg *Config) writeTildes(b *fieldBuilder, s string, start, last, colons bool) {
+	for {
+		if start {
+			if dir, rest, ok := cfg.tilde(s, last, colons); ok {
+				b.writeQuoted(dir)
+				s = rest
+			}
+		}
+		i := indexColon(s)
+		if !colons || i < 0 {
+			break
+		}
+		b.writeLit(s[:i+1])
+		s, start = s[i+1:], true
+	}
+	if s != "" {
+		b.writeLit(s)
+	}
+}
+
+// indexColon returns the index of the first unescaped ':' in s, or -1.
+func indexColon(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ':':
+			return i
+		}
+	}
+	return -1
+}
+
+// ifs returns the characters fields are split at.
+func (cfg *Config) ifs() string {
+	if ifs, ok := cfg.lookup("IFS"); ok {
+		return ifs
+	}
+	return " \t\n"
+}
+
+// tilde expands a tilde prefix at the start of s: the text up to the
+// first slash, or also colon if colons is set, or if whole is set because
+// s ends the word, the end. It returns the directory the prefix names
+// and the rest of s, or false if s does not start with a prefix that can
+// be expanded.
+func (cfg *Config) tilde(s string, whole, colons bool) (dir, rest string, ok bool) {
+	if !strings.HasPrefix(s, "~") {
+		return "", "", false
+	}
+	ends := "/"
+	if colons {
+		ends = "/:"
+	}
+	name := s[1:]
+	if i := strings.IndexAny(s, ends); i >= 0 {
+		name, rest = s[1:i], s[i:]
+	} else if !whole {
+		return "", "", false
+	}
+	if strings.Contains(name, `\`) {
+		return "", "", false
+	}
+
+	switch name {
+	case "":
+		if dir, ok = cfg.lookup("HOME"); ok {
+			return dir, rest, true
+		}
+		u, err := user.Current()
+		if err != nil {
+			return "", "", false
+		}
+		return u.HomeDir, rest, true
+	case "+":
+		dir, ok = cfg.lookup("PWD")
+	case "-":
+		dir, ok = cfg.lookup("OLDPWD")
+	default:
+		u, err := user.Lookup(name)
+		if err != nil {
+			return "", "", false
+		}
+		dir, ok = u.HomeDir, true
+	}
+	return dir, rest, ok
+}
+
+func (cfg *Config) writePart(b *fieldBuilder, part ast.WordPart, quoted bool) error {
+	if isParamList(part) {
+		for i, param := range cfg.params() {
+			if quoted {
+				if i > 0 {
+					b.next()
+				}
+				b.writeQuoted(param)
+				continue
+			}
+			// Each parameter is a field of its own, and is split
+			// further.
+			if i > 0 && b.started {
+				b.pending = true
+			}
+			b.writeExpansion(param)
+		}
+		return nil
+	}
+
+	switch p := part.(type) {
+	case *ast.Lit:
+		if quoted {
+			b.writeQuoted(unescapeDouble(p.Value))
+			return nil
+		}
+		b.writeLit(p.Value)
+	case *ast.SglQuoted:
+		if p.Dollar {
+			b.writeQuoted(decodeEscapes(p.Value))
+			return nil
+		}
+		b.writeQuoted(p.Value)
+	case *ast.DblQuoted:
+		// "$@" alone produces no field at all without parameters.
+		if len(p.Parts) != 1 || !isParamList(p.Parts[0]) {
+			b.begin()
+		}
+		for _, inner := range p.Parts {
+			if err := cfg.writePart(b, inner, true); err != nil {
+				return err
+			}
+		}
+	case *ast.ParamExp:
+		if cfg.substitutes(p) {
+			if p.Word == nil {
+				return nil
+			}
+			for _, inner := range p.Word.Parts {
+				if lit, ok := inner.(*ast.Lit); ok && !quoted {
+					b.writeSplitLit(lit.Value)
+					continue
+				}
+				if err := cfg.writePart(b, inner, quoted); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		value, err := cfg.paramExp(p)
+		if err != nil {
+			return err
+		}
+		b.writeValue(value, quoted)
+	case *ast.CmdSubst:
+		if cfg == nil || cfg.CmdSubst == nil {
+			return nil
+		}
+		out, err := cfg.CmdSubst(p.Stmts)
+		if err != nil {
+			return err
+		}
+		b.writeValue(strings.TrimRight(out, "\n"), quoted)
+	case *ast.ArithExp:
+		// The expression is expanded as if in double quotes.
+		var expr fieldBuilder
+		for _, inner := range p.X.Parts {
+			if err := cfg.writePart(&expr, inner, true); err != nil {
+				return err
+			}
+		}
+		n, err := Arith(cfg, expr.lit.String())
+		if err != nil {
+			return err
+		}
+		b.writeValue(strconv.FormatInt(n, 10), quoted)
+	case *ast.ProcSubst:
+		if cfg == nil || cfg.ProcSubst == nil {
+			return fmt.Errorf("process substitution is not supported here")
+		}
+		path, err := cfg.ProcSubst(p)
+		if err != nil {
+			return err
+		}
+		b.writeQuoted(path)
+	}
+	return nil
+}
+
+// writeValue appends the result of an expansion, quoted or not.
+func (b *fieldBuilder) writeValue(s string, quoted bool) {
+	if quoted {
+		b.writeQuoted(s)
+	} else {
+		b.writeExpansion(s)
+	}
+}
+
+// unescape removes backslashes, keeping the character that follows each
+// one.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,375 @@
+// internal/shell/expand/expand_test.go
+package expand
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/krzko/gosh/internal/shell/ast"
+	"github.com/krzko/gosh/internal/shell/parser"
+)
+
+type mapEnv map[string]string
+
+func (m mapEnv) Get(name string) (string, bool) {
+	v, ok := m[name]
+	return v, ok
+}
+
+func (m mapEnv) Set(name, value string) error {
+	m[name] = value
+	return nil
+}
+
+// words parses src as a simple command and returns its words.
+func words(t *testing.T, src string) []*ast.Word {
+	t.Helper()
+	file, err := parser.New().Parse(src)
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", src, err)
+	}
+	return file.Stmts[0].Cmd.(*ast.CallExpr).Args
+}
+
+func TestParamExpansion(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"$HOME", "/home/gopher"},
+		{"${HOME}/src", "/home/gopher/src"},
+		{`"$HOME"`, "/home/gopher"},
+		{`'$HOME'`, "$HOME"},
+		{`\$HOME`, "$HOME"},
+		{`"\$HOME \\ \z"`, `$HOME \ \z`},
+		{`$'\$HOME'`, `\$HOME`},
+		{"$", "$"},
+		{"a$", "a$"},
+		{"${#HOME}", "12"},
+		{"${UNSET:-fallback}", "fallback"},
+		{"${EMPTY:-fallback}", "fallback"},
+		{"${EMPTY-fallback}", ""},
+		{"${UNSET-fallback}", "fallback"},
+		{`${UNSET:-"two words"}`, "two words"},
+		{"${HOME:+set}", "set"},
+		{"${UNSET:+set}", ""},
+		{"${FILE#*.}", "tar.gz"},
+		{"${FILE##*.}", "gz"},
+		{"${FILE%.*}", "archive.tar"},
+		{"${FILE%%.*}", "archive"},
+		{`${FILE#"*."}`, "archive.tar.gz"},
+		{"${PATHS/:/;}", "a;b:c"},
+		{"${PATHS//:/;}", "a;b;c"},
+		{"${PATHS//[ab]/x}", "x:x:c"},
+		{"${HOME:1:4}", "home"},
+		{"${HOME:6}", "gopher"},
+		{"${HOME: -6:3}", "gop"},
+		{"~", "/home/gopher"},
+		{"~/src", "/home/gopher/src"},
+		{"~+/x", "/work/x"},
+		{`"~"`, "~"},
+		{`\~`, "~"},
+		{"x~", "x~"},
+		{"$((1 + 2 * 3))", "7"},
+		{`"$(( ${#FILE} - 4 ))"`, "10"},
+	}
+
+	env := mapEnv{
+		"HOME":  "/home/gopher",
+		"EMPTY": "",
+		"FILE":  "archive.tar.gz",
+		"PATHS": "a:b:c",
+		"PWD":   "/work",
+	}
+	cfg := &Config{Env: env}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Literal(cfg, words(t, "echo "+tt.input)[1])
+			if err != nil {
+				t.Fatalf("Literal() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Literal(%s) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParamAssignAndError(t *testing.T) {
+	env := mapEnv{}
+	cfg := &Config{Env: env}
+
+	got, err := Literal(cfg, words(t, "echo ${NEW:=value}")[1])
+	if err != nil || got != "value" || env["NEW"] != "value" {
+		t.Errorf("${NEW:=value} = %q, %v; NEW = %q", got, err, env["NEW"])
+	}
+
+	_, err = Literal(cfg, words(t, "echo ${MISSING:?is required}")[1])
+	var unset *UnsetError
+	if !errors.As(err, &unset) || err.Error() != "MISSING: is required" {
+		t.Errorf("${MISSING:?is required} error = %v", err)
+	}
+}
+
+func TestTildeAfterColon(t *testing.T) {
+	cfg := &Config{Env: mapEnv{"HOME": "/home/gopher", "P": "/bin"}}
+
+	values := []struct {
+		input string
+		want  string
+	}{
+		{"a:~/b", "a:/home/gopher/b"},
+		{"~:~", "/home/gopher:/home/gopher"},
+		{"$P:~/go/bin", "/bin:/home/gopher/go/bin"},
+		{`a:"~"/b`, "a:~/b"},
+		{`a\:~/b`, "a:~/b"},
+		{"a:x~", "a:x~"},
+	}
+	for _, tt := range values {
+		got, err := Assignment(cfg, words(t, "echo "+tt.input)[1])
+		if err != nil || got != tt.want {
+			t.Errorf("Assignment(%s) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+
+	args := []struct {
+		input string
+		want  string
+	}{
+		{"PATH=$P:~/go/bin", "PATH=/bin:/home/gopher/go/bin"},
+		{"X=~", "X=/home/gopher"},
+		{"X=a=~", "X=a=~"},
+		{"a:~", "a:~"},
+		{"1X=~", "1X=~"},
+		{`"X"=~`, "X=~"},
+	}
+	for _, tt := range args {
+		got, err := Fields(cfg, words(t, "echo "+tt.input)[1])
+		if err != nil || len(got) != 1 || got[0] != tt.want {
+			t.Errorf("Fields(%s) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestFieldSplitting(t *testing.T) {
+	tests := []struct {
+		input string
+		ifs   *string
+		want  []string
+	}{
+		{"$SPACED", nil, []string{"a", "b", "c"}},
+		{`"$SPACED"`, nil, []string{" a  b\tc "}},
+		{"x${SPACED}y", nil, []string{"x", "a", "b", "c", "y"}},
+		{"$EMPTY", nil, []string{}},
+		{`"$EMPTY"`, nil, []string{""}},
+		{"$COLONS", ptr(":"), []string{"a", "", "b"}},
+		{"$COLONS", ptr(""), []string{"a::b:"}},
+		{"$@", nil, []string{"p", "q", "r"}},
+		{`"$@"`, nil, []string{"p q", "r", ""}},
+		{`"$*"`, ptr(","), []string{"p q,r,"}},
+		{"${UNSET:-two words}", nil, []string{"two", "words"}},
+		{`${UNSET:-"two words"}`, nil, []string{"two words"}},
+		{"{a,b}{1..2}", nil, []string{"a1", "a2", "b1", "b2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			env := mapEnv{"SPACED": " a  b\tc ", "EMPTY": "", "COLONS": "a::b:"}
+			if tt.ifs != nil {
+				env["IFS"] = *tt.ifs
+			}
+			cfg := &Config{Env: env, Params: []string{"p q", "r", ""}}
+			got, err := Fields(cfg, words(t, "echo "+tt.input)[1])
+			if err != nil {
+				t.Fatalf("Fields() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Fields(%s) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestBraces(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"a{b,c}d", []string{"abd", "acd"}},
+		{"{a,b{1,2}}", []string{"a", "b1", "b2"}},
+		{"x{,y}", []string{"x", "xy"}},
+		{"{1..3}", []string{"1", "2", "3"}},
+		{"{3..1}", []string{"3", "2", "1"}},
+		{"{01..10..4}", []string{"01", "05", "09"}},
+		{"{a..e..2}", []string{"a", "c", "e"}},
+		{"{a}", []string{"{a}"}},
+		{"{1..x}", []string{"{1..x}"}},
+		{`"{a,b}"`, []string{"{a,b}"}},
+		{`\{a,b}`, []string{"{a,b}"}},
+		{`{a,"b c"}`, []string{"a", "b c"}},
+	}
+
+	for _, tt := range tests {
+		got, err := Fields(nil, words(t, "echo "+tt.input)[1])
+		if err != nil {
+			t.Fatalf("Fields() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Braces(%s) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestArith(t *testing.T) {
+	tests := []struct {
+		expr string
+		want int64
+	}{
+		{"", 0},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"-7 / 2", -3},
+		{"7 % 3", 1},
+		{"2 ** 3 ** 2", 512},
+		{"1 << 4 | 1", 17},
+		{"!0 && 3 > 2", 1},
+		{"0 || 0", 0},
+		{"~0", -1},
+		{"010 + 0x10 + 2#11", 27},
+		{"n * 2", 84},
+		{"expr + 1", 85},
+		{"unset + 1", 1},
+		{"n > 40 ? 1 : 0", 1},
+		{"0 && (z = 1)", 0},
+	}
+
+	for _, tt := range tests {
+		env := mapEnv{"n": "42", "expr": "n * 2"}
+		got, err := Arith(&Config{Env: env}, tt.expr)
+		if err != nil {
+			t.Errorf("Arith(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Arith(%q) = %d, want %d", tt.expr, got, tt.want)
+		}
+		if _, ok := env["z"]; ok {
+			t.Errorf("Arith(%q) assigned z", tt.expr)
+		}
+	}
+
+	env := mapEnv{"i": "5"}
+	cfg := &Config{Env: env}
+	for _, expr := range []string{"i++", "i += 10", "j = i--"} {
+		if _, err := Arith(cfg, expr); err != nil {
+			t.Fatalf("Arith(%q) error = %v", expr, err)
+		}
+	}
+	if env["i"] != "15" || env["j"] != "16" {
+		t.Errorf("after assignments i = %q, j = %q, want 15 and 16", env["i"], env["j"])
+	}
+
+	for _, expr := range []string{"1 / 0", "1 +", "(1", "08", "1 @ 2", "x = x + 1 +"} {
+		if _, err := Arith(cfg, expr); err == nil {
+			t.Errorf("Arith(%q) error = nil, want an error", expr)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "main.rs", false},
+		{"?.txt", "a.txt", true},
+		{"?.txt", "ab.txt", false},
+		{"[abc]*", "apple", true},
+		{"[!abc]*", "apple", false},
+		{"[a-z][0-9]", "x7", true},
+		{"[[:digit:]]*", "1st", true},
+		{`\*`, "*", true},
+		{`\*`, "a", false},
+		{"[", "[", true},
+		{"a+b(c)", "a+b(c)", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Match(tt.pattern, tt.name)
+		if err != nil {
+			t.Fatalf("Match(%q, %q) error = %v", tt.pattern, tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.txt", ".hidden.go", "src/x.go", "src/sub/y.go", "src/sub/z.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	tests := []struct {
+		input string
+		mode  GlobMode
+		want  string
+	}{
+		{"*.go", GlobKeep, "a.go b.go"},
+		{".*.go", GlobKeep, ".hidden.go"},
+		{"[ab].go", GlobKeep, "a.go b.go"},
+		{"?.txt", GlobKeep, "c.txt"},
+		{"*/", GlobKeep, "src/"},
+		{"src/*/*.go", GlobKeep, "src/sub/y.go"},
+		{"**/*.go", GlobKeep, "a.go b.go src/sub/y.go src/x.go"},
+		{"src/**", GlobKeep, "src/sub src/sub/y.go src/sub/z.txt src/x.go"},
+		{`"*.go"`, GlobKeep, "*.go"},
+		{`\*.go`, GlobKeep, "*.go"},
+		{"*.rs", GlobKeep, "*.rs"},
+		{"*.rs x", GlobNull, "x"},
+	}
+
+	for _, tt := range tests {
+		got, err := Fields(&Config{GlobMode: tt.mode}, words(t, "echo "+tt.input)[1:]...)
+		if err != nil {
+			t.Errorf("Fields(%q) error = %v", tt.input, err)
+			continue
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("Fields(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	if _, err := Fields(&Config{GlobMode: GlobFail}, words(t, "echo *.rs")[1:]...); err == nil {
+		t.Errorf("Fields(*.rs) with GlobFail: expected error")
+	}
+	got, err := Fields(&Config{NoGlob: true}, words(t, "echo *.go")[1:]...)
+	if err != nil || !reflect.DeepEqual(got, []string{"*.go"}) {
+		t.Errorf("Fields(*.go) with NoGlob = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,199 @@
+// internal/shell/expand/glob.go
+package expand
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// HasMeta reports whether pattern contains an unescaped '*', '?' or
+// bracket expression, and so needs pathname expansion.
+func HasMeta(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '*', '?':
+			return true
+		case '[':
+			if _, n := bracketExpr(pattern[i:]); n > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Glob returns the paths matching pattern, sorted. Each '/'-separated
+// component is matched separately, so '*' never matches a '/'. A component
+// of "**" matches any number of directories, including none. Names
+// starting with '.' only match a component that starts with a literal '.'.
+func Glob(pattern string) ([]string, error) {
+	return GlobIn("", pattern)
+}
+
+// GlobIn is like Glob, but matches a relative pattern in dir rather than
+// the current directory. The paths returned are still relative.
+func GlobIn(dir, pattern string) ([]string, error) {
+	base := ""
+	if strings.HasPrefix(pattern, "/") {
+		base = "/"
+		pattern = strings.TrimLeft(pattern, "/")
+	}
+
+	var segments []string
+	for _, seg := range strings.Split(pattern, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	dirOnly := strings.HasSuffix(pattern, "/")
+
+	var matches []string
+	if err := globSegments(dir, base, segments, dirOnly, &matches); err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return dedupe(matches), nil
+}
+
+func globSegments(dir, base string, segments []string, dirOnly bool, matches *[]string) error {
+	if len(segments) == 0 {
+		if base == "" {
+			return nil
+		}
+		if dirOnly {
+			if info, err := os.Stat(inDir(dir, base)); err != nil || !info.IsDir() {
+				return nil
+			}
+			*matches = append(*matches, base+"/")
+			return nil
+		}
+		*matches = append(*matches, base)
+		return nil
+	}
+
+	seg, rest := segments[0], segments[1:]
+
+	if seg == "**" {
+		return globStar(dir, base, rest, dirOnly, matches)
+	}
+
+	if !HasMeta(seg) {
+		path := joinPath(base, unescape(seg))
+		if _, err := os.Lstat(inDir(dir, path)); err != nil {
+			return nil
+		}
+		return globSegments(dir, path, rest, dirOnly, matches)
+	}
+
+	re, err := compilePattern(seg)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(inDir(dir, dirPath(base)))
+	if err != nil {
+		// Unreadable or missing directories simply produce no
+		// matches, as in other shells.
+		return nil
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if hidden(name, seg) || !re.MatchString(name) {
+			continue
+		}
+		path := joinPath(base, name)
+		if len(rest) > 0 || dirOnly {
+			if info, err := os.Stat(inDir(dir, path)); err != nil || !info.IsDir() {
+				continue
+			}
+		}
+		if err := globSegments(dir, path, rest, dirOnly, matches); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// globStar expands a "**" component. As the last component it matches
+// every file and directory below base; otherwise it matches base and each
+// directory below it, and the remaining components are matched from
+// there.
+func globStar(dir, base string, rest []string, dirOnly bool, matches *[]string) error {
+	if len(rest) > 0 {
+		if err := globSegments(dir, base, rest, dirOnly, matches); err != nil {
+			return err
+		}
+	}
+
+	entries, err := os.ReadDir(inDir(dir, dirPath(base)))
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		path := joinPath(base, name)
+		isDir := entry.IsDir()
+
+		if len(rest) == 0 && (isDir || !dirOnly) {
+			if dirOnly {
+				*matches = append(*matches, path+"/")
+			} else {
+				*matches = append(*matches, path)
+			}
+		}
+		// Symlinked directories are not followed, to avoid cycles.
+		if isDir {
+			if err := globStar(dir, path, rest, dirOnly, matches); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hidden reports whether name should be skipped when matching seg: names
+// starting with '.' need the pattern to start with a literal '.'.
+func hidden(name, seg string) bool {
+	return strings.HasPrefix(name, ".") && !strings.HasPrefix(seg, ".")
+}
+
+func joinPath(base, name string) string {
+	switch {
+	case base == "":
+		return name
+	case strings.HasSuffix(base, "/"):
+		return base + name
+	}
+	return base + "/" + name
+}
+
+func dirPath(base string) string {
+	if base == "" {
+		return "."
+	}
+	return base
+}
+
+// inDir returns where path is when relative to dir.
+func inDir(dir, path string) string {
+	if dir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func dedupe(sorted []string) []string {
+	out := sorted[:0]
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
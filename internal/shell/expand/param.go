@@ -0,0 +1,296 @@
+// internal/shell/expand/param.go
+package expand
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/krzko/gosh/internal/shell/ast"
+)
+
+// UnsetError is the error from ${name?word} or ${name:?word} when the
+// parameter is unset, or null. Unlike other expansion errors, it stops a
+// shell that is not interactive.
+type UnsetError struct {
+	Param string
+	Msg   string
+}
+
+func (e *UnsetError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Param, e.Msg)
+}
+
+func (cfg *Config) lookup(name string) (string, bool) {
+	if cfg == nil {
+		return "", false
+	}
+	switch {
+	case name == "#":
+		return strconv.Itoa(len(cfg.Params)), true
+	case name == "@":
+		return strings.Join(cfg.Params, " "), true
+	case name == "*":
+		// "$*" joins the parameters with the first character of IFS.
+		sep := cfg.ifs()
+		if sep != "" {
+			sep = sep[:1]
+		}
+		return strings.Join(cfg.Params, sep), true
+	case name != "0" && name[0] >= '0' && name[0] <= '9':
+		n, err := strconv.Atoi(name)
+		if err != nil || n > len(cfg.Params) {
+			return "", false
+		}
+		return cfg.Params[n-1], true
+	}
+	if cfg.Env == nil {
+		return "", false
+	}
+	return cfg.Env.Get(name)
+}
+
+// params returns the positional parameters.
+func (cfg *Config) params() []string {
+	if cfg == nil {
+		return nil
+	}
+	return cfg.Params
+}
+
+// isParamList reports whether part is a plain $@, which expands to one
+// field per positional parameter.
+func isParamList(part ast.WordPart) bool {
+	p, ok := part.(*ast.ParamExp)
+	return ok && p.Param == "@" && p.Op == ast.ParamNone && !p.Length
+}
+
+// substitutes reports whether p expands to its word rather than to a
+// value: ${x-word} and ${x:-word} when x is unset or, with the colon,
+// empty, and ${x+word} and ${x:+word} otherwise. The word is then
+// expanded in place, so that quotes in it keep it from being split.
+func (cfg *Config) substitutes(p *ast.ParamExp) bool {
+	if p.Length {
+		return false
+	}
+	value, set := cfg.lookup(p.Param)
+	switch p.Op {
+	case ast.DefaultUnset:
+		return !set
+	case ast.DefaultUnsetOrNull:
+		return value == ""
+	case ast.AlternateUnset:
+		return set
+	case ast.AlternateUnsetOrNull:
+		return value != ""
+	}
+	return false
+}
+
+func (cfg *Config) paramExp(p *ast.ParamExp) (string, error) {
+	value, set := cfg.lookup(p.Param)
+	if p.Length {
+		return strconv.Itoa(utf8.RuneCountInString(value)), nil
+	}
+
+	switch p.Op {
+	case ast.ParamNone:
+		return value, nil
+
+	case ast.DefaultUnset, ast.DefaultUnsetOrNull:
+		if !set || (value == "" && p.Op == ast.DefaultUnsetOrNull) {
+			return Literal(cfg, p.Word)
+		}
+		return value, nil
+
+	case ast.AssignUnset, ast.AssignUnsetOrNull:
+		if !set || (value == "" && p.Op == ast.AssignUnsetOrNull) {
+			word, err := Literal(cfg, p.Word)
+			if err != nil {
+				return "", err
+			}
+			if cfg == nil || cfg.Env == nil {
+				return word, nil
+			}
+			if err := cfg.Env.Set(p.Param, word); err != nil {
+				return "", err
+			}
+			return word, nil
+		}
+		return value, nil
+
+	case ast.ErrorUnset, ast.ErrorUnsetOrNull:
+		if !set || (value == "" && p.Op == ast.ErrorUnsetOrNull) {
+			msg, err := Literal(cfg, p.Word)
+			if err != nil {
+				return "", err
+			}
+			if msg == "" {
+				msg = "parameter null or not set"
+			}
+			return "", &UnsetError{Param: p.Param, Msg: msg}
+		}
+		return value, nil
+
+	case ast.AlternateUnset, ast.AlternateUnsetOrNull:
+		if !set || (value == "" && p.Op == ast.AlternateUnsetOrNull) {
+			return "", nil
+		}
+		return Literal(cfg, p.Word)
+
+	case ast.RemSmallPrefix, ast.RemLargePrefix, ast.RemSmallSuffix, ast.RemLargeSuffix:
+		pattern, err := Pattern(cfg, p.Word)
+		if err != nil {
+			return "", err
+		}
+		return removeAffix(value, pattern, p.Op)
+
+	case ast.ReplaceFirst, ast.ReplaceAll:
+		pattern, err := Pattern(cfg, p.Word)
+		if err != nil {
+			return "", err
+		}
+		repl, err := Literal(cfg, p.Repl)
+		if err != nil {
+			return "", err
+		}
+		return replace(value, pattern, repl, p.Op == ast.ReplaceAll)
+
+	case ast.Substring:
+		return cfg.substring(value, p)
+	}
+	return "", fmt.Errorf("%s: unsupported expansion %s", p.Param, p.Op)
+}
+
+// removeAffix removes the shortest or longest prefix or suffix of value
+// that matches pattern.
+func removeAffix(value, pattern string, op ast.ParamOp) (string, error) {
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	n := len(value)
+	switch op {
+	case ast.RemSmallPrefix:
+		for i := 0; i <= n; i++ {
+			if re.MatchString(value[:i]) {
+				return value[i:], nil
+			}
+		}
+	case ast.RemLargePrefix:
+		for i := n; i >= 0; i-- {
+			if re.MatchString(value[:i]) {
+				return value[i:], nil
+			}
+		}
+	case ast.RemSmallSuffix:
+		for i := n; i >= 0; i-- {
+			if re.MatchString(value[i:]) {
+				return value[:i], nil
+			}
+		}
+	case ast.RemLargeSuffix:
+		for i := 0; i <= n; i++ {
+			if re.MatchString(value[i:]) {
+				return value[:i], nil
+			}
+		}
+	}
+	return value, nil
+}
+
+// replace substitutes the longest match of pattern at the leftmost
+// position where it matches, or at every such position if all is set.
+func replace(value, pattern, repl string, all bool) (string, error) {
+	if pattern == "" {
+		return value, nil
+	}
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	i := 0
+	for i < len(value) {
+		end := -1
+		for j := len(value); j > i; j-- {
+			if re.MatchString(value[i:j]) {
+				end = j
+				break
+			}
+		}
+		if end < 0 {
+			sb.WriteByte(value[i])
+			i++
+			continue
+		}
+		sb.WriteString(repl)
+		i = end
+		if !all {
+			sb.WriteString(value[i:])
+			return sb.String(), nil
+		}
+	}
+	return sb.String(), nil
+}
+
+// substring implements ${x:offset:length}. A negative offset counts from
+// the end of the value, and a negative length from the end of the value
+// back.
+func (cfg *Config) substring(value string, p *ast.ParamExp) (string, error) {
+	runes := []rune(value)
+	n := len(runes)
+
+	offset, err := cfg.intOperand(p.Word)
+	if err != nil {
+		return "", err
+	}
+	if offset < 0 {
+		offset += n
+		if offset < 0 {
+			offset = 0
+		}
+	}
+	if offset > n {
+		offset = n
+	}
+
+	end := n
+	if p.Repl != nil {
+		length, err := cfg.intOperand(p.Repl)
+		if err != nil {
+			return "", err
+		}
+		if length < 0 {
+			end = n + length
+		} else {
+			end = offset + length
+		}
+		if end > n {
+			end = n
+		}
+		if end < offset {
+			return "", fmt.Errorf("%s: substring expression < 0", p.Param)
+		}
+	}
+	return string(runes[offset:end]), nil
+}
+
+func (cfg *Config) intOperand(w *ast.Word) (int, error) {
+	s, err := Literal(cfg, w)
+	if err != nil {
+		return 0, err
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid number", s)
+	}
+	return n, nil
+}
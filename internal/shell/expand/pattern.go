@@ -0,0 +1,114 @@
+// internal/shell/expand/pattern.go
+package expand
+
+import (
+	"regexp"
+	"strings"
+)
+
+// QuotePattern escapes the characters that are special in shell patterns
+// so that s matches only itself.
+func QuotePattern(s string) string {
+	if !strings.ContainsAny(s, `*?[]\`) {
+		return s
+	}
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// Match reports whether name matches the shell pattern in its entirety.
+func Match(pattern, name string) (bool, error) {
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(name), nil
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?s:" + patternRegexp(pattern) + ")$")
+}
+
+// patternRegexp translates a shell pattern into an unanchored regular
+// expression: '*' matches any string, '?' any character, and [...] a
+// bracket expression, with '!' or '^' negating it.
+func patternRegexp(pattern string) string {
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			} else {
+				sb.WriteString(`\\`)
+			}
+		case '[':
+			class, n := bracketExpr(pattern[i:])
+			if n == 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			sb.WriteString(class)
+			i += n - 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	return sb.String()
+}
+
+// bracketExpr translates the bracket expression at the start of s into a
+// regular expression character class, returning the class and the number
+// of bytes consumed, or 0 if s does not start a valid bracket expression.
+func bracketExpr(s string) (string, int) {
+	i := 1
+	var sb strings.Builder
+	sb.WriteByte('[')
+	if i < len(s) && (s[i] == '!' || s[i] == '^') {
+		sb.WriteByte('^')
+		i++
+	}
+	// A ']' straight after the opening bracket is a literal.
+	if i < len(s) && s[i] == ']' {
+		sb.WriteString(`\]`)
+		i++
+	}
+	for ; i < len(s); i++ {
+		switch c := s[i]; c {
+		case ']':
+			sb.WriteByte(']')
+			return sb.String(), i + 1
+		case '\\':
+			if i+1 < len(s) {
+				i++
+			}
+			sb.WriteByte('\\')
+			sb.WriteByte(s[i])
+		case '[':
+			// Character classes such as [:alpha:] pass straight
+			// through, since the regexp syntax is the same.
+			if end := strings.Index(s[i:], ":]"); i+1 < len(s) && s[i+1] == ':' && end > 0 {
+				sb.WriteString(s[i : i+end+2])
+				i += end + 1
+				continue
+			}
+			sb.WriteString(`\[`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", 0
+}
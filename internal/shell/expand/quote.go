@@ -0,0 +1,120 @@
+// internal/shell/expand/quote.go
+package expand
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// unescapeDouble removes the backslashes that are escapes within double
+// quotes: those before $, `, ", \ and newline, which is removed as well.
+// Any other backslash is literal.
+func unescapeDouble(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case '$', '`', '"', '\\':
+				i++
+			case '\n':
+				i++
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// simpleEscapes maps the characters after a backslash in $'...' to what
+// they stand for.
+var simpleEscapes = map[byte]string{
+	'a': "\a", 'b': "\b", 'e': "\x1b", 'E': "\x1b", 'f': "\f", 'n': "\n",
+	'r': "\r", 't': "\t", 'v': "\v", '\\': "\\", '\'': "'", '"': "\"",
+	'?': "?",
+}
+
+// decodeEscapes decodes the backslash escapes of $'...' as bash does:
+// the C escapes such as \n and \t, \nnn in octal, \xHH in hex, \uHHHH and
+// \UHHHHHHHH as Unicode code points, and \cX as a control character. An
+// unknown escape is kept as written.
+func decodeEscapes(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		c := s[i]
+		if esc, ok := simpleEscapes[c]; ok {
+			sb.WriteString(esc)
+			continue
+		}
+		switch c {
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			n := digits(s[i:], 3, 8)
+			v, _ := strconv.ParseUint(s[i:i+n], 8, 8)
+			sb.WriteByte(byte(v))
+			i += n - 1
+		case 'x', 'u', 'U':
+			width := 2
+			switch c {
+			case 'u':
+				width = 4
+			case 'U':
+				width = 8
+			}
+			n := digits(s[i+1:], width, 16)
+			if n == 0 {
+				sb.WriteString(s[i-1 : i+1])
+				continue
+			}
+			v, _ := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			if c == 'x' {
+				sb.WriteByte(byte(v))
+			} else if utf8.ValidRune(rune(v)) {
+				sb.WriteRune(rune(v))
+			}
+			i += n
+		case 'c':
+			if i+1 == len(s) {
+				sb.WriteString(`\c`)
+				continue
+			}
+			i++
+			sb.WriteByte(toUpper(s[i]) & 0x1f)
+		default:
+			sb.WriteString(s[i-1 : i+1])
+		}
+	}
+	return sb.String()
+}
+
+// digits returns how many of the first width bytes of s are digits in
+// the given base, which is 8 or 16.
+func digits(s string, width, base int) int {
+	n := 0
+	for n < width && n < len(s) {
+		c := toUpper(s[n])
+		if !(c >= '0' && c <= '7' || base == 16 && (c >= '8' && c <= '9' || c >= 'A' && c <= 'F')) {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+func toUpper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
@@ -5,29 +5,59 @@ import (
 	"bufio"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// Config controls where history is kept and how much of it.
+type Config struct {
+	// File is the history file. A relative path is taken from the home
+	// directory, as is one starting with "~/".
+	File string
+	// Size is the number of entries kept.
+	Size int
+}
+
+// DefaultConfig returns the history settings used without a config file.
+func DefaultConfig() Config {
+	return Config{File: ".gosh_history", Size: 1000}
+}
+
+// Path returns the absolute path of the history file.
+func (c Config) Path() (string, error) {
+	file := strings.TrimPrefix(c.File, "~/")
+	if filepath.IsAbs(file) {
+		return file, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, file), nil
+}
+
 type Manager struct {
 	entries    []string
 	maxEntries int
 	filePath   string
 }
 
-func NewManager(historyFile string) (*Manager, error) {
-	homeDir, err := os.UserHomeDir()
+func NewManager(cfg Config) (*Manager, error) {
+	filePath, err := cfg.Path()
 	if err != nil {
 		return nil, err
 	}
 
-	filePath := filepath.Join(homeDir, historyFile)
 	manager := &Manager{
 		entries:    make([]string, 0),
-		maxEntries: 1000,
+		maxEntries: cfg.Size,
 		filePath:   filePath,
 	}
 
 	// Create history file if it doesn't exist
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return nil, err
+		}
 		file, err := os.Create(filePath)
 		if err != nil {
 			return nil, err
@@ -46,11 +76,21 @@ func NewManager(historyFile string) (*Manager, error) {
 func (m *Manager) Add(command string) error {
 	m.entries = append(m.entries, command)
 	if len(m.entries) > m.maxEntries {
-		m.entries = m.entries[1:]
+		m.entries = m.entries[len(m.entries)-m.maxEntries:]
 	}
 	return m.save()
 }
 
+// Size returns the number of entries kept.
+func (m *Manager) Size() int {
+	return m.maxEntries
+}
+
+// Entries returns the commands in the history, oldest first.
+func (m *Manager) Entries() []string {
+	return m.entries
+}
+
 func (m *Manager) Save() error {
 	return m.save()
 }
@@ -66,9 +106,25 @@ func (m *Manager) load() error {
 	}
 	defer file.Close()
 
+	// save ends each line but the last of a command of several lines
+	// with a backslash, doubling those in the command itself.
 	scanner := bufio.NewScanner(file)
+	var entry strings.Builder
 	for scanner.Scan() {
-		m.entries = append(m.entries, scanner.Text())
+		line, more := unescape(scanner.Text())
+		if more {
+			entry.WriteString(line + "\n")
+			continue
+		}
+		entry.WriteString(line)
+		m.entries = append(m.entries, entry.String())
+		entry.Reset()
+	}
+	if entry.Len() > 0 {
+		m.entries = append(m.entries, entry.String())
+	}
+	if len(m.entries) > m.maxEntries {
+		m.entries = m.entries[len(m.entries)-m.maxEntries:]
 	}
 	return scanner.Err()
 }
@@ -82,9 +138,33 @@ func (m *Manager) save() error {
 
 	writer := bufio.NewWriter(file)
 	for _, entry := range m.entries {
+		entry = strings.ReplaceAll(entry, "\\", "\\\\")
+		entry = strings.ReplaceAll(entry, "\n", "\\\n")
 		if _, err := writer.WriteString(entry + "\n"); err != nil {
 			return err
 		}
 	}
 	return writer.Flush()
 }
+
+// unescape undoes the escaping save gives a line of the history file,
+// reporting whether the command goes on to the next line. A backslash
+// not doubled is kept as it is, as in files written before they were
+// doubled.
+func unescape(line string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] != '\\' {
+			b.WriteByte(line[i])
+			continue
+		}
+		if i+1 == len(line) {
+			return b.String(), true
+		}
+		if line[i+1] == '\\' {
+			i++
+		}
+		b.WriteByte('\\')
+	}
+	return b.String(), false
+}
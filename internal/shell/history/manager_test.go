@@ -0,0 +1,35 @@
+// internal/shell/history/manager_test.go
+package history
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSaveLoad(t *testing.T) {
+	cfg := Config{File: filepath.Join(t.TempDir(), "history"), Size: 10}
+	m, err := NewManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []string{
+		`echo C:\`,
+		"ls",
+		`printf 'a\nb\\'`,
+		"for x in a b\ndo\n  echo \\\n    $x\ndone",
+	}
+	for _, entry := range entries {
+		if err := m.Add(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded, err := NewManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Entries(); !slices.Equal(got, entries) {
+		t.Errorf("Entries() = %q, want %q", got, entries)
+	}
+}
@@ -0,0 +1,284 @@
+// internal/shell/httpclient/client.go
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config controls the client that the http and https builtins send
+// requests with.
+type Config struct {
+	// ConnectTimeout limits connecting, including the TLS handshake,
+	// ResponseTimeout waiting for the response once the request is sent,
+	// and Timeout the whole request, including reading the response
+	// body. Zero means no limit.
+	ConnectTimeout  time.Duration
+	ResponseTimeout time.Duration
+	Timeout         time.Duration
+	// Retries is how many times a request is retried after a connection
+	// error or a 5xx response, waiting RetryBackoff before the first
+	// retry and twice as long before each one after that.
+	Retries      int
+	RetryBackoff time.Duration
+	// Proxy is the proxy to send requests through. If it is empty,
+	// HTTP_PROXY or HTTPS_PROXY is used. Either way hosts listed in
+	// NO_PROXY are not proxied.
+	Proxy string
+	// Insecure turns off checking servers' certificates. CACert is a PEM
+	// file of the certificates to check them against instead of the
+	// system's, and Cert and Key a client certificate and its key. Key
+	// may be left out if Cert holds both.
+	Insecure bool
+	CACert   string
+	Cert     string
+	Key      string
+	// MaxRedirects is how many redirects are followed; with none, a
+	// redirect is returned as the response.
+	MaxRedirects int
+	// HTTPVersion is "1.1" or "2" to use only that version, or "" to use
+	// HTTP/2 with servers that support it.
+	HTTPVersion string
+}
+
+// DefaultConfig returns the client settings used without a config file.
+func DefaultConfig() Config {
+	return Config{
+		ConnectTimeout: 10 * time.Second,
+		// A server that never answers is given up on, while a slow
+		// download is not.
+		ResponseTimeout: 30 * time.Second,
+		RetryBackoff:    time.Second,
+		MaxRedirects:    10,
+	}
+}
+
+// CheckHTTPVersion returns an error if version is not a valid
+// HTTPVersion.
+func CheckHTTPVersion(version string) error {
+	switch version {
+	case "", "1.1", "2":
+		return nil
+	}
+	return fmt.Errorf("unknown HTTP version %q (want 1.1 or 2)", version)
+}
+
+// New returns a client configured by cfg. The proxy variables are read
+// from env, a list of name=value pairs.
+func New(cfg Config, env []string) (*http.Client, error) {
+	if err := CheckHTTPVersion(cfg.HTTPVersion); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := cfg.proxy(env)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: cfg.ConnectTimeout, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   cfg.ConnectTimeout,
+		ResponseHeaderTimeout: cfg.ResponseTimeout,
+		ForceAttemptHTTP2:     true,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	var rt http.RoundTripper = transport
+	switch cfg.HTTPVersion {
+	case "1.1":
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	case "2":
+		rt = requireHTTP2{transport}
+	}
+
+	return &http.Client{
+		Transport: rt,
+		Timeout:   cfg.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) <= cfg.MaxRedirects {
+				return nil
+			}
+			if cfg.MaxRedirects == 0 {
+				return http.ErrUseLastResponse
+			}
+			return fmt.Errorf("stopped after %d redirects", cfg.MaxRedirects)
+		},
+	}, nil
+}
+
+func (cfg Config) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.Insecure}
+	if cfg.CACert != "" {
+		data, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%s: no certificates found", cfg.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.Cert == "" {
+		if cfg.Key != "" {
+			return nil, fmt.Errorf("client key given without a certificate")
+		}
+		return tlsConfig, nil
+	}
+	key := cfg.Key
+	if key == "" {
+		key = cfg.Cert
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.Cert, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	tlsConfig.Certificates = []tls.Certificate{cert}
+	return tlsConfig, nil
+}
+
+// proxy returns the function that picks the proxy for each request.
+// Unlike http.ProxyFromEnvironment it reads the variables from env
+// rather than the process, and on each call, so that it sees them as the
+// shell has them.
+func (cfg Config) proxy(env []string) (func(*http.Request) (*url.URL, error), error) {
+	vars := make(map[string]string)
+	for _, kv := range env {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			vars[name] = value
+		}
+	}
+	getenv := func(name string) string {
+		if value := vars[name]; value != "" {
+			return value
+		}
+		return vars[strings.ToLower(name)]
+	}
+
+	var fixed *url.URL
+	if cfg.Proxy != "" {
+		u, err := parseProxy(cfg.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		fixed = u
+	}
+	noProxy := getenv("NO_PROXY")
+
+	return func(req *http.Request) (*url.URL, error) {
+		if Bypass(noProxy, req.URL) {
+			return nil, nil
+		}
+		if fixed != nil {
+			return fixed, nil
+		}
+		// As with http.ProxyFromEnvironment, requests to this machine
+		// are not sent to a proxy from the environment.
+		if isLocal(req.URL.Hostname()) {
+			return nil, nil
+		}
+		proxy := getenv("HTTP_PROXY")
+		if req.URL.Scheme == "https" {
+			proxy = getenv("HTTPS_PROXY")
+		}
+		if proxy == "" {
+			return nil, nil
+		}
+		return parseProxy(proxy)
+	}, nil
+}
+
+func parseProxy(proxy string) (*url.URL, error) {
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", proxy)
+	}
+	return u, nil
+}
+
+func isLocal(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Bypass reports whether u is not to be proxied according to noProxy, a
+// NO_PROXY list of comma-separated hosts, domains, host:port pairs and
+// CIDR blocks, or * for all. A domain matches its subdomains, with or
+// without a leading dot.
+func Bypass(noProxy string, u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	ip := net.ParseIP(host)
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "*" {
+			return true
+		}
+		if entry == "" {
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+			entry = h
+		}
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// requireHTTP2 fails requests that the server answers with an HTTP
+// version other than 2.
+type requireHTTP2 struct {
+	rt http.RoundTripper
+}
+
+func (r requireHTTP2) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("HTTP/2 is only supported over https")
+	}
+	resp, err := r.rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ProtoMajor != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s does not support HTTP/2", req.URL.Host)
+	}
+	return resp, nil
+}
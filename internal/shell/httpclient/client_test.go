@@ -0,0 +1,262 @@
+// internal/shell/httpclient/client_test.go
+package httpclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBypass(t *testing.T) {
+	tests := []struct {
+		noProxy, url string
+		want         bool
+	}{
+		{"", "http://example.com", false},
+		{"*", "http://example.com", true},
+		{"example.com", "http://example.com/x", true},
+		{"example.com", "http://api.example.com", true},
+		{".example.com", "http://api.example.com", true},
+		{"*.example.com", "http://example.com", true},
+		{"example.com", "http://notexample.com", false},
+		{"a.test, example.com:8080", "http://example.com:8080", true},
+		{"example.com:8080", "http://example.com", false},
+		{"example.com:443", "https://example.com", true},
+		{"10.0.0.0/8", "http://10.1.2.3:9000", true},
+		{"10.0.0.0/8", "http://192.168.0.1", false},
+		{"EXAMPLE.com", "http://Example.COM", true},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got := Bypass(tt.noProxy, u); got != tt.want {
+			t.Errorf("Bypass(%q, %q) = %t, want %t", tt.noProxy, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestProxy(t *testing.T) {
+	env := []string{"HTTP_PROXY=proxy:3128", "https_proxy=https://secure:443", "NO_PROXY=internal.test"}
+	tests := []struct {
+		proxy, url, want string
+	}{
+		{"", "http://example.com", "http://proxy:3128"},
+		{"", "https://example.com", "https://secure:443"},
+		{"", "http://internal.test", ""},
+		{"", "http://localhost:8080", ""},
+		{"", "http://127.0.0.1", ""},
+		{"other:8080", "http://example.com", "http://other:8080"},
+		{"other:8080", "http://localhost", "http://other:8080"},
+		{"other:8080", "http://internal.test", ""},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Proxy = tt.proxy
+		proxy, err := cfg.proxy(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		u, err := proxy(req)
+		if err != nil {
+			t.Errorf("proxy %q for %s error = %v", tt.proxy, tt.url, err)
+			continue
+		}
+		got := ""
+		if u != nil {
+			got = u.String()
+		}
+		if got != tt.want {
+			t.Errorf("proxy %q for %s = %q, want %q", tt.proxy, tt.url, got, tt.want)
+		}
+	}
+}
+
+// get sends a GET request to url with a client for cfg, returning the
+// response's protocol.
+func get(cfg Config, url string) (string, error) {
+	client, err := New(cfg, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.Proto, nil
+}
+
+// writePEM writes the PEM blocks of the given type and DER bytes to a
+// file in dir.
+func writePEM(t *testing.T, dir, name string, blocks ...*pem.Block) string {
+	t.Helper()
+	var data []byte
+	for _, b := range blocks {
+		data = append(data, pem.EncodeToMemory(b)...)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTLS(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+	}))
+	srv.EnableHTTP2 = true
+	srv.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+	dir := t.TempDir()
+	caCert := writePEM(t, dir, "ca.pem", &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+
+	if _, err := get(DefaultConfig(), srv.URL); err == nil {
+		t.Errorf("GET with an untrusted certificate error = nil")
+	}
+	cfg := DefaultConfig()
+	cfg.Insecure = true
+	if _, err := get(cfg, srv.URL); err != nil {
+		t.Errorf("GET with Insecure error = %v", err)
+	}
+
+	cfg = DefaultConfig()
+	cfg.CACert = caCert
+	for version, want := range map[string]string{"": "HTTP/2.0", "2": "HTTP/2.0", "1.1": "HTTP/1.1"} {
+		cfg.HTTPVersion = version
+		proto, err := get(cfg, srv.URL)
+		if err != nil || proto != want {
+			t.Errorf("GET with HTTPVersion %q = %q, %v, want %s", version, proto, err, want)
+		}
+	}
+
+	// A client certificate, with its key in the same file.
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gosh client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = DefaultConfig()
+	cfg.CACert = caCert
+	cfg.Cert = writePEM(t, dir, "client.pem", &pem.Block{Type: "CERTIFICATE", Bytes: der}, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	client, err := New(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "gosh client" {
+		t.Errorf("server saw client certificate %q, want gosh client", body)
+	}
+
+	for _, cfg := range []Config{
+		{CACert: filepath.Join(dir, "missing.pem")},
+		{CACert: cfg.Cert + "x"},
+		{Key: cfg.Cert},
+		{HTTPVersion: "3"},
+	} {
+		if _, err := New(cfg, nil); err == nil {
+			t.Errorf("New(%+v) error = nil, want an error", cfg)
+		}
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n := strings.TrimPrefix(r.URL.Path, "/r"); n != "0" {
+			http.Redirect(w, r, "/r"+string(n[0]-1), http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		max     int
+		status  int
+		wantErr bool
+	}{
+		{10, http.StatusOK, false},
+		{3, http.StatusOK, false},
+		{2, 0, true},
+		{0, http.StatusFound, false},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.MaxRedirects = tt.max
+		client, err := New(cfg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Get(srv.URL + "/r3")
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("MaxRedirects %d error = nil, want an error", tt.max)
+			}
+			continue
+		}
+		if err != nil || resp.StatusCode != tt.status {
+			t.Errorf("MaxRedirects %d = %v, %v, want status %d", tt.max, resp, err, tt.status)
+			continue
+		}
+		resp.Body.Close()
+	}
+
+	cfg := DefaultConfig()
+	cfg.HTTPVersion = "2"
+	if _, err := get(cfg, srv.URL+"/r0"); err == nil {
+		t.Errorf("HTTP/2 over http error = nil")
+	}
+}
+
+func TestResponseTimeout(t *testing.T) {
+	stop := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-stop
+	}))
+	defer srv.Close()
+	defer close(stop)
+
+	if DefaultConfig().ResponseTimeout <= 0 {
+		t.Errorf("default ResponseTimeout = %v, want a limit", DefaultConfig().ResponseTimeout)
+	}
+	cfg := DefaultConfig()
+	cfg.ResponseTimeout = 50 * time.Millisecond
+	if _, err := get(cfg, srv.URL); err == nil {
+		t.Errorf("request to a server that never answers error = nil")
+	}
+}
@@ -0,0 +1,935 @@
+// internal/shell/parser/lexer.go
+package parser
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/krzko/gosh/internal/shell/ast"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNewline
+	tokWord
+	tokIONumber
+
+	// Operators
+	tokPipe      // |
+	tokOrIf      // ||
+	tokAmp       // &
+	tokAndIf     // &&
+	tokSemi      // ;
+	tokDSemi     // ;;
+	tokLParen    // (
+	tokRParen    // )
+	tokLess      // <
+	tokGreat     // >
+	tokDGreat    // >>
+	tokDLess     // <<
+	tokDLessDash // <<-
+	tokTLess     // <<<
+	tokLessAnd   // <&
+	tokGreatAnd  // >&
+	tokLessGreat // <>
+	tokClobber   // >|
+	tokAndGreat  // &>
+	tokAndDGreat // &>>
+)
+
+// operators is ordered so that longer operators are matched before their
+// prefixes.
+var operators = []struct {
+	text string
+	kind tokenKind
+}{
+	{"&>>", tokAndDGreat},
+	{"<<-", tokDLessDash},
+	{"<<<", tokTLess},
+	{"||", tokOrIf},
+	{"&&", tokAndIf},
+	{";;", tokDSemi},
+	{">>", tokDGreat},
+	{"<<", tokDLess},
+	{"<&", tokLessAnd},
+	{">&", tokGreatAnd},
+	{"<>", tokLessGreat},
+	{">|", tokClobber},
+	{"&>", tokAndGreat},
+	{"|", tokPipe},
+	{"&", tokAmp},
+	{";", tokSemi},
+	{"(", tokLParen},
+	{")", tokRParen},
+	{"<", tokLess},
+	{">", tokGreat},
+}
+
+type token struct {
+	kind tokenKind
+	pos  ast.Pos
+	text string
+	word *ast.Word
+	// aliases lists the aliases whose expansion produced the token.
+	aliases []string
+	// checkAlias is set for a word following an alias that ends in a
+	// blank, which is itself checked for an alias.
+	checkAlias bool
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of input"
+	case tokNewline:
+		return "newline"
+	}
+	return "`" + t.text + "'"
+}
+
+// lexer turns source text into tokens. It is driven by the parser one
+// token at a time so that context-sensitive constructs can be added
+// without a separate pass.
+type lexer struct {
+	src  string
+	off  int
+	line int
+	col  int
+	// aliases are expanded in the commands of substitutions, which are
+	// parsed as the lexer meets them.
+	aliases map[string]string
+	// heredocs are the here-documents whose bodies start after the next
+	// newline.
+	heredocs []*ast.Redirect
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: src, line: 1, col: 1}
+}
+
+// newLexerAt returns a lexer for text that stands in for the input at
+// pos, such as the value of an alias, so that errors in it are reported
+// at about the right place.
+func newLexerAt(src string, pos ast.Pos) *lexer {
+	return &lexer{src: src, line: pos.Line, col: pos.Col}
+}
+
+func (l *lexer) pos() ast.Pos {
+	return ast.Pos{Offset: l.off, Line: l.line, Col: l.col}
+}
+
+func (l *lexer) eof() bool {
+	return l.off >= len(l.src)
+}
+
+func (l *lexer) peek() rune {
+	if l.eof() {
+		return utf8.RuneError
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.off:])
+	return r
+}
+
+func (l *lexer) peekAt(n int) byte {
+	if l.off+n >= len(l.src) {
+		return 0
+	}
+	return l.src[l.off+n]
+}
+
+func (l *lexer) advance() rune {
+	r, size := utf8.DecodeRuneInString(l.src[l.off:])
+	l.off += size
+	if r == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return r
+}
+
+func (l *lexer) errorf(pos ast.Pos, format string, args ...interface{}) error {
+	return newError(pos, format, args...)
+}
+
+// incomplete returns an error for input that ends inside a construct
+// started at pos.
+func incomplete(pos ast.Pos, format string, args ...interface{}) error {
+	err := newError(pos, format, args...)
+	err.Incomplete = true
+	return err
+}
+
+func isBlank(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
+func isMeta(r rune) bool {
+	switch r {
+	case ' ', '\t', '\n', '|', '&', ';', '<', '>', '(', ')':
+		return true
+	}
+	return false
+}
+
+// skipBlanks skips whitespace, line continuations and comments.
+func (l *lexer) skipBlanks() {
+	for !l.eof() {
+		r := l.peek()
+		switch {
+		case isBlank(r):
+			l.advance()
+		case r == '\\' && l.peekAt(1) == '\n':
+			l.advance()
+			l.advance()
+		case r == '#':
+			for !l.eof() && l.peek() != '\n' {
+				l.advance()
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipBlanks()
+	pos := l.pos()
+	if l.eof() {
+		if len(l.heredocs) > 0 {
+			r := l.heredocs[0]
+			delim, _ := heredocDelim(r.Word)
+			return token{}, incomplete(r.OpPos, "here-document ended before `%s'", delim)
+		}
+		return token{kind: tokEOF, pos: pos}, nil
+	}
+
+	if l.peek() == '\n' {
+		l.advance()
+		if err := l.heredocBodies(); err != nil {
+			return token{}, err
+		}
+		return token{kind: tokNewline, pos: pos, text: "\n"}, nil
+	}
+
+	if n := l.ioNumberLen(); n > 0 {
+		for i := 0; i < n; i++ {
+			l.advance()
+		}
+		return token{kind: tokIONumber, pos: pos, text: l.src[pos.Offset:l.off]}, nil
+	}
+
+	// <( and >( start a process substitution rather than a
+	// redirection.
+	rest := l.src[l.off:]
+	if !strings.HasPrefix(rest, "<(") && !strings.HasPrefix(rest, ">(") {
+		for _, op := range operators {
+			if strings.HasPrefix(rest, op.text) {
+				for range op.text {
+					l.advance()
+				}
+				return token{kind: op.kind, pos: pos, text: op.text}, nil
+			}
+		}
+	}
+
+	word, err := l.word()
+	if err != nil {
+		return token{}, err
+	}
+	return token{kind: tokWord, pos: pos, text: l.src[pos.Offset:l.off], word: word}, nil
+}
+
+// ioNumberLen returns the length of a run of digits immediately followed
+// by a redirection operator, as in 2>file, or 0 if there is none.
+func (l *lexer) ioNumberLen() int {
+	n := 0
+	for {
+		c := l.peekAt(n)
+		if c < '0' || c > '9' {
+			break
+		}
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	if c := l.peekAt(n); c == '<' || c == '>' {
+		return n
+	}
+	return 0
+}
+
+// word scans a single word up to the next unquoted metacharacter.
+func (l *lexer) word() (*ast.Word, error) {
+	return l.wordUntil(isMeta)
+}
+
+// wordUntil scans a word up to the first unquoted rune for which stop
+// returns true.
+func (l *lexer) wordUntil(stop func(rune) bool) (*ast.Word, error) {
+	w := &ast.Word{}
+	var lit strings.Builder
+	litPos := l.pos()
+
+	flush := func() {
+		if lit.Len() > 0 {
+			w.Parts = append(w.Parts, &ast.Lit{ValuePos: litPos, Value: lit.String()})
+			lit.Reset()
+		}
+	}
+	write := func(r rune) {
+		if lit.Len() == 0 {
+			litPos = l.pos()
+		}
+		lit.WriteRune(r)
+	}
+
+	for !l.eof() {
+		r := l.peek()
+		if (r == '<' || r == '>') && l.peekAt(1) == '(' {
+			flush()
+			part, err := l.procSubst()
+			if err != nil {
+				return nil, err
+			}
+			w.Parts = append(w.Parts, part)
+			continue
+		}
+		if stop(r) {
+			break
+		}
+		switch r {
+		case '\\':
+			escPos := l.pos()
+			if l.peekAt(1) == '\n' {
+				l.advance()
+				l.advance()
+				continue
+			}
+			write(l.advance())
+			if l.eof() {
+				return nil, incomplete(escPos, "unexpected end of input after backslash")
+			}
+			lit.WriteRune(l.advance())
+		case '\'':
+			flush()
+			part, err := l.singleQuoted()
+			if err != nil {
+				return nil, err
+			}
+			w.Parts = append(w.Parts, part)
+		case '"':
+			flush()
+			part, err := l.doubleQuoted()
+			if err != nil {
+				return nil, err
+			}
+			w.Parts = append(w.Parts, part)
+		case '`':
+			flush()
+			part, err := l.backquoted(false)
+			if err != nil {
+				return nil, err
+			}
+			w.Parts = append(w.Parts, part)
+		case '$':
+			if l.peekAt(1) == '\'' {
+				flush()
+				part, err := l.ansiCQuoted()
+				if err != nil {
+					return nil, err
+				}
+				w.Parts = append(w.Parts, part)
+				continue
+			}
+			part, err := l.dollar()
+			if err != nil {
+				return nil, err
+			}
+			if part != nil {
+				flush()
+				w.Parts = append(w.Parts, part)
+				continue
+			}
+			write(l.advance())
+		default:
+			write(l.advance())
+		}
+	}
+	flush()
+	return w, nil
+}
+
+func (l *lexer) singleQuoted() (*ast.SglQuoted, error) {
+	left := l.pos()
+	l.advance()
+	start := l.off
+	for !l.eof() {
+		if l.peek() == '\'' {
+			value := l.src[start:l.off]
+			l.advance()
+			return &ast.SglQuoted{Left: left, Value: value}, nil
+		}
+		l.advance()
+	}
+	return nil, incomplete(left, "unterminated single quote")
+}
+
+// ansiCQuoted scans $'...', in which a backslash escapes the closing
+// quote. The escapes are decoded when the word is expanded.
+func (l *lexer) ansiCQuoted() (*ast.SglQuoted, error) {
+	left := l.pos()
+	l.advance()
+	l.advance()
+	start := l.off
+	for !l.eof() {
+		switch l.peek() {
+		case '\\':
+			l.advance()
+			if l.eof() {
+				continue
+			}
+		case '\'':
+			value := l.src[start:l.off]
+			l.advance()
+			return &ast.SglQuoted{Left: left, Dollar: true, Value: value}, nil
+		}
+		l.advance()
+	}
+	return nil, incomplete(left, "unterminated $' quote")
+}
+
+func (l *lexer) doubleQuoted() (*ast.DblQuoted, error) {
+	q := &ast.DblQuoted{Left: l.pos()}
+	l.advance()
+
+	var lit strings.Builder
+	litPos := l.pos()
+	flush := func() {
+		if lit.Len() > 0 {
+			q.Parts = append(q.Parts, &ast.Lit{ValuePos: litPos, Value: lit.String()})
+			lit.Reset()
+		}
+	}
+	write := func() {
+		if lit.Len() == 0 {
+			litPos = l.pos()
+		}
+		lit.WriteRune(l.advance())
+	}
+
+	for !l.eof() {
+		r := l.peek()
+		switch r {
+		case '"':
+			l.advance()
+			flush()
+			return q, nil
+		case '\\':
+			write()
+			if !l.eof() {
+				write()
+			}
+		case '`':
+			flush()
+			part, err := l.backquoted(true)
+			if err != nil {
+				return nil, err
+			}
+			q.Parts = append(q.Parts, part)
+		case '$':
+			part, err := l.dollar()
+			if err != nil {
+				return nil, err
+			}
+			if part != nil {
+				flush()
+				q.Parts = append(q.Parts, part)
+				continue
+			}
+			write()
+		default:
+			write()
+		}
+	}
+	return nil, incomplete(q.Left, "unterminated double quote")
+}
+
+// isDoubleEscape reports whether a backslash before c is an escape within
+// double quotes, rather than a literal backslash.
+func isDoubleEscape(c byte) bool {
+	switch c {
+	case '$', '`', '"', '\\', '\n':
+		return true
+	}
+	return false
+}
+
+// isSpecialParam reports whether c names a single-character special
+// parameter such as $? or $1.
+func isSpecialParam(c byte) bool {
+	switch c {
+	case '?', '#', '$', '!', '@', '*', '-':
+		return true
+	}
+	return c >= '0' && c <= '9'
+}
+
+func isNameByte(c byte, first bool) bool {
+	switch {
+	case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return true
+	case !first && c >= '0' && c <= '9':
+		return true
+	}
+	return false
+}
+
+// dollar scans an expansion starting at '$'. It returns nil, consuming
+// nothing, if the '$' does not start one and is therefore a literal
+// dollar sign.
+func (l *lexer) dollar() (ast.WordPart, error) {
+	pos := l.pos()
+	c := l.peekAt(1)
+	switch {
+	case c == '{':
+		return l.bracedParam()
+	case c == '(' && l.peekAt(2) == '(':
+		return l.arithExp()
+	case c == '(':
+		return l.cmdSubst()
+	case isSpecialParam(c):
+		l.advance()
+		l.advance()
+		return &ast.ParamExp{Dollar: pos, Short: true, Param: string(c)}, nil
+	case isNameByte(c, true):
+		l.advance()
+		return &ast.ParamExp{Dollar: pos, Short: true, Param: l.name()}, nil
+	}
+	return nil, nil
+}
+
+// name scans a variable name.
+func (l *lexer) name() string {
+	start := l.off
+	for isNameByte(l.peekAt(0), l.off == start) {
+		l.advance()
+	}
+	return l.src[start:l.off]
+}
+
+// paramOps is ordered so that longer operators are matched before their
+// prefixes.
+var paramOps = []struct {
+	text string
+	op   ast.ParamOp
+}{
+	{":-", ast.DefaultUnsetOrNull},
+	{":=", ast.AssignUnsetOrNull},
+	{":?", ast.ErrorUnsetOrNull},
+	{":+", ast.AlternateUnsetOrNull},
+	{"##", ast.RemLargePrefix},
+	{"%%", ast.RemLargeSuffix},
+	{"//", ast.ReplaceAll},
+	{"-", ast.DefaultUnset},
+	{"=", ast.AssignUnset},
+	{"?", ast.ErrorUnset},
+	{"+", ast.AlternateUnset},
+	{"#", ast.RemSmallPrefix},
+	{"%", ast.RemSmallSuffix},
+	{"/", ast.ReplaceFirst},
+	{":", ast.Substring},
+}
+
+// bracedParam scans a ${...} expansion.
+func (l *lexer) bracedParam() (*ast.ParamExp, error) {
+	p := &ast.ParamExp{Dollar: l.pos()}
+	l.advance()
+	l.advance()
+
+	// ${#} is the number of positional parameters, while ${#x} is the
+	// length of x.
+	if l.peek() == '#' && l.peekAt(1) != '}' {
+		p.Length = true
+		l.advance()
+	}
+
+	c := l.peekAt(0)
+	switch {
+	case isNameByte(c, true):
+		p.Param = l.name()
+	case c >= '0' && c <= '9':
+		start := l.off
+		for c := l.peekAt(0); c >= '0' && c <= '9'; c = l.peekAt(0) {
+			l.advance()
+		}
+		p.Param = l.src[start:l.off]
+	case isSpecialParam(c):
+		l.advance()
+		p.Param = string(c)
+	case c == 0:
+		return nil, l.errorf(p.Dollar, "unterminated parameter expansion")
+	default:
+		return nil, l.errorf(l.pos(), "bad substitution")
+	}
+
+	if l.peek() == '}' {
+		l.advance()
+		return p, nil
+	}
+	if p.Length {
+		return nil, l.errorf(l.pos(), "bad substitution")
+	}
+
+	rest := l.src[l.off:]
+	for _, op := range paramOps {
+		if strings.HasPrefix(rest, op.text) {
+			p.Op = op.op
+			for range op.text {
+				l.advance()
+			}
+			break
+		}
+	}
+	if p.Op == ast.ParamNone {
+		if l.eof() {
+			return nil, l.errorf(p.Dollar, "unterminated parameter expansion")
+		}
+		return nil, l.errorf(l.pos(), "bad substitution")
+	}
+
+	var err error
+	switch p.Op {
+	case ast.ReplaceFirst, ast.ReplaceAll:
+		if p.Word, err = l.wordUntil(isByte('}', '/')); err != nil {
+			return nil, err
+		}
+		if l.peek() == '/' {
+			l.advance()
+			if p.Repl, err = l.wordUntil(isByte('}')); err != nil {
+				return nil, err
+			}
+		}
+	case ast.Substring:
+		if p.Word, err = l.wordUntil(isByte('}', ':')); err != nil {
+			return nil, err
+		}
+		if l.peek() == ':' {
+			l.advance()
+			if p.Repl, err = l.wordUntil(isByte('}')); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		if p.Word, err = l.wordUntil(isByte('}')); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.peek() != '}' {
+		return nil, l.errorf(p.Dollar, "unterminated parameter expansion")
+	}
+	l.advance()
+	return p, nil
+}
+
+// isByte returns a stop function for wordUntil that matches any of the
+// given characters.
+func isByte(chars ...rune) func(rune) bool {
+	return func(r rune) bool {
+		for _, c := range chars {
+			if r == c {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// cmdSubst scans a $(...) command substitution.
+func (l *lexer) cmdSubst() (*ast.CmdSubst, error) {
+	c := &ast.CmdSubst{Left: l.pos()}
+	l.advance()
+	l.advance()
+	var err error
+	c.Stmts, c.Right, err = l.substStmts(c.Left)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// procSubst scans a <(...) or >(...) process substitution.
+func (l *lexer) procSubst() (*ast.ProcSubst, error) {
+	p := &ast.ProcSubst{OpPos: l.pos(), Out: l.peek() == '>'}
+	l.advance()
+	l.advance()
+	var err error
+	p.Stmts, p.Rparen, err = l.substStmts(p.OpPos)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// arithExp scans a $((...)) arithmetic expansion. Parameters and
+// commands in the expression are expanded as within double quotes; the
+// parentheses inside it must balance.
+func (l *lexer) arithExp() (*ast.ArithExp, error) {
+	a := &ast.ArithExp{Left: l.pos(), X: &ast.Word{}}
+	l.advance()
+	l.advance()
+	l.advance()
+
+	var lit strings.Builder
+	litPos := l.pos()
+	flush := func() {
+		if lit.Len() > 0 {
+			a.X.Parts = append(a.X.Parts, &ast.Lit{ValuePos: litPos, Value: lit.String()})
+			lit.Reset()
+		}
+	}
+	write := func() {
+		if lit.Len() == 0 {
+			litPos = l.pos()
+		}
+		lit.WriteRune(l.advance())
+	}
+
+	depth := 0
+	for !l.eof() {
+		var part ast.WordPart
+		var err error
+		switch r := l.peek(); r {
+		case ')':
+			if depth == 0 && l.peekAt(1) == ')' {
+				flush()
+				a.Right = l.pos()
+				l.advance()
+				l.advance()
+				return a, nil
+			}
+			depth--
+			write()
+			continue
+		case '(':
+			depth++
+			write()
+			continue
+		case '\\':
+			write()
+			if !l.eof() {
+				write()
+			}
+			continue
+		case '"':
+			part, err = l.doubleQuoted()
+		case '`':
+			part, err = l.backquoted(true)
+		case '$':
+			part, err = l.dollar()
+			if err == nil && part == nil {
+				write()
+				continue
+			}
+		default:
+			write()
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		flush()
+		a.X.Parts = append(a.X.Parts, part)
+	}
+	return nil, incomplete(a.Left, "unterminated arithmetic expansion")
+}
+
+// backquoted scans a `...` command substitution. Within it, a backslash
+// only escapes $, ` and another backslash, and also " if the substitution
+// is itself inside double quotes; other backslashes are kept for the
+// commands inside.
+func (l *lexer) backquoted(inDouble bool) (*ast.CmdSubst, error) {
+	c := &ast.CmdSubst{Left: l.pos()}
+	l.advance()
+	bodyPos := l.pos()
+
+	var body strings.Builder
+	for {
+		if l.eof() {
+			return nil, incomplete(c.Left, "unterminated backquote")
+		}
+		if l.peek() == '`' {
+			c.Right = l.pos()
+			l.advance()
+			break
+		}
+		r := l.advance()
+		if next := l.peek(); r == '\\' && (next == '$' || next == '`' || next == '\\' || next == '"' && inDouble) {
+			r = l.advance()
+		}
+		body.WriteRune(r)
+	}
+
+	var err error
+	c.Stmts, err = l.parseNested(body.String(), bodyPos)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// heredocBodies reads the bodies of the pending here-documents from the
+// lines after a newline.
+func (l *lexer) heredocBodies() error {
+	for _, r := range l.heredocs {
+		if err := l.heredocBody(r); err != nil {
+			return err
+		}
+	}
+	l.heredocs = nil
+	return nil
+}
+
+// heredocBody reads the lines of a here-document up to its delimiter into
+// r.Hdoc. With <<-, leading tabs are removed from each line first.
+func (l *lexer) heredocBody(r *ast.Redirect) error {
+	delim, quoted := heredocDelim(r.Word)
+	start := l.pos()
+	var body strings.Builder
+	for {
+		if l.eof() {
+			return incomplete(r.OpPos, "here-document ended before `%s'", delim)
+		}
+		lineStart := l.off
+		for !l.eof() && l.peek() != '\n' {
+			l.advance()
+		}
+		line := l.src[lineStart:l.off]
+		if !l.eof() {
+			l.advance()
+		}
+		if r.Op == ast.DashHeredoc {
+			line = strings.TrimLeft(line, "\t")
+		}
+		if line == delim {
+			break
+		}
+		body.WriteString(line + "\n")
+	}
+
+	// With any part of the delimiter quoted, the body is literal.
+	if quoted {
+		r.Hdoc = &ast.Word{Parts: []ast.WordPart{&ast.SglQuoted{Left: start, Value: body.String()}}}
+		return nil
+	}
+	lex := newLexerAt(body.String(), start)
+	lex.aliases = l.aliases
+	var err error
+	r.Hdoc, err = lex.heredocWord()
+	return err
+}
+
+// heredocDelim returns the delimiter a here-document ends with, which is
+// its word with quotes removed, and whether any of it was quoted.
+func heredocDelim(w *ast.Word) (string, bool) {
+	var sb strings.Builder
+	quoted := false
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *ast.Lit:
+			quoted = quoted || strings.Contains(p.Value, `\`)
+			sb.WriteString(unescapeLit(p.Value, false))
+		case *ast.SglQuoted:
+			quoted = true
+			sb.WriteString(p.Value)
+		case *ast.DblQuoted:
+			quoted = true
+			for _, inner := range p.Parts {
+				if lit, ok := inner.(*ast.Lit); ok {
+					sb.WriteString(unescapeLit(lit.Value, true))
+				} else {
+					sb.WriteString(ast.String(inner))
+				}
+			}
+		default:
+			sb.WriteString(ast.String(part))
+		}
+	}
+	return sb.String(), quoted
+}
+
+// unescapeLit removes the backslashes from unquoted text or, with
+// inDouble set, those that escape a character within double quotes.
+func unescapeLit(s string, inDouble bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (!inDouble || isDoubleEscape(s[i+1])) {
+			i++
+			if s[i] == '\n' {
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// heredocWord scans the body of a here-document with an unquoted
+// delimiter. Parameters and commands are expanded as within double
+// quotes, and a backslash escapes only $, ` and another backslash, or
+// joins lines; quotes have no special meaning.
+func (l *lexer) heredocWord() (*ast.Word, error) {
+	q := &ast.DblQuoted{Left: l.pos()}
+	var lit strings.Builder
+	litPos := l.pos()
+	flush := func() {
+		if lit.Len() > 0 {
+			q.Parts = append(q.Parts, &ast.SglQuoted{Left: litPos, Value: lit.String()})
+			lit.Reset()
+		}
+	}
+	write := func() {
+		if lit.Len() == 0 {
+			litPos = l.pos()
+		}
+		lit.WriteRune(l.advance())
+	}
+
+	for !l.eof() {
+		switch r, next := l.peek(), l.peekAt(1); {
+		case r == '\\' && next == '\n':
+			l.advance()
+			l.advance()
+		case r == '\\' && (next == '$' || next == '`' || next == '\\'):
+			l.advance()
+			write()
+		case r == '`':
+			flush()
+			part, err := l.backquoted(false)
+			if err != nil {
+				return nil, err
+			}
+			q.Parts = append(q.Parts, part)
+		case r == '$':
+			part, err := l.dollar()
+			if err != nil {
+				return nil, err
+			}
+			if part == nil {
+				write()
+				continue
+			}
+			flush()
+			q.Parts = append(q.Parts, part)
+		default:
+			write()
+		}
+	}
+	flush()
+	return &ast.Word{Parts: []ast.WordPart{q}}, nil
+}
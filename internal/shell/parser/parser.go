@@ -3,96 +3,948 @@ package parser
 
 import (
 	"errors"
+	"fmt"
+	"slices"
 	"strings"
 
-	"github.com/krzko/gosh/internal/shell/executor"
+	"github.com/krzko/gosh/internal/shell/ast"
 )
 
-type Parser struct{}
+// Error is a syntax error at a specific position in the input.
+type Error struct {
+	Pos ast.Pos
+	Msg string
+	// Incomplete is set when the input ended inside a construct that
+	// more input could complete, such as a quote or here-document.
+	Incomplete bool
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+func newError(pos ast.Pos, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// IsIncomplete reports whether err is a syntax error caused by the input
+// ending too early, so that an interactive shell can read more lines
+// before trying again.
+func IsIncomplete(err error) bool {
+	var parseErr *Error
+	return errors.As(err, &parseErr) && parseErr.Incomplete
+}
+
+type Parser struct {
+	aliases map[string]string
+}
 
 func New() *Parser {
 	return &Parser{}
 }
 
-func (p *Parser) Parse(input string) (*executor.Command, error) {
-	if input == "" {
-		return nil, errors.New("empty input")
-	}
+// SetAliases makes the parser expand aliases, looking them up in aliases
+// whenever it parses. The map may be changed between calls to Parse.
+func (p *Parser) SetAliases(aliases map[string]string) {
+	p.aliases = aliases
+}
 
+// Parse parses input into a syntax tree. Blank input yields a File with no
+// statements.
+func (p *Parser) Parse(input string) (*ast.File, error) {
 	// Handle ".." as "cd .."
 	if input == ".." {
-		return &executor.Command{
-			Name: "cd",
-			Args: []string{".."},
-		}, nil
+		pos := ast.Pos{Line: 1, Col: 1}
+		return &ast.File{Stmts: []*ast.Stmt{{
+			Position: pos,
+			Cmd: &ast.CallExpr{Args: []*ast.Word{
+				{Parts: []ast.WordPart{&ast.Lit{ValuePos: pos, Value: "cd"}}},
+				{Parts: []ast.WordPart{&ast.Lit{ValuePos: pos, Value: ".."}}},
+			}},
+		}}}, nil
 	}
 
-	// Split the input into pipeline segments
-	pipeSegments := strings.Split(input, "|")
-	var currentCommand *executor.Command
-	var firstCommand *executor.Command
+	ps, err := p.start(input)
+	if err != nil {
+		return nil, err
+	}
+	return ps.file()
+}
 
-	for i, segment := range pipeSegments {
-		// Trim spaces and split into command and args
-		parts := splitCommand(strings.TrimSpace(segment))
-		if len(parts) == 0 {
-			continue
+// ParseEach parses input one complete command at a time, as a script is
+// read, calling fn with each before parsing the next, so that aliases
+// defined by a command apply to the lines after it. A complete command is
+// the statements on a line, with the lines that a compound command or
+// here-document spans. ParseEach stops at the first syntax error, which
+// it returns, or when fn returns false.
+func (p *Parser) ParseEach(input string, fn func(*ast.File) bool) error {
+	ps, err := p.start(input)
+	if err != nil {
+		return err
+	}
+	for {
+		if err := ps.skipNewlines(); err != nil {
+			return err
+		}
+		if ps.tok.kind == tokEOF {
+			return nil
+		}
+		stmts, err := ps.line()
+		if err != nil {
+			return err
+		}
+		if !fn(&ast.File{Stmts: stmts}) {
+			return nil
+		}
+	}
+}
+
+// start begins parsing input, reading its first token.
+func (p *Parser) start(input string) (*parseState, error) {
+	lex := newLexer(input)
+	lex.aliases = p.aliases
+	ps := &parseState{lex: lex, aliases: p.aliases}
+	if err := ps.advance(); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// parseState holds the state of a single Parse call.
+type parseState struct {
+	lex     *lexer
+	tok     token
+	aliases map[string]string
+	// pending holds the tokens of alias expansions still to be parsed,
+	// which come before the rest of the input.
+	pending []token
+	// blankAlias is set when an alias whose value ends in a blank has
+	// just been expanded, so that the next token read from the input is
+	// checked for an alias too.
+	blankAlias bool
+}
+
+func (ps *parseState) advance() error {
+	tok, err := ps.next()
+	if err != nil {
+		return err
+	}
+	ps.tok = tok
+	return nil
+}
+
+// next returns the next token, from a pending alias expansion if there is
+// one and otherwise from the input.
+func (ps *parseState) next() (token, error) {
+	if len(ps.pending) > 0 {
+		tok := ps.pending[0]
+		ps.pending = ps.pending[1:]
+		return tok, nil
+	}
+	tok, err := ps.lex.next()
+	if err != nil {
+		return token{}, err
+	}
+	tok.checkAlias = ps.blankAlias
+	ps.blankAlias = false
+	return tok, nil
+}
+
+// peek returns the token after the current one without consuming it.
+func (ps *parseState) peek() (token, error) {
+	tok, err := ps.next()
+	if err != nil {
+		return token{}, err
+	}
+	ps.pending = append([]token{tok}, ps.pending...)
+	return tok, nil
+}
+
+// closingWords are the reserved words that end a list inside a compound
+// command.
+var closingWords = []string{"}", "then", "elif", "else", "fi", "do", "done", "esac"}
+
+func (ps *parseState) atClosing() bool {
+	for _, word := range closingWords {
+		if ps.atReserved(word) {
+			return true
 		}
+	}
+	return false
+}
+
+// atReserved reports whether the current token is the reserved word
+// word. Reserved words are only recognised unquoted, where a command
+// could start.
+func (ps *parseState) atReserved(word string) bool {
+	return ps.tok.kind == tokWord && ps.tok.text == word && ps.tok.word.Lit() == word
+}
 
-		command := &executor.Command{
-			Name: parts[0],
-			Args: parts[1:],
+// expandAlias replaces the current token by the tokens of its alias, if
+// it is an unquoted word naming one. An alias is not expanded again
+// within its own expansion, so that alias ls='ls -F' refers to the
+// command ls. It reports whether the token was replaced.
+func (ps *parseState) expandAlias() (bool, error) {
+	if ps.tok.kind != tokWord || ps.aliases == nil {
+		return false, nil
+	}
+	name := ps.tok.word.Lit()
+	value, ok := ps.aliases[name]
+	if !ok || name != ps.tok.text || slices.Contains(ps.tok.aliases, name) {
+		return false, nil
+	}
+
+	expanding := append(ps.tok.aliases[:len(ps.tok.aliases):len(ps.tok.aliases)], name)
+	lex := newLexerAt(value, ps.tok.pos)
+	lex.aliases = ps.aliases
+	var toks []token
+	for {
+		tok, err := lex.next()
+		if err != nil {
+			return false, err
+		}
+		if tok.kind == tokEOF {
+			break
 		}
+		tok.aliases = expanding
+		toks = append(toks, tok)
+	}
 
-		if i == 0 {
-			firstCommand = command
-			currentCommand = command
+	// As in other shells, the word after an alias ending in a blank is
+	// checked for an alias as well, as in alias sudo='sudo '.
+	if strings.HasSuffix(value, " ") || strings.HasSuffix(value, "\t") {
+		if len(ps.pending) > 0 {
+			ps.pending[0].checkAlias = true
 		} else {
-			currentCommand.Pipe = command
-			currentCommand = command
+			ps.blankAlias = true
 		}
 	}
+	ps.pending = append(toks, ps.pending...)
+	return true, ps.advance()
+}
 
-	return firstCommand, nil
+// expandAliases expands the current token until it no longer names an
+// alias.
+func (ps *parseState) expandAliases() error {
+	for {
+		expanded, err := ps.expandAlias()
+		if err != nil || !expanded {
+			return err
+		}
+	}
 }
 
-// splitCommand splits a command string into command and arguments,
-// handling quoted strings and escaping
-func splitCommand(input string) []string {
-	var parts []string
-	var current strings.Builder
-	inQuotes := false
-	escapeNext := false
+// unexpected returns an error for the current token. Reaching the end of
+// the input where more was expected means the input is incomplete, as
+// after a trailing pipe or inside an unclosed compound command.
+func (ps *parseState) unexpected() error {
+	if ps.tok.kind == tokEOF {
+		return incomplete(ps.tok.pos, "syntax error: unexpected end of input")
+	}
+	return newError(ps.tok.pos, "syntax error near unexpected token %s", ps.tok)
+}
 
-	for _, char := range input {
-		if escapeNext {
-			current.WriteRune(char)
-			escapeNext = false
-			continue
+func (ps *parseState) skipNewlines() error {
+	for ps.tok.kind == tokNewline {
+		if err := ps.advance(); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		switch char {
-		case '\\':
-			escapeNext = true
-		case '"':
-			inQuotes = !inQuotes
-		case ' ':
-			if !inQuotes {
-				if current.Len() > 0 {
-					parts = append(parts, current.String())
-					current.Reset()
-				}
-			} else {
-				current.WriteRune(char)
+func (ps *parseState) file() (*ast.File, error) {
+	stmts, err := ps.stmtList()
+	if err != nil {
+		return nil, err
+	}
+	if ps.tok.kind != tokEOF {
+		return nil, ps.unexpected()
+	}
+	return &ast.File{Stmts: stmts}, nil
+}
+
+// stmtList parses statements separated by ';', '&' or newlines, up to the
+// end of the input, a reserved word that closes a compound command, the
+// ;; ending a case item or the ) ending a substitution.
+func (ps *parseState) stmtList() ([]*ast.Stmt, error) {
+	var stmts []*ast.Stmt
+	if err := ps.skipNewlines(); err != nil {
+		return nil, err
+	}
+	for ps.tok.kind != tokEOF && ps.tok.kind != tokDSemi && ps.tok.kind != tokRParen && !ps.atClosing() {
+		stmt, err := ps.andOr()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+
+		switch ps.tok.kind {
+		case tokAmp:
+			stmt.Background = true
+			if err := ps.advance(); err != nil {
+				return nil, err
+			}
+		case tokSemi:
+			if err := ps.advance(); err != nil {
+				return nil, err
 			}
+		case tokNewline, tokEOF, tokDSemi, tokRParen:
 		default:
-			current.WriteRune(char)
+			return nil, ps.unexpected()
+		}
+		if err := ps.skipNewlines(); err != nil {
+			return nil, err
 		}
 	}
+	return stmts, nil
+}
 
-	if current.Len() > 0 {
-		parts = append(parts, current.String())
+// line parses the statements of a complete command, up to the newline or
+// end of input that ends it. The newline is left as the current token,
+// so that nothing after it is read until the command has run.
+func (ps *parseState) line() ([]*ast.Stmt, error) {
+	var stmts []*ast.Stmt
+	for ps.tok.kind != tokNewline && ps.tok.kind != tokEOF {
+		if ps.tok.kind == tokDSemi || ps.tok.kind == tokRParen || ps.atClosing() {
+			return nil, ps.unexpected()
+		}
+		stmt, err := ps.andOr()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+
+		switch ps.tok.kind {
+		case tokAmp:
+			stmt.Background = true
+			if err := ps.advance(); err != nil {
+				return nil, err
+			}
+		case tokSemi:
+			if err := ps.advance(); err != nil {
+				return nil, err
+			}
+		case tokNewline, tokEOF:
+		default:
+			return nil, ps.unexpected()
+		}
+	}
+	return stmts, nil
+}
+
+// andOr parses pipelines joined by && and ||. A newline may follow either
+// operator.
+func (ps *parseState) andOr() (*ast.Stmt, error) {
+	left, err := ps.pipeline()
+	if err != nil {
+		return nil, err
+	}
+	for ps.tok.kind == tokAndIf || ps.tok.kind == tokOrIf {
+		bin := &ast.BinaryCmd{OpPos: ps.tok.pos, Op: ast.AndStmt, X: left}
+		if ps.tok.kind == tokOrIf {
+			bin.Op = ast.OrStmt
+		}
+		if err := ps.advance(); err != nil {
+			return nil, err
+		}
+		if err := ps.skipNewlines(); err != nil {
+			return nil, err
+		}
+		if bin.Y, err = ps.pipeline(); err != nil {
+			return nil, err
+		}
+		left = &ast.Stmt{Position: left.Position, Cmd: bin}
+	}
+	return left, nil
+}
+
+// pipeline parses one or more commands separated by '|', optionally
+// preceded by '!'. A pipeline of a single command without '!' is returned
+// as that command's statement.
+func (ps *parseState) pipeline() (*ast.Stmt, error) {
+	pos := ps.tok.pos
+	negated := false
+	if ps.tok.kind == tokWord && ps.tok.word.Lit() == "!" {
+		negated = true
+		if err := ps.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	first, err := ps.command()
+	if err != nil {
+		return nil, err
+	}
+	if ps.tok.kind != tokPipe && !negated {
+		return first, nil
+	}
+
+	pipe := &ast.Pipeline{Position: pos, Negated: negated, Stmts: []*ast.Stmt{first}}
+	for ps.tok.kind == tokPipe {
+		if err := ps.advance(); err != nil {
+			return nil, err
+		}
+		if err := ps.skipNewlines(); err != nil {
+			return nil, err
+		}
+		stmt, err := ps.command()
+		if err != nil {
+			return nil, err
+		}
+		pipe.Stmts = append(pipe.Stmts, stmt)
+	}
+	return &ast.Stmt{Position: pos, Cmd: pipe}, nil
+}
+
+func (ps *parseState) command() (*ast.Stmt, error) {
+	if err := ps.expandAliases(); err != nil {
+		return nil, err
+	}
+	if ps.tok.kind != tokWord && !ps.atRedirect() {
+		return nil, ps.unexpected()
+	}
+	stmt := &ast.Stmt{Position: ps.tok.pos}
+
+	switch {
+	case ps.atCompound():
+		return ps.compound(stmt)
+	case ps.atReserved("function"):
+		return ps.funcDecl(stmt, true)
+	case ps.tok.kind == tokWord && ps.tok.word.Lit() != "":
+		next, err := ps.peek()
+		if err != nil {
+			return nil, err
+		}
+		if next.kind == tokLParen {
+			return ps.funcDecl(stmt, false)
+		}
+	}
+
+	call, err := ps.simpleCommand(stmt)
+	if err != nil {
+		return nil, err
+	}
+	stmt.Cmd = call
+	return stmt, nil
+}
+
+// compoundWords are the reserved words that start a compound command.
+var compoundWords = []string{"{", "if", "while", "until", "for", "case"}
+
+func (ps *parseState) atCompound() bool {
+	for _, word := range compoundWords {
+		if ps.atReserved(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// compound parses a compound command and any redirections after it into
+// stmt.
+func (ps *parseState) compound(stmt *ast.Stmt) (*ast.Stmt, error) {
+	var err error
+	switch {
+	case ps.atReserved("{"):
+		stmt.Cmd, err = ps.block()
+	case ps.atReserved("if"):
+		stmt.Cmd, err = ps.ifClause()
+	case ps.atReserved("while"), ps.atReserved("until"):
+		stmt.Cmd, err = ps.whileClause()
+	case ps.atReserved("for"):
+		stmt.Cmd, err = ps.forClause()
+	case ps.atReserved("case"):
+		stmt.Cmd, err = ps.caseClause()
+	default:
+		return nil, ps.unexpected()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for ps.atRedirect() {
+		redir, err := ps.redirect()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Redirs = append(stmt.Redirs, redir)
+	}
+	return stmt, nil
+}
+
+// expect consumes the reserved word word, or fails if the current token
+// is anything else.
+func (ps *parseState) expect(word string) error {
+	if !ps.atReserved(word) {
+		return ps.unexpected()
+	}
+	return ps.advance()
+}
+
+// list parses the non-empty list of statements that follows a reserved
+// word in a compound command.
+func (ps *parseState) list() ([]*ast.Stmt, error) {
+	stmts, err := ps.stmtList()
+	if err != nil {
+		return nil, err
+	}
+	if len(stmts) == 0 {
+		return nil, ps.unexpected()
+	}
+	return stmts, nil
+}
+
+// ifClause parses if list; then list; [elif list; then list;]... [else
+// list;] fi.
+func (ps *parseState) ifClause() (*ast.IfClause, error) {
+	clause := &ast.IfClause{Position: ps.tok.pos}
+	if err := ps.advance(); err != nil {
+		return nil, err
+	}
+	if err := ps.ifBody(clause); err != nil {
+		return nil, err
+	}
+	return clause, ps.expect("fi")
+}
+
+// ifBody parses an if or elif from its condition up to, but not
+// including, the closing fi.
+func (ps *parseState) ifBody(clause *ast.IfClause) error {
+	var err error
+	if clause.Cond, err = ps.list(); err != nil {
+		return err
+	}
+	if err := ps.expect("then"); err != nil {
+		return err
+	}
+	if clause.Then, err = ps.list(); err != nil {
+		return err
 	}
 
-	return parts
+	switch {
+	case ps.atReserved("elif"):
+		clause.Else = &ast.IfClause{Position: ps.tok.pos}
+		if err := ps.advance(); err != nil {
+			return err
+		}
+		return ps.ifBody(clause.Else)
+	case ps.atReserved("else"):
+		clause.Else = &ast.IfClause{Position: ps.tok.pos}
+		if err := ps.advance(); err != nil {
+			return err
+		}
+		clause.Else.Then, err = ps.list()
+		return err
+	}
+	return nil
+}
+
+// whileClause parses while list; do list; done, or the same with until.
+func (ps *parseState) whileClause() (*ast.WhileClause, error) {
+	clause := &ast.WhileClause{Position: ps.tok.pos, Until: ps.atReserved("until")}
+	if err := ps.advance(); err != nil {
+		return nil, err
+	}
+	var err error
+	if clause.Cond, err = ps.list(); err != nil {
+		return nil, err
+	}
+	if clause.Do, err = ps.doGroup(); err != nil {
+		return nil, err
+	}
+	return clause, nil
+}
+
+// doGroup parses do list; done.
+func (ps *parseState) doGroup() ([]*ast.Stmt, error) {
+	if err := ps.expect("do"); err != nil {
+		return nil, err
+	}
+	stmts, err := ps.list()
+	if err != nil {
+		return nil, err
+	}
+	return stmts, ps.expect("done")
+}
+
+// forClause parses for name [in word...]; do list; done.
+func (ps *parseState) forClause() (*ast.ForClause, error) {
+	clause := &ast.ForClause{Position: ps.tok.pos}
+	if err := ps.advance(); err != nil {
+		return nil, err
+	}
+	if ps.tok.kind != tokWord {
+		return nil, ps.unexpected()
+	}
+	name := ps.tok.word.Lit()
+	if !IsName(name) {
+		return nil, newError(ps.tok.pos, "`%s': not a valid identifier", ps.tok.text)
+	}
+	clause.Name = &ast.Lit{ValuePos: ps.tok.pos, Value: name}
+	if err := ps.advance(); err != nil {
+		return nil, err
+	}
+
+	if err := ps.skipNewlines(); err != nil {
+		return nil, err
+	}
+	if ps.atReserved("in") {
+		clause.InSet = true
+		if err := ps.advance(); err != nil {
+			return nil, err
+		}
+		for ps.tok.kind == tokWord {
+			clause.Items = append(clause.Items, ps.tok.word)
+			if err := ps.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if ps.tok.kind != tokSemi && ps.tok.kind != tokNewline {
+			return nil, ps.unexpected()
+		}
+		if err := ps.advance(); err != nil {
+			return nil, err
+		}
+	} else if ps.tok.kind == tokSemi {
+		if err := ps.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if err := ps.skipNewlines(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if clause.Do, err = ps.doGroup(); err != nil {
+		return nil, err
+	}
+	return clause, nil
+}
+
+// caseClause parses case word in [(]pattern[|pattern]...) list;; ...
+// esac. The ;; may be left out after the last item.
+func (ps *parseState) caseClause() (*ast.CaseClause, error) {
+	clause := &ast.CaseClause{Position: ps.tok.pos}
+	if err := ps.advance(); err != nil {
+		return nil, err
+	}
+	if ps.tok.kind != tokWord {
+		return nil, ps.unexpected()
+	}
+	clause.Word = ps.tok.word
+	if err := ps.advance(); err != nil {
+		return nil, err
+	}
+	if err := ps.skipNewlines(); err != nil {
+		return nil, err
+	}
+	if err := ps.expect("in"); err != nil {
+		return nil, err
+	}
+	if err := ps.skipNewlines(); err != nil {
+		return nil, err
+	}
+
+	for !ps.atReserved("esac") {
+		item := &ast.CaseItem{}
+		if ps.tok.kind == tokLParen {
+			if err := ps.advance(); err != nil {
+				return nil, err
+			}
+		}
+		for {
+			if ps.tok.kind != tokWord {
+				return nil, ps.unexpected()
+			}
+			item.Patterns = append(item.Patterns, ps.tok.word)
+			if err := ps.advance(); err != nil {
+				return nil, err
+			}
+			if ps.tok.kind != tokPipe {
+				break
+			}
+			if err := ps.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if ps.tok.kind != tokRParen {
+			return nil, ps.unexpected()
+		}
+		if err := ps.advance(); err != nil {
+			return nil, err
+		}
+
+		var err error
+		if item.Stmts, err = ps.stmtList(); err != nil {
+			return nil, err
+		}
+		clause.Items = append(clause.Items, item)
+
+		if ps.tok.kind != tokDSemi {
+			break
+		}
+		if err := ps.advance(); err != nil {
+			return nil, err
+		}
+		if err := ps.skipNewlines(); err != nil {
+			return nil, err
+		}
+	}
+	return clause, ps.expect("esac")
+}
+
+// block parses { list; }.
+func (ps *parseState) block() (*ast.Block, error) {
+	b := &ast.Block{Lbrace: ps.tok.pos}
+	if err := ps.advance(); err != nil {
+		return nil, err
+	}
+	stmts, err := ps.stmtList()
+	if err != nil {
+		return nil, err
+	}
+	if !ps.atReserved("}") || len(stmts) == 0 {
+		return nil, ps.unexpected()
+	}
+	b.Stmts = stmts
+	b.Rbrace = ps.tok.pos
+	if err := ps.advance(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// funcDecl parses a function definition, name() body, or with keyword
+// set, function name [()] body. The body is a compound command.
+func (ps *parseState) funcDecl(stmt *ast.Stmt, keyword bool) (*ast.Stmt, error) {
+	if keyword {
+		if err := ps.advance(); err != nil {
+			return nil, err
+		}
+		if ps.tok.kind != tokWord {
+			return nil, ps.unexpected()
+		}
+	}
+	name := ps.tok.word.Lit()
+	if !isFuncName(name) {
+		return nil, newError(ps.tok.pos, "`%s': not a valid function name", ps.tok.text)
+	}
+	decl := &ast.FuncDecl{Position: stmt.Position, Name: &ast.Lit{ValuePos: ps.tok.pos, Value: name}}
+	if err := ps.advance(); err != nil {
+		return nil, err
+	}
+
+	if ps.tok.kind == tokLParen {
+		if err := ps.advance(); err != nil {
+			return nil, err
+		}
+		if ps.tok.kind != tokRParen {
+			return nil, ps.unexpected()
+		}
+		if err := ps.advance(); err != nil {
+			return nil, err
+		}
+	} else if !keyword {
+		return nil, ps.unexpected()
+	}
+	if err := ps.skipNewlines(); err != nil {
+		return nil, err
+	}
+
+	body, err := ps.compound(&ast.Stmt{Position: ps.tok.pos})
+	if err != nil {
+		return nil, err
+	}
+	decl.Body = body
+	stmt.Cmd = decl
+	return stmt, nil
+}
+
+// isFuncName reports whether name can name a function. Other shells allow
+// more than variable names, such as mk-cd or git.branch, so only words
+// that could not be parsed back as a function call are refused.
+func isFuncName(name string) bool {
+	switch name {
+	case "", "{", "}", "function", "!":
+		return false
+	}
+	return !strings.Contains(name, "=")
+}
+
+// simpleCommand parses assignments, words and redirections. Redirections
+// may appear anywhere in the command and are attached to stmt.
+func (ps *parseState) simpleCommand(stmt *ast.Stmt) (*ast.CallExpr, error) {
+	call := &ast.CallExpr{}
+	for ps.tok.kind == tokWord || ps.atRedirect() {
+		if ps.atRedirect() {
+			redir, err := ps.redirect()
+			if err != nil {
+				return nil, err
+			}
+			stmt.Redirs = append(stmt.Redirs, redir)
+			continue
+		}
+		if len(call.Args) == 0 {
+			if as := assignment(ps.tok.word); as != nil {
+				call.Assigns = append(call.Assigns, as)
+				if err := ps.advance(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		// The command name may be an alias even after assignments, and
+		// so may the word after an alias ending in a blank.
+		if (len(call.Args) == 0 && len(call.Assigns) > 0) || ps.tok.checkAlias {
+			expanded, err := ps.expandAlias()
+			if err != nil {
+				return nil, err
+			}
+			if expanded {
+				continue
+			}
+		}
+		call.Args = append(call.Args, ps.tok.word)
+		if err := ps.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return call, nil
+}
+
+var redirOps = map[tokenKind]ast.RedirOp{
+	tokGreat:     ast.RedirOut,
+	tokDGreat:    ast.AppendOut,
+	tokLess:      ast.RedirIn,
+	tokLessGreat: ast.RedirInOut,
+	tokLessAnd:   ast.DupIn,
+	tokGreatAnd:  ast.DupOut,
+	tokClobber:   ast.ClobberOut,
+	tokAndGreat:  ast.RedirAll,
+	tokAndDGreat: ast.AppendAll,
+	tokDLess:     ast.Heredoc,
+	tokDLessDash: ast.DashHeredoc,
+	tokTLess:     ast.HereString,
+}
+
+func (ps *parseState) atRedirect() bool {
+	if ps.tok.kind == tokIONumber {
+		return true
+	}
+	_, ok := redirOps[ps.tok.kind]
+	return ok
+}
+
+func (ps *parseState) redirect() (*ast.Redirect, error) {
+	redir := &ast.Redirect{}
+	if ps.tok.kind == tokIONumber {
+		redir.N = &ast.Lit{ValuePos: ps.tok.pos, Value: ps.tok.text}
+		if err := ps.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	op, ok := redirOps[ps.tok.kind]
+	if !ok {
+		return nil, ps.unexpected()
+	}
+	redir.OpPos = ps.tok.pos
+	redir.Op = op
+	if err := ps.advance(); err != nil {
+		return nil, err
+	}
+
+	if ps.tok.kind == tokEOF {
+		// More input would not help a redirection that has no target
+		// on its line.
+		return nil, newError(ps.tok.pos, "syntax error near unexpected token `newline'")
+	}
+	if ps.tok.kind != tokWord {
+		return nil, ps.unexpected()
+	}
+	redir.Word = ps.tok.word
+	// The body of a here-document follows the next newline, which the
+	// lexer may reach as soon as the parser advances.
+	if op == ast.Heredoc || op == ast.DashHeredoc {
+		ps.lex.heredocs = append(ps.lex.heredocs, redir)
+	}
+	if err := ps.advance(); err != nil {
+		return nil, err
+	}
+	return redir, nil
+}
+
+// assignment returns the assignment a word represents, or nil if the word
+// does not start with an unquoted NAME=.
+func assignment(w *ast.Word) *ast.Assign {
+	lit, ok := w.Parts[0].(*ast.Lit)
+	if !ok {
+		return nil
+	}
+	eq := strings.IndexByte(lit.Value, '=')
+	if eq <= 0 || !IsName(lit.Value[:eq]) {
+		return nil
+	}
+
+	value := &ast.Word{}
+	if rest := lit.Value[eq+1:]; rest != "" {
+		valuePos := lit.ValuePos
+		valuePos.Offset += eq + 1
+		valuePos.Col += eq + 1
+		value.Parts = append(value.Parts, &ast.Lit{ValuePos: valuePos, Value: rest})
+	}
+	value.Parts = append(value.Parts, w.Parts[1:]...)
+
+	return &ast.Assign{NamePos: lit.ValuePos, Name: lit.Value[:eq], Value: value}
+}
+
+// IsName reports whether s is a valid shell variable name.
+func IsName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isNameByte(s[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// substStmts parses the commands of a command or process substitution
+// from l, which is just past the opening parenthesis at open, up to and
+// including the closing parenthesis, whose position it returns.
+func (l *lexer) substStmts(open ast.Pos) ([]*ast.Stmt, ast.Pos, error) {
+	ps := &parseState{lex: l, aliases: l.aliases}
+	if err := ps.advance(); err != nil {
+		return nil, ast.Pos{}, err
+	}
+	stmts, err := ps.stmtList()
+	if err != nil {
+		return nil, ast.Pos{}, err
+	}
+	switch {
+	case ps.tok.kind == tokEOF:
+		return nil, ast.Pos{}, incomplete(open, "unterminated substitution")
+	case ps.tok.kind != tokRParen:
+		return nil, ast.Pos{}, ps.unexpected()
+	}
+	return stmts, ps.tok.pos, nil
+}
+
+// parseNested parses src, the text of a backquoted command substitution
+// found at pos, as a complete list of commands.
+func (l *lexer) parseNested(src string, pos ast.Pos) ([]*ast.Stmt, error) {
+	lex := newLexerAt(src, pos)
+	lex.aliases = l.aliases
+	ps := &parseState{lex: lex, aliases: l.aliases}
+	if err := ps.advance(); err != nil {
+		return nil, err
+	}
+	file, err := ps.file()
+	if err != nil {
+		return nil, err
+	}
+	return file.Stmts, nil
 }
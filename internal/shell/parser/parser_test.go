@@ -2,9 +2,33 @@
 package parser
 
 import (
+	"errors"
+	"fmt"
+	"slices"
 	"testing"
+
+	"github.com/krzko/gosh/internal/shell/ast"
+	"github.com/krzko/gosh/internal/shell/expand"
 )
 
+func fields(t *testing.T, words []*ast.Word) []string {
+	t.Helper()
+	result, err := expand.Fields(nil, words...)
+	if err != nil {
+		t.Fatalf("Fields() error = %v", err)
+	}
+	return result
+}
+
+func literal(t *testing.T, word *ast.Word) string {
+	t.Helper()
+	result, err := expand.Literal(nil, word)
+	if err != nil {
+		t.Fatalf("Literal() error = %v", err)
+	}
+	return result
+}
+
 func TestParser(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -41,35 +65,508 @@ func TestParser(t *testing.T) {
 			wantArgs: []string{"hello world"},
 			wantPipe: false,
 		},
+		{
+			name:     "pipe inside quotes",
+			input:    `echo "a|b"`,
+			wantCmd:  "echo",
+			wantArgs: []string{"a|b"},
+			wantPipe: false,
+		},
+		{
+			name:     "single quotes",
+			input:    `echo 'it works' x'y'z`,
+			wantCmd:  "echo",
+			wantArgs: []string{"it works", "xyz"},
+			wantPipe: false,
+		},
+		{
+			name:     "backslashes in quotes",
+			input:    "grep 'a\\.b' \"a\\.b\" \"\\$x \\\" \\\\ \\`\" a\\.b",
+			wantCmd:  "grep",
+			wantArgs: []string{`a\.b`, `a\.b`, "$x \" \\ `", "a.b"},
+			wantPipe: false,
+		},
+		{
+			name:     "ANSI-C quotes",
+			input:    `echo $'a\tb\n' $'it\'s' $'\x41\101\u00e9' "$'x'"`,
+			wantCmd:  "echo",
+			wantArgs: []string{"a\tb\n", "it's", "AAé", "$'x'"},
+			wantPipe: false,
+		},
 	}
 
 	parser := New()
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cmd, err := parser.Parse(tt.input)
+			file, err := parser.Parse(tt.input)
 			if err != nil {
 				t.Fatalf("Parse() error = %v", err)
 			}
+			if len(file.Stmts) != 1 {
+				t.Fatalf("Parse() stmts = %d, want 1", len(file.Stmts))
+			}
+
+			stmt := file.Stmts[0]
+			pipe, hasPipe := stmt.Cmd.(*ast.Pipeline)
+			if hasPipe != tt.wantPipe {
+				t.Errorf("Parse() pipe = %v, want %v", hasPipe, tt.wantPipe)
+			}
+			if hasPipe {
+				stmt = pipe.Stmts[0]
+			}
 
-			if cmd.Name != tt.wantCmd {
-				t.Errorf("Parse() command = %v, want %v", cmd.Name, tt.wantCmd)
+			call, ok := stmt.Cmd.(*ast.CallExpr)
+			if !ok {
+				t.Fatalf("Parse() command type = %T, want *ast.CallExpr", stmt.Cmd)
 			}
+			words := fields(t, call.Args)
 
-			if len(cmd.Args) != len(tt.wantArgs) {
-				t.Errorf("Parse() args length = %v, want %v", len(cmd.Args), len(tt.wantArgs))
+			if words[0] != tt.wantCmd {
+				t.Errorf("Parse() command = %v, want %v", words[0], tt.wantCmd)
 			}
 
-			for i, arg := range cmd.Args {
+			args := words[1:]
+			if len(args) != len(tt.wantArgs) {
+				t.Fatalf("Parse() args = %q, want %q", args, tt.wantArgs)
+			}
+
+			for i, arg := range args {
 				if arg != tt.wantArgs[i] {
 					t.Errorf("Parse() arg[%d] = %v, want %v", i, arg, tt.wantArgs[i])
 				}
 			}
+		})
+	}
+}
 
-			hasPipe := cmd.Pipe != nil
-			if hasPipe != tt.wantPipe {
-				t.Errorf("Parse() pipe = %v, want %v", hasPipe, tt.wantPipe)
+func TestParserAssignments(t *testing.T) {
+	file, err := New().Parse("FOO=bar BAZ= env")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	call := file.Stmts[0].Cmd.(*ast.CallExpr)
+	if len(call.Assigns) != 2 {
+		t.Fatalf("Parse() assigns = %d, want 2", len(call.Assigns))
+	}
+	if call.Assigns[0].Name != "FOO" || literal(t, call.Assigns[0].Value) != "bar" {
+		t.Errorf("Parse() assign[0] = %s=%s, want FOO=bar",
+			call.Assigns[0].Name, literal(t, call.Assigns[0].Value))
+	}
+	if call.Assigns[1].Name != "BAZ" || literal(t, call.Assigns[1].Value) != "" {
+		t.Errorf("Parse() assign[1] = %s=%s, want BAZ=",
+			call.Assigns[1].Name, literal(t, call.Assigns[1].Value))
+	}
+	if len(call.Args) != 1 {
+		t.Errorf("Parse() args = %d, want 1", len(call.Args))
+	}
+}
+
+func TestParserErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantPos ast.Pos
+	}{
+		{
+			name:    "unterminated double quote",
+			input:   `echo "foo`,
+			wantPos: ast.Pos{Offset: 5, Line: 1, Col: 6},
+		},
+		{
+			name:    "unterminated single quote",
+			input:   `echo 'foo`,
+			wantPos: ast.Pos{Offset: 5, Line: 1, Col: 6},
+		},
+		{
+			name:    "unterminated parameter expansion",
+			input:   `echo ${HOME`,
+			wantPos: ast.Pos{Offset: 5, Line: 1, Col: 6},
+		},
+		{
+			name:    "bad substitution",
+			input:   `echo ${HOME!}`,
+			wantPos: ast.Pos{Offset: 11, Line: 1, Col: 12},
+		},
+		{
+			name:    "leading pipe",
+			input:   "| grep foo",
+			wantPos: ast.Pos{Offset: 0, Line: 1, Col: 1},
+		},
+		{
+			name:    "error on second line",
+			input:   "ls\n  cat |",
+			wantPos: ast.Pos{Offset: 10, Line: 2, Col: 8},
+		},
+	}
+
+	parser := New()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.Parse(tt.input)
+			var perr *Error
+			if !errors.As(err, &perr) {
+				t.Fatalf("Parse() error = %v, want *Error", err)
+			}
+			if perr.Pos != tt.wantPos {
+				t.Errorf("Parse() error pos = %+v, want %+v", perr.Pos, tt.wantPos)
 			}
 		})
 	}
 }
+
+func TestParserRedirects(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantArgs []string
+		wantOps  []ast.RedirOp
+		wantN    []string
+		wantWord []string
+	}{
+		{
+			input:    "ls -l > out.txt",
+			wantArgs: []string{"ls", "-l"},
+			wantOps:  []ast.RedirOp{ast.RedirOut},
+			wantN:    []string{""},
+			wantWord: []string{"out.txt"},
+		},
+		{
+			input:    "cmd 2>&1 >>log <in",
+			wantArgs: []string{"cmd"},
+			wantOps:  []ast.RedirOp{ast.DupOut, ast.AppendOut, ast.RedirIn},
+			wantN:    []string{"2", "", ""},
+			wantWord: []string{"1", "log", "in"},
+		},
+		{
+			input:    "<>rw cat &>all a2>b",
+			wantArgs: []string{"cat", "a2"},
+			wantOps:  []ast.RedirOp{ast.RedirInOut, ast.RedirAll, ast.RedirOut},
+			wantN:    []string{"", "", ""},
+			wantWord: []string{"rw", "all", "b"},
+		},
+		{
+			input:    `echo "2>x"`,
+			wantArgs: []string{"echo", "2>x"},
+		},
+	}
+
+	parser := New()
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			file, err := parser.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			stmt := file.Stmts[0]
+			args := fields(t, stmt.Cmd.(*ast.CallExpr).Args)
+			if len(args) != len(tt.wantArgs) {
+				t.Fatalf("Parse() args = %q, want %q", args, tt.wantArgs)
+			}
+			for i := range args {
+				if args[i] != tt.wantArgs[i] {
+					t.Errorf("Parse() arg[%d] = %q, want %q", i, args[i], tt.wantArgs[i])
+				}
+			}
+
+			if len(stmt.Redirs) != len(tt.wantOps) {
+				t.Fatalf("Parse() redirs = %d, want %d", len(stmt.Redirs), len(tt.wantOps))
+			}
+			for i, r := range stmt.Redirs {
+				if r.Op != tt.wantOps[i] {
+					t.Errorf("Parse() redir[%d] op = %s, want %s", i, r.Op, tt.wantOps[i])
+				}
+				n := ""
+				if r.N != nil {
+					n = r.N.Value
+				}
+				if n != tt.wantN[i] {
+					t.Errorf("Parse() redir[%d] fd = %q, want %q", i, n, tt.wantN[i])
+				}
+				if word := literal(t, r.Word); word != tt.wantWord[i] {
+					t.Errorf("Parse() redir[%d] word = %q, want %q", i, word, tt.wantWord[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParserLists(t *testing.T) {
+	file, err := New().Parse("a; b && c || d\ne;")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(file.Stmts) != 3 {
+		t.Fatalf("Parse() stmts = %d, want 3", len(file.Stmts))
+	}
+
+	// "b && c || d" is left-associative: ((b && c) || d)
+	or, ok := file.Stmts[1].Cmd.(*ast.BinaryCmd)
+	if !ok || or.Op != ast.OrStmt {
+		t.Fatalf("Parse() stmt[1] = %#v, want || BinaryCmd", file.Stmts[1].Cmd)
+	}
+	and, ok := or.X.Cmd.(*ast.BinaryCmd)
+	if !ok || and.Op != ast.AndStmt {
+		t.Fatalf("Parse() stmt[1].X = %#v, want && BinaryCmd", or.X.Cmd)
+	}
+	if name := literal(t, or.Y.Cmd.(*ast.CallExpr).Args[0]); name != "d" {
+		t.Errorf("Parse() stmt[1].Y = %q, want d", name)
+	}
+
+	file, err = New().Parse("a & b && c &\nd")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	var background []bool
+	for _, stmt := range file.Stmts {
+		background = append(background, stmt.Background)
+	}
+	if fmt.Sprint(background) != "[true true false]" {
+		t.Errorf("Parse() background = %v, want [true true false]", background)
+	}
+
+	for _, input := range []string{"a ;; b", "&& b", "a ||", "; a", "& a", "a & ;"} {
+		if _, err := New().Parse(input); err == nil {
+			t.Errorf("Parse(%q) error = nil, want syntax error", input)
+		}
+	}
+}
+
+func TestParserAliases(t *testing.T) {
+	p := New()
+	p.SetAliases(map[string]string{
+		"ll":    "ls -la",
+		"ls":    "ls -F",
+		"gs":    "git status | head",
+		"a":     "b x",
+		"b":     "a y",
+		"sudo":  "sudo ",
+		"empty": "",
+	})
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"ll /tmp", "ls -F -la /tmp"},
+		{"gs -n; echo ll", "git status | head -n\necho ll"},
+		{"a z", "a y x z"},
+		{"sudo ll", "sudo ls -la"},
+		{"echo sudo ll", "echo sudo ll"},
+		{"X=1 ll", "X=1 ls -F -la"},
+		{"'ll' \\ll", "'ll' \\ll"},
+		{"true && ll | ll", "true && ls -F -la | ls -F -la"},
+		{"empty echo", "echo"},
+	}
+	for _, tt := range tests {
+		file, err := p.Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.input, err)
+			continue
+		}
+		if got := ast.String(file); got != tt.want {
+			t.Errorf("Parse(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseEach(t *testing.T) {
+	aliases := map[string]string{}
+	p := New()
+	p.SetAliases(aliases)
+
+	// Each command is parsed after the one before has been handled, so
+	// an alias defined then applies to the lines after it but not the
+	// rest of its own.
+	src := "define; gs\n\ngs\nif gs\nthen gs; fi\ncat <<EOF\ngs\nEOF\ngs &\ngs )\nnever"
+	var got []string
+	err := p.ParseEach(src, func(file *ast.File) bool {
+		got = append(got, ast.String(file))
+		aliases["gs"] = "git status"
+		return true
+	})
+	if err == nil {
+		t.Errorf("ParseEach() error = nil, want a syntax error")
+	}
+	want := []string{"define\ngs", "git status", "if git status; then git status; fi", "cat <<EOF", "git status &"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseEach() commands = %q, want %q", got, want)
+	}
+
+	got = nil
+	p.ParseEach("a\nb\nc", func(file *ast.File) bool {
+		got = append(got, ast.String(file))
+		return len(got) < 2
+	})
+	if want := []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Errorf("ParseEach() stopped early = %q, want %q", got, want)
+	}
+}
+
+func TestParserFunctions(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"f() { echo $1; }", "f() { echo $1; }"},
+		{"function mk-cd { mkdir \"$1\" && cd \"$1\"; } >/dev/null", "mk-cd() { mkdir \"$1\" && cd \"$1\"; } >/dev/null"},
+		{"f()\n{\n  a &\n  b\n}\nf x", "f() { a & b; }\nf x"},
+		{"{ a; b; } | c", "{ a; b; } | c"},
+		{"echo { }", "echo { }"},
+	}
+	for _, tt := range tests {
+		file, err := New().Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.input, err)
+			continue
+		}
+		if got := ast.String(file); got != tt.want {
+			t.Errorf("Parse(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"f() echo", "{ }", "{ a }", "}", "f( ) { a; } b", "a=b() { c; }", "function { a; }"} {
+		if _, err := New().Parse(input); err == nil {
+			t.Errorf("Parse(%q) error = nil, want syntax error", input)
+		}
+	}
+}
+
+func TestParserControlFlow(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"if a; then b; fi", "if a; then b; fi"},
+		{"if a\nthen\n  b\nelif c; then d\nelse e; fi > out", "if a; then b; elif c; then d; else e; fi >out"},
+		{"while a; do b; c; done", "while a; do b; c; done"},
+		{"until a\ndo\n  b &\ndone", "until a; do b & done"},
+		{"for x in a \"b c\"; do echo $x; done", "for x in a \"b c\"; do echo $x; done"},
+		{"for x\ndo echo $x; done", "for x; do echo $x; done"},
+		{"for x in; do a; done", "for x in; do a; done"},
+		{"case $1 in\n  a|b) x;;\n  (*) y; z\nesac", "case $1 in a|b) x;; *) y; z;; esac"},
+		{"case x in esac", "case x in esac"},
+		{"f() if a; then b; fi", "f() if a; then b; fi"},
+		{"echo if then fi", "echo if then fi"},
+	}
+	for _, tt := range tests {
+		file, err := New().Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.input, err)
+			continue
+		}
+		if got := ast.String(file); got != tt.want {
+			t.Errorf("Parse(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"if a; then b", "if a; fi", "if; then b; fi", "while a; done", "for 1x in a; do b; done", "for x in a do b; done", "case x in a) b", "case x a) b;; esac", "fi", "done"} {
+		if _, err := New().Parse(input); err == nil {
+			t.Errorf("Parse(%q) error = nil, want syntax error", input)
+		}
+	}
+}
+
+func TestParserSubstitutions(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"cd $(git rev-parse --show-toplevel)", "cd $(git rev-parse --show-toplevel)"},
+		{"echo \"$(a | b; c &)x\"", "echo \"$(a | b; c &)x\""},
+		{"echo $(echo $(date))", "echo $(echo $(date))"},
+		{"echo $(\n  a\n  b\n)", "echo $(a; b)"},
+		{"echo $()", "echo $()"},
+		{"echo $(case x in a) b;; esac)", "echo $(case x in a) b;; esac)"},
+		{"echo `date` x`a \\`b\\``", "echo $(date) x$(a $(b))"},
+		{"echo \"`echo \\\"q\\\"`\"", "echo \"$(echo \"q\")\""},
+		{"diff <(ls a) <(ls b)", "diff <(ls a) <(ls b)"},
+		{"tee >(wc -l) < in", "tee >(wc -l) <in"},
+		{"echo $((1 + (2 * $x)))", "echo $((1 + (2 * $x)))"},
+		{"echo \"$((${#a} + $(b)))\"", "echo \"$((${#a} + $(b)))\""},
+	}
+	for _, tt := range tests {
+		file, err := New().Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.input, err)
+			continue
+		}
+		if got := ast.String(file); got != tt.want {
+			t.Errorf("Parse(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"echo $(a", "echo $(a;;)", "echo `a", "echo <(a", "echo $(fi)", "a)", "cat < (x)"} {
+		if _, err := New().Parse(input); err == nil {
+			t.Errorf("Parse(%q) error = nil, want syntax error", input)
+		}
+	}
+}
+
+func TestParserHeredocs(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		hdocs []string
+	}{
+		{"cat <<EOF\nhello $x\nEOF", "cat <<EOF", []string{"\"'hello '$x'\n'\""}},
+		{"cat <<'EOF' >out\n$x `y`\nEOF\necho", "cat <<'EOF' >out\necho", []string{"'$x `y`\n'"}},
+		{"cat <<-\\E\n\t\tindented\n\tE", "cat <<-\\E", []string{"'indented\n'"}},
+		{"cat <<A; cat <<B\na\nA\nb\nB", "cat <<A\ncat <<B", []string{"\"'a\n'\"", "\"'b\n'\""}},
+		{"cat <<E\n\\$x \\\\ \\\" a\\\nb $(echo)\nE", "cat <<E", []string{"\"'$x \\ \\\" ab '$(echo)'\n'\""}},
+		{"cat <<E\nE", "cat <<E", []string{`""`}},
+		{"tr a b <<< \"$x y\"", "tr a b <<<\"$x y\"", nil},
+	}
+	for _, tt := range tests {
+		file, err := New().Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.input, err)
+			continue
+		}
+		if got := ast.String(file); got != tt.want {
+			t.Errorf("Parse(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+		var hdocs []string
+		for _, stmt := range file.Stmts {
+			for _, r := range stmt.Redirs {
+				if r.Hdoc != nil {
+					hdocs = append(hdocs, ast.String(r.Hdoc))
+				}
+			}
+		}
+		if !slices.Equal(hdocs, tt.hdocs) {
+			t.Errorf("Parse(%q) bodies = %q, want %q", tt.input, hdocs, tt.hdocs)
+		}
+	}
+
+	for _, input := range []string{"cat <<EOF", "cat <<EOF\nbody", "echo 'open", "echo \"open", "echo `open", "echo $(open"} {
+		if _, err := New().Parse(input); !IsIncomplete(err) {
+			t.Errorf("Parse(%q) error = %v, want incomplete input", input, err)
+		}
+	}
+	if _, err := New().Parse("echo )"); err == nil || IsIncomplete(err) {
+		t.Errorf("Parse(%q) error = %v, want a complete syntax error", "echo )", err)
+	}
+}
+
+func TestParserIncomplete(t *testing.T) {
+	incomplete := []string{
+		"echo $((1 +", "ls |", "ls &&", "ls ||", "echo foo \\", "ls |\n",
+		"if true; then", "if true; then ls; else", "while true; do", "for x in a b",
+		"case x in", "case x in a) ls;;", "{ ls", "f()",
+	}
+	for _, input := range incomplete {
+		if _, err := New().Parse(input); !IsIncomplete(err) {
+			t.Errorf("Parse(%q) error = %v, want incomplete input", input, err)
+		}
+	}
+
+	for _, input := range []string{"echo >", "ls | | wc", "if true; fi"} {
+		if _, err := New().Parse(input); err == nil || IsIncomplete(err) {
+			t.Errorf("Parse(%q) error = %v, want a complete syntax error", input, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// internal/shell/prompt/git.go
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitBranch returns the branch checked out in the git work tree containing
+// dir, the short commit hash if HEAD is detached, or "" outside a work
+// tree. It reads .git/HEAD rather than running git, since it is called for
+// every prompt.
+func gitBranch(dir string) string {
+	for dir != "" {
+		gitDir := filepath.Join(dir, ".git")
+		if info, err := os.Stat(gitDir); err == nil {
+			if !info.IsDir() {
+				// A worktree or submodule: .git names the real directory.
+				data, err := os.ReadFile(gitDir)
+				if err != nil {
+					return ""
+				}
+				ref, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+				if !ok {
+					return ""
+				}
+				if !filepath.IsAbs(ref) {
+					ref = filepath.Join(dir, ref)
+				}
+				gitDir = ref
+			}
+			return headBranch(gitDir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return ""
+}
+
+func headBranch(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	head := strings.TrimSpace(string(data))
+	if ref, ok := strings.CutPrefix(head, "ref: "); ok {
+		return strings.TrimPrefix(ref, "refs/heads/")
+	}
+	if len(head) > 7 {
+		head = head[:7]
+	}
+	return head
+}
@@ -2,106 +2,217 @@
 package prompt
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"os/user"
+	"regexp"
 	"strings"
 
 	"github.com/chzyer/readline"
 	"github.com/krzko/gosh/internal/shell/command"
 	"github.com/krzko/gosh/internal/shell/completion"
+	"github.com/krzko/gosh/internal/shell/history"
+	"github.com/krzko/gosh/internal/shell/vars"
 	"github.com/krzko/gosh/internal/utils/color"
 )
 
+// ErrInterrupt is returned by Read when the user presses Ctrl-C at the
+// prompt.
+var ErrInterrupt = readline.ErrInterrupt
+
 type Manager struct {
 	format   string
+	ps2      string
 	theme    *color.Theme
 	rl       *readline.Instance
+	history  *history.Manager
 	builtins map[string]command.BuiltinCommand
+	vars     *vars.Store
+
+	// Incomplete, if set, reports whether the input read so far needs
+	// more lines, such as after an open quote or a trailing pipe.
+	Incomplete func(input string) bool
 }
 
 type Config struct {
+	// Format is the prompt, with ${user}, ${hostname}, ${pwd} and
+	// ${NAME} replaced by their values, and ${git_branch} by " (branch)"
+	// inside a git work tree. If empty, the prompt is built from the
+	// Show settings.
+	Format        string
 	ShowGitBranch bool
 	ShowHostname  bool
 	ShowPath      bool
 	Theme         string
+	// PS2 is the prompt for the further lines of a command that is not
+	// complete yet, unless the PS2 variable is set.
+	PS2 string
 }
 
-// internal/shell/prompt/manager.go
-func NewManager(completer *completion.Completer, builtins map[string]command.BuiltinCommand) (*Manager, error) {
+// DefaultConfig returns the prompt settings used without a config file.
+func DefaultConfig() Config {
+	return Config{
+		ShowHostname: true,
+		ShowPath:     true,
+		Theme:        "default",
+		PS2:          "> ",
+	}
+}
+
+// NewManager creates a prompt that offers the entries of hist for recall
+// and adds what is read to it.
+func NewManager(completer *completion.Completer, builtins map[string]command.BuiltinCommand, variables *vars.Store, cfg Config, hist *history.Manager) (*Manager, error) {
+	theme, err := color.Named(cfg.Theme)
+	if err != nil {
+		return nil, err
+	}
+	color.Use(theme)
+
 	rlConfig := &readline.Config{
-		Prompt:          "> ",
-		HistoryFile:     homeDir() + "/.gosh_history",
-		InterruptPrompt: "^C",
-		EOFPrompt:       "exit",
-		AutoComplete:    completer,
+		Prompt: "> ",
+		// Entries are added by Read once a command is complete, so that
+		// one of several lines is kept and recalled as a whole.
+		HistoryLimit:           hist.Size(),
+		DisableAutoSaveHistory: true,
+		InterruptPrompt:        "^C",
+		EOFPrompt:              "exit",
+		AutoComplete:           completer,
+		// Ctrl-Z at the prompt would suspend the shell itself; it is
+		// only meaningful for the job running in the foreground.
+		FuncFilterInputRune: func(r rune) (rune, bool) {
+			return r, r != readline.CharCtrlZ
+		},
 	}
 
 	rl, err := readline.NewEx(rlConfig)
 	if err != nil {
 		return nil, err
 	}
+	for _, entry := range hist.Entries() {
+		rl.SaveHistory(entry)
+	}
 
 	return &Manager{
-		format:   "${user}@${hostname}:${pwd}$ ",
-		theme:    color.DefaultTheme(),
+		format:   cfg.format(),
+		ps2:      cfg.PS2,
+		theme:    color.Current(),
 		rl:       rl,
+		history:  hist,
 		builtins: builtins,
+		vars:     variables,
 	}, nil
 }
 
+// format returns the prompt format: Format if set, and otherwise one
+// built from the Show settings.
+func (c Config) format() string {
+	if c.Format != "" {
+		return c.Format
+	}
+	format := "${user}"
+	if c.ShowHostname {
+		format += "@${hostname}"
+	}
+	if c.ShowPath {
+		format += ":${pwd}"
+	}
+	if c.ShowGitBranch {
+		format += "${git_branch}"
+	}
+	return format + "$ "
+}
+
 func (m *Manager) SetFormat(format string) {
 	m.format = format
 }
 
+// Read reads a command, going on with further lines at the PS2 prompt
+// while it is incomplete, and adds it to the history. At the end of the
+// input the lines read so far are returned; Ctrl-C discards them and
+// returns ErrInterrupt.
 func (m *Manager) Read() (string, error) {
 	prompt := m.buildPrompt()
 	m.rl.SetPrompt(prompt)
-	line, err := m.rl.Readline()
+	input, err := m.rl.Readline()
 	if err != nil {
-		return line, err
+		return input, err
 	}
+	lines := 1
+	for m.Incomplete != nil && m.Incomplete(input) {
+		m.rl.SetPrompt(m.continuationPrompt())
+		line, err := m.rl.Readline()
+		if errors.Is(err, ErrInterrupt) {
+			return "", err
+		}
+		if err != nil {
+			break
+		}
+		input += "\n" + line
+		lines++
+	}
+	m.addHistory(input)
 
 	// Colorize the command if it's valid
-	words := strings.Fields(line)
-	if len(words) > 0 {
+	words := strings.Fields(input)
+	if lines == 1 && len(words) > 0 {
 		cmd := words[0]
 		if _, ok := m.builtins[cmd]; ok {
-			// Save original command for return
-			original := line
 			// Show colorized version
 			fmt.Printf("\033[1A\033[2K\r%s\033[32m%s\033[0m%s\n",
-				prompt, cmd, line[len(cmd):])
-			return original, nil
+				prompt, cmd, input[len(cmd):])
 		}
 	}
 
-	return line, nil
+	return input, nil
 }
 
-func (m *Manager) buildPrompt() string {
-	promptStr := m.format
-
-	// Replace user
-	if strings.Contains(promptStr, "${user}") {
-		if u, err := user.Current(); err == nil {
-			promptStr = strings.ReplaceAll(promptStr, "${user}", u.Username)
-		}
+// continuationPrompt returns the PS2 variable, or the configured prompt
+// if it is not set.
+func (m *Manager) continuationPrompt() string {
+	if ps2, ok := m.vars.Get("PS2"); ok {
+		return ps2
 	}
+	return m.ps2
+}
 
-	// Replace hostname
-	if strings.Contains(promptStr, "${hostname}") {
-		if hostname, err := os.Hostname(); err == nil {
-			promptStr = strings.ReplaceAll(promptStr, "${hostname}", hostname)
-		}
+func (m *Manager) addHistory(input string) {
+	if strings.TrimSpace(input) == "" {
+		return
+	}
+	m.rl.SaveHistory(input)
+	if err := m.history.Add(input); err != nil {
+		fmt.Fprintf(os.Stderr, "gosh: history: %v\n", err)
 	}
+}
+
+// promptVars maps the prompt format's lower-case placeholders to the shell
+// variables that back them. Any other ${NAME} is looked up directly.
+var promptVars = map[string]string{
+	"user":     "USER",
+	"hostname": "HOSTNAME",
+	"pwd":      "PWD",
+}
+
+var placeholderRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
 
-	// Replace pwd
-	if strings.Contains(promptStr, "${pwd}") {
-		if pwd, err := os.Getwd(); err == nil {
-			promptStr = strings.ReplaceAll(promptStr, "${pwd}", pwd)
+func (m *Manager) buildPrompt() string {
+	promptStr := placeholderRe.ReplaceAllStringFunc(m.format, func(placeholder string) string {
+		name := placeholder[2 : len(placeholder)-1]
+		if name == "git_branch" {
+			pwd, _ := m.vars.Get("PWD")
+			if branch := gitBranch(pwd); branch != "" {
+				return " (" + branch + ")"
+			}
+			return ""
 		}
-	}
+		if v, ok := promptVars[name]; ok {
+			name = v
+		}
+		if value, ok := m.vars.Get(name); ok {
+			return value
+		}
+		return placeholder
+	})
 
 	return m.theme.ColorizePrompt(promptStr)
 }
@@ -112,10 +223,3 @@ func (m *Manager) Close() error {
 	}
 	return nil
 }
-
-func homeDir() string {
-	if home, err := os.UserHomeDir(); err == nil {
-		return home
-	}
-	return os.TempDir()
-}
@@ -2,10 +2,16 @@
 package shell
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/krzko/gosh/internal/shell/ast"
+	"github.com/krzko/gosh/internal/shell/builtins"
 	"github.com/krzko/gosh/internal/shell/completion"
+	"github.com/krzko/gosh/internal/shell/config"
 	"github.com/krzko/gosh/internal/shell/executor"
 	"github.com/krzko/gosh/internal/shell/history"
 	"github.com/krzko/gosh/internal/shell/parser"
@@ -18,24 +24,34 @@ type Shell struct {
 	executor  *executor.Executor
 	completer *completion.Completer
 	history   *history.Manager
+	signals   chan os.Signal
 }
 
-// internal/shell/shell.go
-func New() (*Shell, error) {
+// New creates an interactive shell and runs its startup files.
+func New(opts Options) (*Shell, error) {
+	cfg := loadConfig()
+
 	// Initialize history first
-	hist, err := history.NewManager(".gosh_history")
+	hist, err := history.NewManager(cfg.History)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize history: %w", err)
 	}
 
 	// Initialize executor to get builtins
 	executor := executor.New()
+	executor.SetInteractive(true)
+	if err := executor.EnableJobControl(); err != nil {
+		printError(err)
+	}
+	addConfigBuiltins(executor, cfg)
 
 	// Initialize completer
-	completer := completion.NewCompleter()
+	completer := completion.NewCompleter(cfg.Completion)
+	completer.SetBuiltins(executor.GetBuiltins())
+	completer.SetAliases(executor.Aliases())
 
 	// Initialize prompt with history and builtins
-	promptManager, err := prompt.NewManager(completer, executor.GetBuiltins())
+	promptManager, err := prompt.NewManager(completer, executor.GetBuiltins(), executor.Vars(), cfg.Prompt, hist)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize prompt: %w", err)
 	}
@@ -43,21 +59,81 @@ func New() (*Shell, error) {
 	// Create shell instance
 	sh := &Shell{
 		prompt:    promptManager,
-		parser:    parser.New(),
+		parser:    executor.NewParser(),
 		executor:  executor,
 		completer: completer,
 		history:   hist,
 	}
+	promptManager.Incomplete = func(input string) bool {
+		_, err := sh.parser.Parse(input)
+		return parser.IsIncomplete(err)
+	}
+	sh.handleSignals()
+	sh.loadStartupFiles(opts)
 
 	return sh, nil
 }
 
+// loadConfig loads the config file. A broken config file is reported,
+// but the shell still starts with the settings that could be read.
+func loadConfig() *config.Config {
+	cfg, err := config.Load(config.DefaultPath())
+	if err != nil {
+		printError(err)
+	}
+	return cfg
+}
+
+// printError reports err on stderr. The errors joined in one, such as
+// one for each bad line of the config file, are each given a line of
+// their own.
+func printError(err error) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			printError(err)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "gosh: %v\n", err)
+}
+
+// addConfigBuiltins adds the builtins that use the settings in cfg.
+func addConfigBuiltins(executor *executor.Executor, cfg *config.Config) {
+	executor.AddBuiltin("config", &builtins.ConfigCommand{Config: cfg})
+	executor.AddBuiltin("http", &builtins.HttpCommand{Config: &cfg.HTTP})
+	executor.AddBuiltin("https", &builtins.HttpsCommand{Config: &cfg.HTTP})
+}
+
+// handleSignals stops Ctrl-C and Ctrl-\ from killing the shell. The
+// signals are caught rather than ignored, so that external commands still
+// get the default dispositions and are killed by them. Ctrl-C also
+// interrupts the builtins running in the foreground.
+func (s *Shell) handleSignals() {
+	s.signals = make(chan os.Signal, 1)
+	signal.Notify(s.signals, os.Interrupt, syscall.SIGQUIT)
+	go func() {
+		for sig := range s.signals {
+			if sig == os.Interrupt {
+				s.executor.Interrupt()
+			}
+		}
+	}()
+}
+
 func (s *Shell) Run() error {
 	defer s.cleanup()
 
-	for {
+	// A startup file may already have run exit.
+	for !s.executor.Exited() {
+		s.executor.NotifyJobs(os.Stderr)
+
 		input, err := s.prompt.Read()
 		if err != nil {
+			if errors.Is(err, prompt.ErrInterrupt) {
+				// Ctrl-C at the prompt discards the line.
+				s.executor.SetStatus(130)
+				continue
+			}
 			if err.Error() == "EOF" {
 				return nil // Clean exit on Ctrl+D
 			}
@@ -68,26 +144,62 @@ func (s *Shell) Run() error {
 			continue
 		}
 
-		// Add to history
-		if err := s.history.Add(input); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to add to history: %v\n", err)
-		}
-
-		// Parse the command
-		cmd, err := s.parser.Parse(input)
+		file, err := s.parser.Parse(input)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Parse error: %v\n", err)
+			s.executor.SetStatus(2)
 			continue
 		}
 
-		// Execute the command
-		if err := s.executor.Execute(cmd); err != nil {
-			fmt.Fprintf(os.Stderr, "Execution error: %v\n", err)
-		}
+		// Execute the command; failures are reported by the executor
+		// and reflected in its exit status.
+		s.executor.Run(file)
+	}
+	return nil
+}
+
+// NewScript creates a shell that runs commands without a prompt, history
+// or job control, for gosh -c, script files and commands piped to its
+// standard input. name becomes $0 and args the positional parameters.
+func NewScript(name string, args []string) *Shell {
+	executor := executor.New()
+	executor.SetParams(name, args)
+	addConfigBuiltins(executor, loadConfig())
+
+	return &Shell{
+		parser:   executor.NewParser(),
+		executor: executor,
 	}
 }
 
+// RunScript runs src, parsing each command just before it runs, so that
+// aliases defined in a script apply to the lines after them. A syntax
+// error stops the script with exit status 2; otherwise the exit status is
+// that of the last command run, or that given to exit.
+func (s *Shell) RunScript(name, src string) {
+	defer s.cleanup()
+
+	err := s.parser.ParseEach(src, func(file *ast.File) bool {
+		s.executor.Run(file)
+		return !s.executor.Exited()
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gosh: %s: %v\n", name, err)
+		s.executor.SetStatus(2)
+	}
+}
+
+// ExitStatus returns the status the shell process should exit with: that
+// of the last command run.
+func (s *Shell) ExitStatus() int {
+	return s.executor.Status()
+}
+
 func (s *Shell) cleanup() {
+	if s.signals != nil {
+		signal.Stop(s.signals)
+		close(s.signals)
+	}
 	if s.prompt != nil {
 		s.prompt.Close()
 	}
@@ -0,0 +1,79 @@
+// internal/shell/startup.go
+package shell
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/krzko/gosh/internal/shell/config"
+)
+
+// Options controls how an interactive shell starts.
+type Options struct {
+	// Login is set for a login shell, which runs the login profile
+	// before the rc file.
+	Login bool
+	// NoProfile skips the login profile.
+	NoProfile bool
+	// NoRC skips the rc file.
+	NoRC bool
+	// RCFile replaces the default rc file.
+	RCFile string
+}
+
+// profileFiles returns the candidate login profiles, in order of
+// preference: ~/.gosh_profile, then profile in the XDG config directory.
+func profileFiles() []string {
+	return startupFiles(".gosh_profile", "profile")
+}
+
+// rcFiles returns the candidate rc files, in order of preference:
+// ~/.goshrc, then goshrc in the XDG config directory.
+func rcFiles() []string {
+	return startupFiles(".goshrc", "goshrc")
+}
+
+func startupFiles(homeName, configName string) []string {
+	var files []string
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, homeName))
+	}
+	if dir := config.Dir(); dir != "" {
+		files = append(files, filepath.Join(dir, configName))
+	}
+	return files
+}
+
+// loadStartupFiles runs the login profile, for a login shell, and then the
+// rc file. Only the first existing file of each kind is run.
+func (s *Shell) loadStartupFiles(opts Options) {
+	if opts.Login && !opts.NoProfile {
+		s.sourceFirst(profileFiles())
+	}
+	if opts.NoRC {
+		return
+	}
+	if opts.RCFile != "" {
+		s.source(opts.RCFile)
+		return
+	}
+	s.sourceFirst(rcFiles())
+}
+
+func (s *Shell) sourceFirst(paths []string) {
+	for _, path := range paths {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		s.source(path)
+		return
+	}
+}
+
+func (s *Shell) source(path string) {
+	if _, err := s.executor.SourceFile(path, nil); err != nil {
+		printError(err)
+	}
+}
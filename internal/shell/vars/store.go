@@ -0,0 +1,187 @@
+// internal/shell/vars/store.go
+package vars
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Variable is a single shell variable.
+type Variable struct {
+	Name     string
+	Value    string
+	Exported bool
+}
+
+// Store holds the shell's variables. It is safe for concurrent use, since
+// builtins in a pipeline run on their own goroutines.
+type Store struct {
+	mu   sync.RWMutex
+	vars map[string]*Variable
+	// scopes holds a frame for each function call in progress, mapping
+	// the names of its local variables to the variables they hide, or to
+	// nil for those that were unset.
+	scopes []map[string]*Variable
+}
+
+func New() *Store {
+	return &Store{vars: make(map[string]*Variable)}
+}
+
+// FromEnviron creates a store holding the given KEY=value pairs, all
+// marked as exported.
+func FromEnviron(environ []string) *Store {
+	s := New()
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || name == "" {
+			continue
+		}
+		s.vars[name] = &Variable{Name: name, Value: value, Exported: true}
+	}
+	return s
+}
+
+// Clone returns an independent copy of the store, for a subshell whose
+// changes must not affect its parent.
+func (s *Store) Clone() *Store {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	clone := New()
+	for name, v := range s.vars {
+		copied := *v
+		clone.vars[name] = &copied
+	}
+	for _, frame := range s.scopes {
+		copied := make(map[string]*Variable, len(frame))
+		for name, v := range frame {
+			if v != nil {
+				saved := *v
+				v = &saved
+			}
+			copied[name] = v
+		}
+		clone.scopes = append(clone.scopes, copied)
+	}
+	return clone
+}
+
+// PushScope starts a scope for local variables, as for a function call.
+func (s *Store) PushScope() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scopes = append(s.scopes, make(map[string]*Variable))
+}
+
+// PopScope ends the innermost scope, restoring the variables its local
+// variables hid.
+func (s *Store) PopScope() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.scopes)
+	if n == 0 {
+		return
+	}
+	for name, v := range s.scopes[n-1] {
+		if v == nil {
+			delete(s.vars, name)
+		} else {
+			s.vars[name] = v
+		}
+	}
+	s.scopes = s.scopes[:n-1]
+}
+
+// Local makes name local to the innermost scope, unset until it is given
+// a value. Until the scope ends, the variable hides any of the same name,
+// including from functions it calls. It reports false if there is no
+// scope.
+func (s *Store) Local(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.scopes)
+	if n == 0 {
+		return false
+	}
+	frame := s.scopes[n-1]
+	if _, ok := frame[name]; !ok {
+		frame[name] = s.vars[name]
+		delete(s.vars, name)
+	}
+	return true
+}
+
+// Get returns the value of a variable and whether it is set.
+func (s *Store) Get(name string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if v, ok := s.vars[name]; ok {
+		return v.Value, true
+	}
+	return "", false
+}
+
+// Set sets a variable's value, keeping its exported flag if it already
+// exists.
+func (s *Store) Set(name, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.vars[name]; ok {
+		v.Value = value
+		return
+	}
+	s.vars[name] = &Variable{Name: name, Value: value}
+}
+
+// Export marks a variable as exported, creating it with an empty value if
+// it does not exist.
+func (s *Store) Export(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.vars[name]; ok {
+		v.Exported = true
+		return
+	}
+	s.vars[name] = &Variable{Name: name, Exported: true}
+}
+
+// Unexport removes a variable's exported flag without unsetting it.
+func (s *Store) Unexport(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.vars[name]; ok {
+		v.Exported = false
+	}
+}
+
+// Unset removes a variable.
+func (s *Store) Unset(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.vars, name)
+}
+
+// All returns a copy of every variable, sorted by name.
+func (s *Store) All() []Variable {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	all := make([]Variable, 0, len(s.vars))
+	for _, v := range s.vars {
+		all = append(all, *v)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// Environ returns the exported variables as KEY=value pairs, sorted by
+// name, for passing to child processes.
+func (s *Store) Environ() []string {
+	var env []string
+	for _, v := range s.All() {
+		if v.Exported {
+			env = append(env, v.Name+"="+v.Value)
+		}
+	}
+	return env
+}
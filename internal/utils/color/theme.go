@@ -2,7 +2,10 @@
 package color
 
 import (
+	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/fatih/color"
 )
@@ -13,8 +16,25 @@ type Theme struct {
 	execColor    *color.Color
 	symlinkColor *color.Color
 	promptColor  *color.Color
+
+	// Colors of JSON output.
+	keyColor     *color.Color
+	stringColor  *color.Color
+	numberColor  *color.Color
+	literalColor *color.Color
 }
 
+// JSONKind is the kind of a token of JSON output, for ColorizeJSON.
+type JSONKind int
+
+const (
+	JSONKey JSONKind = iota
+	JSONString
+	JSONNumber
+	// JSONLiteral is true, false or null.
+	JSONLiteral
+)
+
 func DefaultTheme() *Theme {
 	return &Theme{
 		dirColor:     color.New(color.FgBlue, color.Bold),
@@ -22,7 +42,84 @@ func DefaultTheme() *Theme {
 		execColor:    color.New(color.FgGreen, color.Bold),
 		symlinkColor: color.New(color.FgCyan),
 		promptColor:  color.New(color.FgYellow, color.Bold),
+		keyColor:     color.New(color.FgBlue, color.Bold),
+		stringColor:  color.New(color.FgGreen),
+		numberColor:  color.New(color.FgCyan),
+		literalColor: color.New(color.FgMagenta),
+	}
+}
+
+// LightTheme suits terminals with a light background.
+func LightTheme() *Theme {
+	return &Theme{
+		dirColor:     color.New(color.FgBlue, color.Bold),
+		fileColor:    color.New(color.FgBlack),
+		execColor:    color.New(color.FgGreen),
+		symlinkColor: color.New(color.FgMagenta),
+		promptColor:  color.New(color.FgBlue, color.Bold),
+		keyColor:     color.New(color.FgBlue),
+		stringColor:  color.New(color.FgGreen),
+		numberColor:  color.New(color.FgRed),
+		literalColor: color.New(color.FgMagenta),
+	}
+}
+
+// PlainTheme uses no colors at all.
+func PlainTheme() *Theme {
+	plain := func() *color.Color {
+		c := color.New()
+		c.DisableColor()
+		return c
+	}
+	return &Theme{
+		dirColor:     plain(),
+		fileColor:    plain(),
+		execColor:    plain(),
+		symlinkColor: plain(),
+		promptColor:  plain(),
+		keyColor:     plain(),
+		stringColor:  plain(),
+		numberColor:  plain(),
+		literalColor: plain(),
+	}
+}
+
+var themes = map[string]func() *Theme{
+	"default": DefaultTheme,
+	"light":   LightTheme,
+	"plain":   PlainTheme,
+}
+
+// ThemeNames returns the names accepted by Named, sorted.
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
+
+// Named returns the theme with the given name.
+func Named(name string) (*Theme, error) {
+	newTheme, ok := themes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown theme %q (want one of %s)", name, strings.Join(ThemeNames(), ", "))
+	}
+	return newTheme(), nil
+}
+
+var current = DefaultTheme()
+
+// Current returns the theme in use. It is shared, so that a later call to
+// Use is seen by everything that holds it.
+func Current() *Theme {
+	return current
+}
+
+// Use makes t the theme in use.
+func Use(t *Theme) {
+	*current = *t
 }
 
 func (t *Theme) ColorizeName(name string, isDir bool, mode os.FileMode) string {
@@ -43,3 +140,15 @@ func (t *Theme) ColorizePermissions(perms string) string {
 func (t *Theme) ColorizePrompt(prompt string) string {
 	return t.promptColor.Sprint(prompt)
 }
+
+func (t *Theme) ColorizeJSON(kind JSONKind, token string) string {
+	switch kind {
+	case JSONKey:
+		return t.keyColor.Sprint(token)
+	case JSONString:
+		return t.stringColor.Sprint(token)
+	case JSONNumber:
+		return t.numberColor.Sprint(token)
+	}
+	return t.literalColor.Sprint(token)
+}
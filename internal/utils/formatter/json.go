@@ -0,0 +1,70 @@
+// internal/utils/formatter/json.go
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/krzko/gosh/internal/utils/color"
+)
+
+// WriteJSON writes data indented and, unless theme is nil, colored with
+// theme. Data that is not valid JSON is written unchanged.
+func WriteJSON(w io.Writer, data []byte, theme *color.Theme) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, bytes.TrimSpace(data), "", "    "); err != nil {
+		_, err := w.Write(data)
+		return err
+	}
+	buf.WriteByte('\n')
+	if theme == nil {
+		_, err := buf.WriteTo(w)
+		return err
+	}
+	_, err := io.WriteString(w, colorizeJSON(buf.String(), theme))
+	return err
+}
+
+// colorizeJSON colors the tokens of valid, indented JSON.
+func colorizeJSON(s string, theme *color.Theme) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end++
+			kind := color.JSONString
+			if strings.HasPrefix(s[end:], ":") {
+				kind = color.JSONKey
+			}
+			sb.WriteString(theme.ColorizeJSON(kind, s[i:end]))
+			i = end
+		case c == '-' || c >= '0' && c <= '9':
+			end := i + 1
+			for end < len(s) && strings.IndexByte("0123456789.eE+-", s[end]) >= 0 {
+				end++
+			}
+			sb.WriteString(theme.ColorizeJSON(color.JSONNumber, s[i:end]))
+			i = end
+		case c >= 'a' && c <= 'z':
+			end := i + 1
+			for end < len(s) && s[end] >= 'a' && s[end] <= 'z' {
+				end++
+			}
+			sb.WriteString(theme.ColorizeJSON(color.JSONLiteral, s[i:end]))
+			i = end
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	return sb.String()
+}
@@ -3,6 +3,7 @@ package formatter
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strings"
@@ -30,17 +31,23 @@ type FileInfo struct {
 
 func New() *TableFormatter {
 	return &TableFormatter{
-		theme: color.DefaultTheme(),
+		theme: color.Current(),
 	}
 }
 
+// NewWithTheme returns a formatter that colors names with theme instead of
+// the current theme.
+func NewWithTheme(theme *color.Theme) *TableFormatter {
+	return &TableFormatter{theme: theme}
+}
+
 func stripANSI(str string) string {
 	ansi := regexp.MustCompile(`\x1b\[[0-9;]*m`)
 	return ansi.ReplaceAllString(str, "")
 }
 
-func (t *TableFormatter) FormatLongList(entries []FileInfo) error {
-	table := tablewriter.NewWriter(os.Stdout)
+func (t *TableFormatter) FormatLongList(w io.Writer, entries []FileInfo) error {
+	table := tablewriter.NewWriter(w)
 
 	// Configure table
 	table.SetHeader([]string{"Permissions", "Owner", "Group", "Size", "Modified", "Name"})
@@ -62,7 +69,7 @@ func (t *TableFormatter) FormatLongList(entries []FileInfo) error {
 			t.theme.ColorizePermissions(entry.Permissions),
 			entry.Owner,
 			entry.Group,
-			formatSize(entry.Size),
+			FormatSize(entry.Size),
 			entry.ModTime.Format("Jan _2 15:04"),
 			t.theme.ColorizeName(entry.Name, entry.IsDir, entry.Mode),
 		}
@@ -74,14 +81,14 @@ func (t *TableFormatter) FormatLongList(entries []FileInfo) error {
 }
 
 // FormatSimpleList provides a simpler listing format
-func (t *TableFormatter) FormatSimpleList(entries []FileInfo) error {
+func (t *TableFormatter) FormatSimpleList(w io.Writer, entries []FileInfo) error {
 	for _, entry := range entries {
-		fmt.Println(t.theme.ColorizeName(entry.Name, entry.IsDir, entry.Mode))
+		fmt.Fprintln(w, t.theme.ColorizeName(entry.Name, entry.IsDir, entry.Mode))
 	}
 	return nil
 }
 
-func (t *TableFormatter) FormatCompact(entries []FileInfo, width int) error {
+func (t *TableFormatter) FormatCompact(w io.Writer, entries []FileInfo, width int) error {
 	if len(entries) == 0 {
 		return nil
 	}
@@ -142,18 +149,18 @@ func (t *TableFormatter) FormatCompact(entries []FileInfo, width int) error {
 				continue
 			}
 			if colIdx > 0 {
-				fmt.Print(strings.Repeat(" ", colWidth-len(stripANSI(row[colIdx-1]))))
+				fmt.Fprint(w, strings.Repeat(" ", colWidth-len(stripANSI(row[colIdx-1]))))
 			}
-			fmt.Print(name)
+			fmt.Fprint(w, name)
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
 	}
 
 	return nil
 }
 
 // FormatCompactList provides a compact multi-column listing
-func (t *TableFormatter) FormatCompactList(entries []FileInfo) error {
+func (t *TableFormatter) FormatCompactList(w io.Writer, entries []FileInfo) error {
 	termWidth := 80 // You might want to get actual terminal width
 	maxNameLength := 0
 
@@ -174,16 +181,17 @@ func (t *TableFormatter) FormatCompactList(entries []FileInfo) error {
 	// Print entries in columns
 	for i, entry := range entries {
 		if i > 0 && i%columns == 0 {
-			fmt.Println()
+			fmt.Fprintln(w)
 		}
-		fmt.Printf("%-*s", columnWidth, t.theme.ColorizeName(entry.Name, entry.IsDir, entry.Mode))
+		fmt.Fprintf(w, "%-*s", columnWidth, t.theme.ColorizeName(entry.Name, entry.IsDir, entry.Mode))
 	}
-	fmt.Println()
+	fmt.Fprintln(w)
 
 	return nil
 }
 
-func formatSize(size int64) string {
+// FormatSize formats a size in bytes for people, such as 512 or 1.5K.
+func FormatSize(size int64) string {
 	const unit = 1024
 	if size < unit {
 		return fmt.Sprintf("%d", size)
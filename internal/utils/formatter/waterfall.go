@@ -0,0 +1,70 @@
+// internal/utils/formatter/waterfall.go
+package formatter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// waterfallWidth is the width of the bars drawn by FormatWaterfall.
+const waterfallWidth = 40
+
+// Phase is a step of an operation that started Start after the operation
+// did.
+type Phase struct {
+	Name     string
+	Start    time.Duration
+	Duration time.Duration
+}
+
+// FormatWaterfall writes phases as a table, with a bar for each placed by
+// when it started and how long it took out of the total.
+func (t *TableFormatter) FormatWaterfall(w io.Writer, phases []Phase, total time.Duration) error {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Phase", "Start", "Duration", ""})
+	table.SetAutoWrapText(false)
+	table.SetAutoFormatHeaders(true)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT})
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetTablePadding("  ")
+	table.SetNoWhiteSpace(true)
+
+	for _, p := range phases {
+		table.Append([]string{p.Name, formatDuration(p.Start), formatDuration(p.Duration), waterfallBar(p, total)})
+	}
+	table.Append([]string{"Total", "", formatDuration(total), ""})
+	table.Render()
+	return nil
+}
+
+// waterfallBar draws a phase as a bar at least one column long.
+func waterfallBar(p Phase, total time.Duration) string {
+	if total <= 0 {
+		return ""
+	}
+	scale := func(d time.Duration) int {
+		return int(int64(d) * waterfallWidth / int64(total))
+	}
+	offset := min(scale(p.Start), waterfallWidth-1)
+	length := min(max(scale(p.Start+p.Duration)-offset, 1), waterfallWidth-offset)
+	return strings.Repeat(" ", offset) + strings.Repeat("█", length)
+}
+
+// formatDuration formats a duration in milliseconds, or seconds from one
+// second up, such as 12.3ms or 1.25s.
+func formatDuration(d time.Duration) string {
+	if d >= time.Second {
+		return fmt.Sprintf("%.2fs", d.Seconds())
+	}
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}